@@ -1,21 +1,29 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	neturl "net/url"
+	"os"
 	"regexp"
 	"strings"
 	"time"
 )
 
 const (
-	fxTwitterBase = "https://api.fxtwitter.com"
-	userAgent     = "x2md/1.0"
-	httpTimeout   = 30 * time.Second
+	// anonymousScreenName is the placeholder path segment in x.com/i/... URLs.
+	anonymousScreenName = "i"
+	userAgent           = "x2md/1.0"
+	httpTimeout         = 30 * time.Second
 )
 
+// fxTwitterBase is the FxTwitter API root, a variable so tests can point it
+// at a local server.
+var fxTwitterBase = "https://api.fxtwitter.com"
+
 var (
 	// Matches: x.com/{user}/status/{id}, twitter.com/{user}/status/{id},
 	// fxtwitter.com/{user}/status/{id}, fixupx.com/{user}/status/{id}
@@ -26,15 +34,46 @@ var (
 	articleURLPattern = regexp.MustCompile(
 		`(?:https?://)?(?:www\.)?(?:x\.com|twitter\.com|fxtwitter\.com|fixupx\.com)/([^/]+)/article/(\d+)`,
 	)
+	// Matches: bsky.app/profile/{handle or DID}/post/{rkey}
+	blueskyURLPattern = regexp.MustCompile(
+		`(?:https?://)?(?:www\.)?bsky\.app/profile/([^/?#]+)/post/([A-Za-z0-9]+)`,
+	)
+	// Matches: {instance}/@{user}/{id} and {instance}/@{user}@{domain}/{id}
+	mastodonURLPattern = regexp.MustCompile(
+		`^(?:https?://)?([A-Za-z0-9.-]+\.[A-Za-z]{2,}(?::\d+)?)/@([^/?#]+)/(\d+)`,
+	)
 )
 
-// ParseURL parses a tweet or article URL and returns structured info.
+// ParseURL parses a tweet, article, Bluesky post or Mastodon status URL and
+// returns structured info.
 func ParseURL(rawURL string) (URLInfo, error) {
 	rawURL = strings.TrimSpace(rawURL)
 
+	if m := blueskyURLPattern.FindStringSubmatch(rawURL); m != nil {
+		return URLInfo{
+			Type:        URLTypeTweet,
+			Source:      SourceBluesky,
+			ScreenName:  m[1],
+			ID:          m[2],
+			OriginalURL: blueskyPostURL(m[1], m[2]),
+		}, nil
+	}
+
+	if m := mastodonURLPattern.FindStringSubmatch(rawURL); m != nil {
+		return URLInfo{
+			Type:        URLTypeTweet,
+			Source:      SourceMastodon,
+			Host:        m[1],
+			ScreenName:  m[2],
+			ID:          m[3],
+			OriginalURL: fmt.Sprintf("https://%s/@%s/%s", m[1], m[2], m[3]),
+		}, nil
+	}
+
 	if m := articleURLPattern.FindStringSubmatch(rawURL); m != nil {
 		return URLInfo{
 			Type:        URLTypeArticle,
+			Source:      SourceFxTwitter,
 			ScreenName:  m[1],
 			ID:          m[2],
 			OriginalURL: normalizeOriginalURL(m[1], "article", m[2]),
@@ -44,6 +83,7 @@ func ParseURL(rawURL string) (URLInfo, error) {
 	if m := tweetURLPattern.FindStringSubmatch(rawURL); m != nil {
 		return URLInfo{
 			Type:        URLTypeTweet,
+			Source:      SourceFxTwitter,
 			ScreenName:  m[1],
 			ID:          m[2],
 			OriginalURL: normalizeOriginalURL(m[1], "status", m[2]),
@@ -58,21 +98,47 @@ func normalizeOriginalURL(screenName, pathType, id string) string {
 	return fmt.Sprintf("https://x.com/%s/%s/%s", screenName, pathType, id)
 }
 
+// tweetURLInfo builds URL info for a tweet obtained without a URL (e.g. from a file).
+func tweetURLInfo(tweet *Tweet) URLInfo {
+	info := URLInfo{Type: URLTypeTweet, Source: SourceFxTwitter, ID: tweet.ID, OriginalURL: tweet.URL}
+	if tweet.Author != nil {
+		info.ScreenName = tweet.Author.ScreenName
+		info.OriginalURL = normalizeOriginalURL(info.ScreenName, "status", tweet.ID)
+	}
+	return info
+}
+
 // FetchTweet fetches a single tweet from FxTwitter API.
-func FetchTweet(screenName, id string) (*Tweet, error) {
+func FetchTweet(ctx context.Context, screenName, id string) (*Tweet, error) {
 	url := fmt.Sprintf("%s/%s/status/%s", fxTwitterBase, screenName, id)
-	return fetchAndParse(url)
+	return fetchAndParse(ctx, url)
+}
+
+// FetchTweetTranslated fetches a single tweet with its text translated to lang.
+func FetchTweetTranslated(ctx context.Context, screenName, id, lang string) (*Tweet, error) {
+	url := fmt.Sprintf("%s/%s/status/%s/%s", fxTwitterBase, screenName, id, lang)
+	return fetchAndParse(ctx, url)
 }
 
 // FetchArticle fetches an article from FxTwitter API.
-func FetchArticle(screenName, id string) (*Tweet, error) {
+func FetchArticle(ctx context.Context, screenName, id string) (*Tweet, error) {
+	// x.com/i/article/{id} links carry no screen name
+	if screenName == anonymousScreenName {
+		url := fmt.Sprintf("%s/i/article/%s", fxTwitterBase, id)
+		tweet, err := fetchAndParse(ctx, url)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch article %s: %w", id, err)
+		}
+		return tweet, nil
+	}
+
 	// Try with screen name first
 	url := fmt.Sprintf("%s/%s/article/%s", fxTwitterBase, screenName, id)
-	tweet, err := fetchAndParse(url)
+	tweet, err := fetchAndParse(ctx, url)
 	if err != nil {
 		// Fallback: try with /i/ path
 		url = fmt.Sprintf("%s/i/article/%s", fxTwitterBase, id)
-		tweet, err = fetchAndParse(url)
+		tweet, err = fetchAndParse(ctx, url)
 		if err != nil {
 			return nil, fmt.Errorf("failed to fetch article %s: %w", id, err)
 		}
@@ -80,43 +146,166 @@ func FetchArticle(screenName, id string) (*Tweet, error) {
 	return tweet, nil
 }
 
+// Timeouts for API calls and media downloads, settable via -timeout and
+// -download-timeout.
+var (
+	apiTimeout      = httpTimeout
+	downloadTimeout = httpTimeout
+)
+
+// newHTTPClient returns the HTTP client used for API calls and downloads.
+func newHTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{Timeout: timeout}
+}
+
+// Request settings shared by API calls and media downloads, settable via
+// -user-agent, -header and -media-headers.
+var (
+	requestUserAgent = userAgent
+	requestHeaders   = http.Header{}
+	// mediaHeaders also sends requestHeaders with media downloads.
+	mediaHeaders bool
+)
+
+// newRequest builds a GET request carrying the configured User-Agent; a
+// -header for User-Agent wins over -user-agent. Other extra headers may hold
+// credentials for the FxTwitter API, so they are only sent to its host, and
+// to media hosts (media set) with -media-headers.
+func newRequest(ctx context.Context, url string, media bool) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", requestUserAgent)
+	if ua := requestHeaders.Get("User-Agent"); ua != "" {
+		req.Header.Set("User-Agent", ua)
+	}
+	if (media && mediaHeaders) || isFxTwitterHost(req.URL.Host) {
+		for name, values := range requestHeaders {
+			req.Header[name] = values
+		}
+	}
+	return req, nil
+}
+
+// isFxTwitterHost reports whether host serves the FxTwitter API.
+func isFxTwitterHost(host string) bool {
+	base, err := neturl.Parse(fxTwitterBase)
+	return err == nil && strings.EqualFold(host, base.Host)
+}
+
 // fetchAndParse makes an HTTP GET request and parses the JSON response.
-func fetchAndParse(url string) (*Tweet, error) {
-	client := &http.Client{Timeout: httpTimeout}
+// Cancelling ctx aborts the request.
+func fetchAndParse(ctx context.Context, url string) (*Tweet, error) {
+	body, status, err := apiGet(ctx, url)
+	if err != nil {
+		return nil, err
+	}
 
-	req, err := http.NewRequest("GET", url, nil)
+	if status != http.StatusOK {
+		// Prefer the API's own error when the body carries one.
+		var apiResp APIResponse
+		if json.Unmarshal(body, &apiResp) == nil && apiResp.Code != 0 && apiResp.Code != 200 {
+			return nil, apiError(apiResp, body)
+		}
+		return nil, statusError("API", status, body)
+	}
+
+	return parseAPIResponse(body)
+}
+
+// apiGet makes a rate-limited API GET request, returning the response body
+// and status code. Cancelling ctx aborts the request.
+func apiGet(ctx context.Context, url string) ([]byte, int, error) {
+	client := newHTTPClient(apiTimeout)
+
+	req, err := newRequest(ctx, url, false)
 	if err != nil {
-		return nil, fmt.Errorf("creating request: %w", err)
+		return nil, 0, fmt.Errorf("creating request: %w", err)
 	}
-	req.Header.Set("User-Agent", userAgent)
 
+	if err := apiLimiter.Wait(ctx); err != nil {
+		return nil, 0, err
+	}
 	resp, err := client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("HTTP request failed: %w", err)
+		return nil, 0, fmt.Errorf("HTTP request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("reading response body: %w", err)
+		return nil, 0, fmt.Errorf("reading response body: %w", err)
 	}
+	return body, resp.StatusCode, nil
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
+// LoadTweetFile reads a saved FxTwitter API response from a JSON file.
+func LoadTweetFile(path string) (*Tweet, error) {
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	tweet, err := parseAPIResponse(body)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
 	}
+	return tweet, nil
+}
 
+// parseAPIResponse decodes an FxTwitter API response body into its tweet.
+func parseAPIResponse(body []byte) (*Tweet, error) {
 	var apiResp APIResponse
 	if err := json.Unmarshal(body, &apiResp); err != nil {
 		return nil, fmt.Errorf("parsing JSON response: %w", err)
 	}
 
+	// FxTwitter reports most failures as HTTP 200 with the error in "code".
 	if apiResp.Code != 200 {
-		return nil, fmt.Errorf("API error (code %d): %s", apiResp.Code, apiResp.Message)
+		return nil, apiError(apiResp, body)
 	}
 
 	if apiResp.Tweet == nil {
 		return nil, fmt.Errorf("no tweet data in response")
 	}
 
+	apiResp.Tweet.Raw = body
 	return apiResp.Tweet, nil
 }
+
+// APIError is a failure reported by an API, with the code it gave: the
+// response's own error code or, failing that, the HTTP status.
+type APIError struct {
+	Code int
+	msg  string
+}
+
+func (e *APIError) Error() string { return e.msg }
+
+// apiError describes a response whose code is not 200, falling back to the
+// start of the raw body when the API gave no message.
+func apiError(apiResp APIResponse, body []byte) error {
+	msg := apiResp.Message
+	if msg == "" {
+		msg = bodySnippet(body)
+	}
+	return &APIError{Code: apiResp.Code, msg: fmt.Sprintf("API error (code %d): %s", apiResp.Code, msg)}
+}
+
+// statusError describes a non-200 response from the named API that carried
+// no error of its own.
+func statusError(api string, status int, body []byte) error {
+	return &APIError{Code: status, msg: fmt.Sprintf("%s returned status %d: %s", api, status, bodySnippet(body))}
+}
+
+// maxBodySnippet is the number of characters of a response body quoted in errors.
+const maxBodySnippet = 200
+
+// bodySnippet returns the start of a response body on a single line.
+func bodySnippet(body []byte) string {
+	s := strings.Join(strings.Fields(string(body)), " ")
+	if s == "" {
+		return "(empty body)"
+	}
+	return truncateRunes(s, maxBodySnippet)
+}
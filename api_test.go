@@ -0,0 +1,319 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fxStub is a fake FxTwitter API serving canned tweets by request path.
+type fxStub struct {
+	mu     sync.Mutex
+	tweets map[string]*Tweet
+	paths  []string
+}
+
+// stubFxTwitter points the FxTwitter client at a local server for the rest
+// of the test, with rate limiting off.
+func stubFxTwitter(t *testing.T, tweets map[string]*Tweet) *fxStub {
+	t.Helper()
+	stub := &fxStub{tweets: tweets}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		stub.mu.Lock()
+		stub.paths = append(stub.paths, r.URL.Path)
+		tweet, ok := stub.tweets[r.URL.Path]
+		stub.mu.Unlock()
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(APIResponse{Code: 404, Message: "NOT_FOUND"})
+			return
+		}
+		json.NewEncoder(w).Encode(APIResponse{Code: 200, Message: "OK", Tweet: tweet})
+	}))
+
+	base, limiter := fxTwitterBase, apiLimiter
+	fxTwitterBase, apiLimiter = srv.URL, newRateLimiter(0)
+	t.Cleanup(func() {
+		srv.Close()
+		fxTwitterBase, apiLimiter = base, limiter
+	})
+	return stub
+}
+
+func TestParseAnonymousArticleURL(t *testing.T) {
+	info, err := ParseURL("https://x.com/i/article/1234567890")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := URLInfo{
+		Type:        URLTypeArticle,
+		Source:      SourceFxTwitter,
+		ScreenName:  anonymousScreenName,
+		ID:          "1234567890",
+		OriginalURL: "https://x.com/i/article/1234567890",
+	}
+	if info != want {
+		t.Errorf("ParseURL() = %+v, want %+v", info, want)
+	}
+}
+
+func TestFetchArticle(t *testing.T) {
+	article := &Tweet{ID: "1234567890", Article: &Article{Title: "Title"}}
+	stub := stubFxTwitter(t, map[string]*Tweet{"/i/article/1234567890": article})
+
+	tweet, err := FetchArticle(context.Background(), anonymousScreenName, "1234567890")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tweet.Article == nil || tweet.Article.Title != "Title" {
+		t.Errorf("FetchArticle() = %+v", tweet)
+	}
+	if want := []string{"/i/article/1234567890"}; !slices.Equal(stub.paths, want) {
+		t.Errorf("requested %v, want %v", stub.paths, want)
+	}
+
+	// A screen name path that fails falls back to /i/article/.
+	stub.paths = nil
+	if _, err := FetchArticle(context.Background(), "alice", "1234567890"); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"/alice/article/1234567890", "/i/article/1234567890"}; !slices.Equal(stub.paths, want) {
+		t.Errorf("requested %v, want %v", stub.paths, want)
+	}
+}
+
+func TestLoadTweetFileFixture(t *testing.T) {
+	tweet, err := LoadTweetFile(filepath.Join("testdata", "tweet.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := os.ReadFile(filepath.Join("testdata", "tweet.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := RenderTweet(tweet, RenderOptions{}); got != string(want) {
+		t.Errorf("rendered fixture =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestLoadTweetFileMalformed(t *testing.T) {
+	dir := t.TempDir()
+	for name, body := range map[string]string{
+		"truncated.json": `{"code": 200, "tweet": {`,
+		"error.json":     `{"code": 404, "message": "NOT_FOUND"}`,
+		"empty.json":     `{"code": 200, "message": "OK"}`,
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadTweetFile(path); err == nil || !strings.Contains(err.Error(), path) {
+			t.Errorf("LoadTweetFile(%s) error = %v, want one naming the file", name, err)
+		}
+	}
+}
+
+// withTimeouts sets the API and download timeouts for the rest of the test.
+func withTimeouts(t *testing.T, api, download time.Duration) {
+	t.Helper()
+	oldAPI, oldDownload := apiTimeout, downloadTimeout
+	apiTimeout, downloadTimeout = api, download
+	t.Cleanup(func() { apiTimeout, downloadTimeout = oldAPI, oldDownload })
+}
+
+func TestTimeouts(t *testing.T) {
+	if got := newHTTPClient(5 * time.Second).Timeout; got != 5*time.Second {
+		t.Errorf("newHTTPClient(5s).Timeout = %v", got)
+	}
+
+	stubFxTwitter(t, map[string]*Tweet{"/alice/status/1": {ID: "1"}})
+	api := fxTwitterBase
+	// slow answers after a delay by redirecting to the stub API.
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+		http.Redirect(w, r, api+r.URL.Path, http.StatusFound)
+	}))
+	defer slow.Close()
+	fxTwitterBase = slow.URL
+
+	withTimeouts(t, 20*time.Millisecond, time.Minute)
+	start := time.Now()
+	if _, err := FetchTweet(context.Background(), "alice", "1"); err == nil {
+		t.Error("API call outlived -timeout")
+	} else if elapsed := time.Since(start); elapsed > 150*time.Millisecond {
+		t.Errorf("API call took %v with a 20ms -timeout", elapsed)
+	}
+
+	withTimeouts(t, time.Minute, 20*time.Millisecond)
+	if _, err := FetchTweet(context.Background(), "alice", "1"); err != nil {
+		t.Errorf("API call used -download-timeout: %v", err)
+	}
+	if _, _, err := downloadFile(slow.URL+"/alice/status/1", filepath.Join(t.TempDir(), "img")); err == nil {
+		t.Error("download outlived -download-timeout")
+	}
+}
+
+func TestFetchTweetCancel(t *testing.T) {
+	arrived := make(chan struct{})
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(arrived)
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+	base, limiter := fxTwitterBase, apiLimiter
+	fxTwitterBase, apiLimiter = srv.URL, newRateLimiter(0)
+	defer func() { fxTwitterBase, apiLimiter = base, limiter }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-arrived
+		cancel()
+	}()
+	start := time.Now()
+	_, err := FetchTweet(ctx, "alice", "1")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("FetchTweet error = %v, want context.Canceled", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("cancelled fetch took %v", elapsed)
+	}
+
+	// A context cancelled up front sends no request at all.
+	stub := stubFxTwitter(t, map[string]*Tweet{"/alice/status/1": {ID: "1"}})
+	if _, err := FetchTweet(ctx, "alice", "1"); !errors.Is(err, context.Canceled) || len(stub.paths) != 0 {
+		t.Errorf("FetchTweet with a cancelled context: err = %v, requests %v", err, stub.paths)
+	}
+}
+
+// withRequestHeaders sets the -user-agent, -header and -media-headers
+// settings for the rest of the test.
+func withRequestHeaders(t *testing.T, ua string, headers http.Header, media bool) {
+	t.Helper()
+	oldUA, oldHeaders, oldMedia := requestUserAgent, requestHeaders, mediaHeaders
+	requestUserAgent, requestHeaders, mediaHeaders = ua, headers, media
+	t.Cleanup(func() { requestUserAgent, requestHeaders, mediaHeaders = oldUA, oldHeaders, oldMedia })
+}
+
+// headerRecorder starts a server answering with a PNG and recording the
+// headers of each request.
+func headerRecorder(t *testing.T) (*httptest.Server, *[]http.Header) {
+	t.Helper()
+	var mu sync.Mutex
+	var got []http.Header
+	body := testPNG(t, 1, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		got = append(got, r.Header.Clone())
+		mu.Unlock()
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &got
+}
+
+func TestUserAgentOnDownload(t *testing.T) {
+	srv, got := headerRecorder(t)
+	withRequestHeaders(t, "archiver/2.0", http.Header{}, false)
+
+	if _, _, err := downloadFile(srv.URL+"/a.png", filepath.Join(t.TempDir(), "a.png")); err != nil {
+		t.Fatal(err)
+	}
+	if len(*got) != 1 || (*got)[0].Get("User-Agent") != "archiver/2.0" {
+		t.Errorf("download headers = %v, want User-Agent archiver/2.0", *got)
+	}
+}
+
+func TestRequestHeaderScope(t *testing.T) {
+	stubFxTwitter(t, map[string]*Tweet{"/alice/status/1": {ID: "1"}})
+	headers := http.Header{}
+	headers.Set("Authorization", "Bearer secret")
+	headers.Set("User-Agent", "custom/1.0")
+	withRequestHeaders(t, "x2md/1.0", headers, false)
+
+	req, err := newRequest(context.Background(), fxTwitterBase+"/alice/status/1", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Header.Get("Authorization") != "Bearer secret" || req.Header.Get("User-Agent") != "custom/1.0" {
+		t.Errorf("API request headers = %v", req.Header)
+	}
+
+	// Other API hosts and media hosts get the User-Agent only.
+	srv, got := headerRecorder(t)
+	for _, url := range []string{blueskyAPIBase + "/app.bsky.feed.getPostThread", "https://mastodon.social/api/v1/statuses/1"} {
+		req, err := newRequest(context.Background(), url, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if req.Header.Get("Authorization") != "" || req.Header.Get("User-Agent") != "custom/1.0" {
+			t.Errorf("%s: headers = %v", url, req.Header)
+		}
+	}
+	if _, _, err := downloadFile(srv.URL+"/a.png", filepath.Join(t.TempDir(), "a.png")); err != nil {
+		t.Fatal(err)
+	}
+	if h := (*got)[0]; h.Get("Authorization") != "" || h.Get("User-Agent") != "custom/1.0" {
+		t.Errorf("download without -media-headers sent %v", h)
+	}
+
+	// -media-headers opts media downloads in.
+	mediaHeaders = true
+	if _, _, err := downloadFile(srv.URL+"/b.png", filepath.Join(t.TempDir(), "b.png")); err != nil {
+		t.Fatal(err)
+	}
+	if h := (*got)[1]; h.Get("Authorization") != "Bearer secret" {
+		t.Errorf("download with -media-headers sent %v", h)
+	}
+}
+
+func TestFetchTweetErrorCodes(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		wantCode int
+		wantMsg  string
+	}{
+		{"200 with code 404", http.StatusOK, `{"code":404,"message":"NOT_FOUND","tweet":null}`, 404, "API error (code 404): NOT_FOUND"},
+		{"200 with code and no message", http.StatusOK, `{"code": 401}`, 401, `API error (code 401): {"code": 401}`},
+		{"200 with code 500", http.StatusOK, `{"code":500,"message":"API_FAIL"}`, 500, "API error (code 500): API_FAIL"},
+		{"status with API code", http.StatusNotFound, `{"code":404,"message":"NOT_FOUND"}`, 404, "API error (code 404): NOT_FOUND"},
+		{"status without API code", http.StatusBadGateway, "<html>Bad gateway</html>", 502, "API returned status 502: <html>Bad gateway</html>"},
+	}
+	for _, tt := range tests {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tt.status)
+			w.Write([]byte(tt.body))
+		}))
+		base, limiter := fxTwitterBase, apiLimiter
+		fxTwitterBase, apiLimiter = srv.URL, newRateLimiter(0)
+
+		_, err := FetchTweet(context.Background(), "alice", "1")
+		srv.Close()
+		fxTwitterBase, apiLimiter = base, limiter
+
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) {
+			t.Errorf("%s: error = %v, want an *APIError", tt.name, err)
+			continue
+		}
+		if apiErr.Code != tt.wantCode || err.Error() != tt.wantMsg {
+			t.Errorf("%s: error = %q (code %d), want %q (code %d)", tt.name, err, apiErr.Code, tt.wantMsg, tt.wantCode)
+		}
+	}
+}
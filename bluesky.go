@@ -0,0 +1,354 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	// blueskyAPIBase is Bluesky's public, unauthenticated AppView API.
+	blueskyAPIBase = "https://public.api.bsky.app/xrpc"
+	blueskyWebBase = "https://bsky.app"
+)
+
+// blueskySensitiveLabels are the moderation labels -mark-sensitive folds.
+var blueskySensitiveLabels = map[string]bool{
+	"porn": true, "sexual": true, "nudity": true, "graphic-media": true, "gore": true,
+}
+
+// blueskyFetcher fetches posts from Bluesky's public API, mapping them onto
+// the Tweet model. screenName is a handle or DID and id a post record key.
+type blueskyFetcher struct{}
+
+// FetchTweet fetches a Bluesky post along with its parent, which supplies
+// ReplyingTo and ReplyingToStatus for thread traversal.
+func (blueskyFetcher) FetchTweet(ctx context.Context, screenName, id string) (*Tweet, error) {
+	did, err := resolveBlueskyHandle(ctx, screenName)
+	if err != nil {
+		return nil, fmt.Errorf("resolving handle %s: %w", screenName, err)
+	}
+	q := url.Values{}
+	q.Set("uri", "at://"+did+"/app.bsky.feed.post/"+id)
+	q.Set("depth", "0")
+	q.Set("parentHeight", "1")
+	body, err := blueskyGet(ctx, "app.bsky.feed.getPostThread", q)
+	if err != nil {
+		return nil, err
+	}
+	return parseBlueskyThread(body)
+}
+
+// blueskyDIDs caches handle resolutions, since every post of a thread is
+// fetched by its author's handle.
+var blueskyDIDs sync.Map
+
+// resolveBlueskyHandle returns the DID behind a handle; DIDs are returned
+// as is.
+func resolveBlueskyHandle(ctx context.Context, handle string) (string, error) {
+	if strings.HasPrefix(handle, "did:") {
+		return handle, nil
+	}
+	if did, ok := blueskyDIDs.Load(handle); ok {
+		return did.(string), nil
+	}
+	body, err := blueskyGet(ctx, "com.atproto.identity.resolveHandle", url.Values{"handle": {handle}})
+	if err != nil {
+		return "", err
+	}
+	var resp struct {
+		DID string `json:"did"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return "", fmt.Errorf("parsing JSON response: %w", err)
+	}
+	if resp.DID == "" {
+		return "", fmt.Errorf("no DID in response")
+	}
+	blueskyDIDs.Store(handle, resp.DID)
+	return resp.DID, nil
+}
+
+// blueskyGet calls an XRPC query method and returns the response body,
+// turning XRPC error responses into errors.
+func blueskyGet(ctx context.Context, method string, params url.Values) ([]byte, error) {
+	body, status, err := apiGet(ctx, blueskyAPIBase+"/"+method+"?"+params.Encode())
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		var xrpcErr struct {
+			Error   string `json:"error"`
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(body, &xrpcErr) == nil && xrpcErr.Error != "" {
+			return nil, &APIError{Code: status, msg: fmt.Sprintf("Bluesky API error (%s): %s", xrpcErr.Error, xrpcErr.Message)}
+		}
+		return nil, statusError("Bluesky API", status, body)
+	}
+	return body, nil
+}
+
+// bskyThreadResponse is the app.bsky.feed.getPostThread response.
+type bskyThreadResponse struct {
+	Thread struct {
+		Post   *bskyPost `json:"post"`
+		Parent *struct {
+			Post *bskyPost `json:"post"` // absent for deleted or blocked parents
+		} `json:"parent"`
+	} `json:"thread"`
+}
+
+// bskyPost is an app.bsky.feed.defs#postView, or the
+// app.bsky.embed.record#viewRecord of a quoted post, which carries its record
+// in Value and its embeds in Embeds.
+type bskyPost struct {
+	URI         string       `json:"uri"`
+	Author      bskyAuthor   `json:"author"`
+	Record      *bskyRecord  `json:"record"`
+	Value       *bskyRecord  `json:"value"`
+	Embed       *bskyEmbed   `json:"embed"`
+	Embeds      []*bskyEmbed `json:"embeds"`
+	ReplyCount  int          `json:"replyCount"`
+	RepostCount int          `json:"repostCount"`
+	LikeCount   int          `json:"likeCount"`
+	Labels      []bskyLabel  `json:"labels"`
+}
+
+type bskyAuthor struct {
+	DID         string `json:"did"`
+	Handle      string `json:"handle"`
+	DisplayName string `json:"displayName"`
+	Avatar      string `json:"avatar"`
+}
+
+type bskyLabel struct {
+	Val string `json:"val"`
+}
+
+// bskyRecord is an app.bsky.feed.post record.
+type bskyRecord struct {
+	Text      string      `json:"text"`
+	CreatedAt string      `json:"createdAt"`
+	Langs     []string    `json:"langs"`
+	Facets    []bskyFacet `json:"facets"`
+	Reply     *struct {
+		Parent struct {
+			URI string `json:"uri"`
+		} `json:"parent"`
+	} `json:"reply"`
+}
+
+// bskyFacet annotates a UTF-8 byte range of a post's text.
+type bskyFacet struct {
+	Index struct {
+		ByteStart int `json:"byteStart"`
+		ByteEnd   int `json:"byteEnd"`
+	} `json:"index"`
+	Features []struct {
+		Type string `json:"$type"`
+		URI  string `json:"uri"`
+	} `json:"features"`
+}
+
+// bskyEmbed is any of the embed views; which fields are set depends on Type.
+type bskyEmbed struct {
+	Type   string `json:"$type"`
+	Images []struct {
+		Fullsize    string           `json:"fullsize"`
+		Alt         string           `json:"alt"`
+		AspectRatio *bskyAspectRatio `json:"aspectRatio"`
+	} `json:"images"`
+	// Video embeds.
+	Playlist    string           `json:"playlist"`
+	Thumbnail   string           `json:"thumbnail"`
+	AspectRatio *bskyAspectRatio `json:"aspectRatio"`
+	// External link cards.
+	External *struct {
+		URI string `json:"uri"`
+	} `json:"external"`
+	// Quotes: the quoted post for record embeds, or a nested record embed
+	// for recordWithMedia, which also carries Media.
+	Record json.RawMessage `json:"record"`
+	Media  *bskyEmbed      `json:"media"`
+}
+
+type bskyAspectRatio struct {
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// parseBlueskyThread decodes a getPostThread response into its post.
+func parseBlueskyThread(body []byte) (*Tweet, error) {
+	var resp bskyThreadResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("parsing JSON response: %w", err)
+	}
+	if resp.Thread.Post == nil {
+		return nil, fmt.Errorf("no post data in response")
+	}
+
+	tweet := resp.Thread.Post.toTweet()
+	if record := resp.Thread.Post.Record; record != nil && record.Reply != nil {
+		did, rkey := splitPostURI(record.Reply.Parent.URI)
+		tweet.ReplyingTo, tweet.ReplyingToStatus = did, rkey
+		if parent := resp.Thread.Parent; parent != nil && parent.Post != nil {
+			tweet.ReplyingTo = parent.Post.Author.Handle
+		}
+	}
+	tweet.Raw = body
+	return tweet, nil
+}
+
+// toTweet maps a post onto the Tweet model.
+func (p *bskyPost) toTweet() *Tweet {
+	record := p.Record
+	if record == nil {
+		record = p.Value
+	}
+	if record == nil {
+		record = &bskyRecord{}
+	}
+	_, rkey := splitPostURI(p.URI)
+
+	tweet := &Tweet{
+		ID:        rkey,
+		URL:       blueskyPostURL(p.Author.Handle, rkey),
+		Origin:    SourceBluesky,
+		Text:      expandFacetLinks(record.Text, record.Facets),
+		CreatedAt: record.CreatedAt,
+		Likes:     Count(p.LikeCount),
+		Retweets:  Count(p.RepostCount),
+		Replies:   Count(p.ReplyCount),
+		Author: &Author{
+			ID:         p.Author.DID,
+			Name:       p.Author.DisplayName,
+			ScreenName: p.Author.Handle,
+			AvatarURL:  p.Author.Avatar,
+		},
+	}
+	if len(record.Langs) > 0 {
+		tweet.Lang = record.Langs[0]
+	}
+	for _, label := range p.Labels {
+		if blueskySensitiveLabels[label.Val] {
+			tweet.PossiblySensitive = true
+		}
+	}
+
+	embeds := p.Embeds
+	if p.Embed != nil {
+		embeds = append(embeds, p.Embed)
+	}
+	for _, embed := range embeds {
+		addBlueskyEmbed(tweet, embed)
+	}
+	return tweet
+}
+
+// addBlueskyEmbed adds an embed's media, quoted post or link card to tweet.
+func addBlueskyEmbed(tweet *Tweet, embed *bskyEmbed) {
+	switch strings.TrimSuffix(embed.Type, "#view") {
+	case "app.bsky.embed.images":
+		media := blueskyMedia(tweet)
+		for _, img := range embed.Images {
+			photo := Photo{URL: img.Fullsize, AltText: img.Alt}
+			if img.AspectRatio != nil {
+				photo.Width, photo.Height = img.AspectRatio.Width, img.AspectRatio.Height
+			}
+			media.Photos = append(media.Photos, photo)
+			media.All = append(media.All, MediaItem{
+				Type: "photo", URL: photo.URL, Width: photo.Width, Height: photo.Height, AltText: photo.AltText,
+			})
+		}
+	case "app.bsky.embed.video":
+		media := blueskyMedia(tweet)
+		video := Video{URL: embed.Playlist, ThumbnailURL: embed.Thumbnail}
+		if embed.AspectRatio != nil {
+			video.Width, video.Height = embed.AspectRatio.Width, embed.AspectRatio.Height
+		}
+		media.Videos = append(media.Videos, video)
+		media.All = append(media.All, MediaItem{
+			Type: "video", URL: video.URL, Width: video.Width, Height: video.Height, ThumbnailURL: video.ThumbnailURL,
+		})
+	case "app.bsky.embed.external":
+		if embed.External != nil && !strings.Contains(tweet.Text, embed.External.URI) {
+			tweet.Text = strings.TrimSpace(tweet.Text + "\n\n" + embed.External.URI)
+		}
+	case "app.bsky.embed.record":
+		var quoted bskyPost
+		// Deleted, blocked or non-post records lack a post record and are skipped.
+		if json.Unmarshal(embed.Record, &quoted) == nil && quoted.Value != nil {
+			tweet.Quote = quoted.toTweet()
+		}
+	case "app.bsky.embed.recordWithMedia":
+		var nested bskyEmbed
+		if json.Unmarshal(embed.Record, &nested) == nil {
+			nested.Type = "app.bsky.embed.record#view"
+			addBlueskyEmbed(tweet, &nested)
+		}
+		if embed.Media != nil {
+			addBlueskyEmbed(tweet, embed.Media)
+		}
+	}
+}
+
+func blueskyMedia(tweet *Tweet) *Media {
+	if tweet.Media == nil {
+		tweet.Media = &Media{}
+	}
+	return tweet.Media
+}
+
+// expandFacetLinks replaces the shortened link text Bluesky shows in a post
+// ("example.com/very-lo...") with the full URL from the link facets.
+func expandFacetLinks(text string, facets []bskyFacet) string {
+	type link struct {
+		start, end int
+		uri        string
+	}
+	var links []link
+	for _, f := range facets {
+		start, end := f.Index.ByteStart, f.Index.ByteEnd
+		if start < 0 || end > len(text) || start >= end {
+			continue
+		}
+		for _, feature := range f.Features {
+			if feature.Type == "app.bsky.richtext.facet#link" && feature.URI != "" {
+				links = append(links, link{start, end, feature.URI})
+			}
+		}
+	}
+	// Replace from the end so earlier byte offsets stay valid.
+	sort.Slice(links, func(i, j int) bool { return links[i].start > links[j].start })
+	last := len(text) + 1
+	for _, l := range links {
+		if l.end > last {
+			continue // overlapping facets
+		}
+		text = text[:l.start] + l.uri + text[l.end:]
+		last = l.start
+	}
+	return text
+}
+
+// splitPostURI splits an at://{did}/app.bsky.feed.post/{rkey} URI into the
+// repository DID and the record key.
+func splitPostURI(uri string) (did, rkey string) {
+	rest := strings.TrimPrefix(uri, "at://")
+	did, _, _ = strings.Cut(rest, "/")
+	if i := strings.LastIndex(rest, "/"); i >= 0 {
+		rkey = rest[i+1:]
+	}
+	return did, rkey
+}
+
+// blueskyPostURL returns the bsky.app URL of a post.
+func blueskyPostURL(handle, rkey string) string {
+	return fmt.Sprintf("%s/profile/%s/post/%s", blueskyWebBase, handle, rkey)
+}
@@ -0,0 +1,46 @@
+package main
+
+import "testing"
+
+func TestParseBlueskyURL(t *testing.T) {
+	tests := []struct {
+		url        string
+		screenName string
+		id         string
+	}{
+		{"https://bsky.app/profile/alice.bsky.social/post/3kabc123xyz", "alice.bsky.social", "3kabc123xyz"},
+		{"bsky.app/profile/alice.example.com/post/3kabc123xyz?ref=share", "alice.example.com", "3kabc123xyz"},
+		{"https://www.bsky.app/profile/did:plc:abc123/post/3kabc123xyz#reply", "did:plc:abc123", "3kabc123xyz"},
+	}
+	for _, tt := range tests {
+		info, err := ParseURL(tt.url)
+		if err != nil {
+			t.Errorf("ParseURL(%q): %v", tt.url, err)
+			continue
+		}
+		want := URLInfo{
+			Type:        URLTypeTweet,
+			Source:      SourceBluesky,
+			ScreenName:  tt.screenName,
+			ID:          tt.id,
+			OriginalURL: "https://bsky.app/profile/" + tt.screenName + "/post/" + tt.id,
+		}
+		if info != want {
+			t.Errorf("ParseURL(%q) = %+v, want %+v", tt.url, info, want)
+		}
+		if f, err := newFetcher(info); err != nil {
+			t.Errorf("newFetcher(%q): %v", tt.url, err)
+		} else if _, ok := f.(blueskyFetcher); !ok {
+			t.Errorf("newFetcher(%q) = %T, want blueskyFetcher", tt.url, f)
+		}
+	}
+
+	for _, bad := range []string{
+		"https://bsky.app/profile/alice.bsky.social",
+		"https://bsky.app/profile/alice.bsky.social/feed/3kabc123xyz",
+	} {
+		if info, err := ParseURL(bad); err == nil {
+			t.Errorf("ParseURL(%q) = %+v, want an error", bad, info)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// defaultCalloutTypes maps blockquote prefixes to the callout types -callouts
+// emits unless -callout-types says otherwise.
+const defaultCalloutTypes = "Note=NOTE,Warning=WARNING,Tip=TIP,Important=IMPORTANT,Caution=CAUTION"
+
+// parseCalloutTypes parses a comma-separated list of prefix=TYPE pairs into a
+// map keyed by the lowercased prefix.
+func parseCalloutTypes(list string) (map[string]string, error) {
+	types := make(map[string]string)
+	for _, pair := range strings.Split(list, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		prefix, typ, ok := strings.Cut(pair, "=")
+		prefix, typ = strings.TrimSpace(prefix), strings.TrimSpace(typ)
+		if !ok || prefix == "" || typ == "" {
+			return nil, fmt.Errorf("expected prefix=TYPE, got %q", pair)
+		}
+		types[strings.ToLower(prefix)] = strings.ToUpper(typ)
+	}
+	return types, nil
+}
+
+// applyCallouts turns blockquotes whose first line starts with a known prefix
+// such as "Note:" into GitHub/Obsidian callouts ("> [!NOTE]"). Other
+// blockquotes and fenced code are left untouched.
+func applyCallouts(text string, types map[string]string) string {
+	if len(types) == 0 {
+		return text
+	}
+	lines := strings.Split(text, "\n")
+	var out []string
+	inFence, inQuote := false, false
+	for _, line := range lines {
+		if strings.HasPrefix(line, "```") {
+			inFence = !inFence
+		}
+		quoted := !inFence && strings.HasPrefix(line, ">")
+		// Only the first line of a quote can carry the prefix.
+		if quoted && !inQuote {
+			if header, rest, ok := calloutLine(line, types); ok {
+				out = append(out, header)
+				if rest != "" {
+					out = append(out, "> "+rest)
+				}
+				inQuote = true
+				continue
+			}
+		}
+		inQuote = quoted
+		out = append(out, line)
+	}
+	return strings.Join(out, "\n")
+}
+
+// calloutLine reports whether a top-level quote line starts with a known
+// prefix, returning the callout header and the text after the prefix.
+func calloutLine(line string, types map[string]string) (header, rest string, ok bool) {
+	body, found := strings.CutPrefix(line, "> ")
+	if !found {
+		return "", "", false
+	}
+	prefix, rest, found := strings.Cut(body, ":")
+	if !found {
+		return "", "", false
+	}
+	typ, known := types[strings.ToLower(strings.TrimSpace(prefix))]
+	if !known {
+		return "", "", false
+	}
+	return "> [!" + typ + "]", strings.TrimSpace(rest), true
+}
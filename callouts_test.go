@@ -0,0 +1,67 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestApplyCallouts(t *testing.T) {
+	types, err := parseCalloutTypes(defaultCalloutTypes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"warning", "> Warning: back up first\n> then upgrade", "> [!WARNING]\n> back up first\n> then upgrade"},
+		{"prefix only", "> Note:\n> details", "> [!NOTE]\n> details"},
+		{"case-insensitive", "> tip: use -o -", "> [!TIP]\n> use -o -"},
+		{"unknown prefix", "> Aside: not a callout", "> Aside: not a callout"},
+		{"plain quote", "> Just a quote", "> Just a quote"},
+		{"later line", "> First\n> Warning: not first", "> First\n> Warning: not first"},
+		{"fenced", "```\n> Warning: code\n```", "```\n> Warning: code\n```"},
+	}
+	for _, tt := range tests {
+		if got := applyCallouts(tt.in, types); got != tt.want {
+			t.Errorf("%s: applyCallouts(%q) = %q, want %q", tt.name, tt.in, got, tt.want)
+		}
+	}
+	if in := "> Warning: x"; applyCallouts(in, nil) != in {
+		t.Error("applyCallouts changed text without -callouts")
+	}
+}
+
+func TestParseCalloutTypes(t *testing.T) {
+	types, err := parseCalloutTypes(" Heads up = caution , FYI=note")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if types["heads up"] != "CAUTION" || types["fyi"] != "NOTE" || len(types) != 2 {
+		t.Errorf("parseCalloutTypes = %v", types)
+	}
+	if _, err := parseCalloutTypes("Note"); err == nil {
+		t.Error("accepted a pair without '='")
+	}
+}
+
+func TestRenderCallouts(t *testing.T) {
+	types, err := parseCalloutTypes(defaultCalloutTypes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tweet, info := testArticle(Block{Type: "blockquote", Text: "Warning: this deletes data"})
+	got := RenderArticle(tweet, info, RenderOptions{Callouts: types})
+	if !strings.Contains(got, "\n> [!WARNING]\n> this deletes data\n") {
+		t.Errorf("article quote not turned into a callout:\n%s", got)
+	}
+	if got := RenderArticle(tweet, info, RenderOptions{}); !strings.Contains(got, "\n> Warning: this deletes data\n") {
+		t.Errorf("quote changed without -callouts:\n%s", got)
+	}
+
+	tweet = testThread("> Warning: spoilers ahead\n\nThe ending was great")[0]
+	if got := RenderTweet(tweet, RenderOptions{Callouts: types}); !strings.Contains(got, "\n> [!WARNING]\n> spoilers ahead\n\nThe ending") {
+		t.Errorf("tweet quote not turned into a callout:\n%s", got)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// CombineDocuments joins rendered documents into one Markdown file, in order.
+// Each document's frontmatter is demoted to a heading block, and documents are
+// separated by horizontal rules.
+func CombineDocuments(docs []string) string {
+	parts := make([]string, 0, len(docs))
+	for _, doc := range docs {
+		parts = append(parts, strings.TrimRight(demoteFrontmatter(doc), "\n")+"\n")
+	}
+	return strings.Join(parts, "\n---\n\n")
+}
+
+// appendDocument appends doc to an existing document after a horizontal rule,
+// demoting doc's frontmatter so the file keeps a single frontmatter block.
+// An empty existing document yields doc unchanged.
+func appendDocument(existing, doc string) string {
+	existing = strings.TrimRight(existing, "\n")
+	if strings.TrimSpace(existing) == "" {
+		return doc
+	}
+	return existing + "\n\n---\n\n" + strings.TrimRight(demoteFrontmatter(doc), "\n") + "\n"
+}
+
+// demoteFrontmatter replaces a leading YAML frontmatter block with a "##"
+// heading (title, author or source) followed by the fields as a list. Block
+// lists and maps, such as aliases, are written inline, comma-separated.
+func demoteFrontmatter(doc string) string {
+	if !strings.HasPrefix(doc, "---\n") {
+		return doc
+	}
+	end := strings.Index(doc[4:], "\n---\n")
+	if end == -1 {
+		return doc
+	}
+	header := doc[4 : 4+end]
+	body := strings.TrimLeft(doc[4+end+len("\n---\n"):], "\n")
+
+	lines := strings.Split(header, "\n")
+	// Compact frontmatter is a single flow mapping.
+	if strings.HasPrefix(header, "{") && strings.HasSuffix(header, "}") {
+		lines = splitFlowMapping(header[1 : len(header)-1])
+	}
+
+	var keys []string
+	values := make(map[string]string)
+	nested := "" // the key whose block list or map is being read
+	for _, line := range lines {
+		if item, ok := strings.CutPrefix(line, "  "); ok && nested != "" {
+			item = unquoteYAML(strings.TrimPrefix(item, "- "))
+			if values[nested] != "" {
+				item = values[nested] + ", " + item
+			}
+			values[nested] = item
+			continue
+		}
+		nested = ""
+		if key, ok := strings.CutSuffix(line, ":"); ok && !strings.Contains(key, ": ") {
+			keys = append(keys, key)
+			nested = key
+			continue
+		}
+		key, value, ok := strings.Cut(line, ": ")
+		if !ok {
+			continue
+		}
+		keys = append(keys, key)
+		values[key] = unquoteYAML(value)
+	}
+
+	heading := values["source"]
+	for _, key := range []string{"title", "author_name", "author"} {
+		if values[key] != "" {
+			heading = values[key]
+			break
+		}
+	}
+
+	var sb strings.Builder
+	if heading != "" {
+		sb.WriteString("## " + heading + "\n\n")
+	}
+	for _, key := range keys {
+		sb.WriteString("- " + key + ": " + values[key] + "\n")
+	}
+	sb.WriteString("\n")
+	sb.WriteString(body)
+	return sb.String()
+}
+
+// unquoteYAML removes the double quotes yamlEscape adds, if any.
+func unquoteYAML(value string) string {
+	if unquoted, err := strconv.Unquote(value); err == nil {
+		return unquoted
+	}
+	return value
+}
+
+// splitFlowMapping splits the inside of a YAML flow mapping into its
+// "key: value" entries, ignoring commas inside quotes and nested collections.
+func splitFlowMapping(s string) []string {
+	var entries []string
+	depth, start := 0, 0
+	inQuote, escaped := false, false
+	for i, r := range s {
+		switch {
+		case escaped:
+			escaped = false
+		case inQuote && r == '\\':
+			escaped = true
+		case r == '"':
+			inQuote = !inQuote
+		case inQuote:
+		case r == '[' || r == '{':
+			depth++
+		case r == ']' || r == '}':
+			depth--
+		case r == ',' && depth == 0:
+			entries = append(entries, strings.TrimSpace(s[start:i]))
+			start = i + 1
+		}
+	}
+	return append(entries, strings.TrimSpace(s[start:]))
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// renderFixture loads testdata/name and renders it as a tweet or article.
+func renderFixture(t *testing.T, name string, opts RenderOptions) string {
+	t.Helper()
+	tweet, err := LoadTweetFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tweet.Article != nil {
+		return RenderArticle(tweet, tweetURLInfo(tweet), opts)
+	}
+	return RenderTweet(tweet, opts)
+}
+
+func TestCombineDocuments(t *testing.T) {
+	docs := []string{
+		renderFixture(t, "tweet.json", RenderOptions{}),
+		renderFixture(t, "article.json", RenderOptions{}),
+	}
+	got := CombineDocuments(docs)
+
+	if !strings.HasPrefix(got, "## Alice\n\n- type: tweet\n- author: @alice\n") {
+		t.Errorf("first document's frontmatter not demoted:\n%s", got)
+	}
+	parts := strings.Split(got, "\n---\n\n")
+	if len(parts) != 2 {
+		t.Fatalf("got %d parts, want 2:\n%s", len(parts), got)
+	}
+	if !strings.Contains(parts[0], "Shipping x2md today.") {
+		t.Errorf("first part is not the tweet:\n%s", parts[0])
+	}
+	if !strings.HasPrefix(parts[1], "## Notes on Parsing\n\n- type: article\n") || !strings.Contains(parts[1], "## Why parsers\n") {
+		t.Errorf("second part is not the article:\n%s", parts[1])
+	}
+	if strings.Count(got, "\n---\n") != 1 {
+		t.Errorf("combined output keeps frontmatter delimiters:\n%s", got)
+	}
+}
+
+func TestDemoteFrontmatterLists(t *testing.T) {
+	doc := renderFixture(t, "tweet.json", RenderOptions{Aliases: true})
+	if !strings.Contains(doc, "aliases:\n  - ") {
+		t.Fatalf("fixture has no aliases list:\n%s", doc)
+	}
+	got := demoteFrontmatter(doc)
+	want := "- aliases: 1746000000000000001, https://fixupx.com/alice/status/1746000000000000001\n"
+	if !strings.Contains(got, want) {
+		t.Errorf("aliases not kept, want %q in:\n%s", want, got)
+	}
+	if strings.Contains(got, "\n  - ") {
+		t.Errorf("list items left as stray lines:\n%s", got)
+	}
+}
+
+func TestAppendDocument(t *testing.T) {
+	first := renderFixture(t, "tweet.json", RenderOptions{})
+	second := renderFixture(t, "article.json", RenderOptions{})
+
+	if got := appendDocument("\n", first); got != first {
+		t.Errorf("appending to an empty document changed it:\n%s", got)
+	}
+	got := appendDocument(first, second)
+	if !strings.HasPrefix(got, first[:strings.Index(first, "\n---\n")+5]) {
+		t.Errorf("existing frontmatter not kept:\n%s", got)
+	}
+	if !strings.Contains(got, "\n\n---\n\n## Notes on Parsing\n\n- type: article\n") {
+		t.Errorf("appended document not demoted after a rule:\n%s", got)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// defaultConfigPath returns ~/.config/x2md/config.toml.
+func defaultConfigPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config", "x2md", "config.toml")
+}
+
+// loadConfig reads a flat TOML file of flag defaults, e.g.
+//
+//	format = "bundle"
+//	no-stats = true
+//	rps = 1.5
+//
+// Keys are flag names. Tables, arrays and multi-line strings are not supported.
+func loadConfig(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, raw, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected key = value", path, lineNo)
+		}
+		key = strings.Trim(strings.TrimSpace(key), `"`)
+		value, err := parseConfigValue(strings.TrimSpace(raw))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, lineNo, err)
+		}
+		values[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// parseConfigValue decodes a TOML basic string, literal string, or bare value
+// (boolean, number), dropping any trailing comment.
+func parseConfigValue(raw string) (string, error) {
+	var value, rest string
+	switch {
+	case strings.HasPrefix(raw, `"`):
+		end := closingQuote(raw)
+		if end == -1 {
+			return "", fmt.Errorf("unterminated string %s", raw)
+		}
+		s, err := strconv.Unquote(raw[:end+1])
+		if err != nil {
+			return "", fmt.Errorf("invalid string %s: %w", raw[:end+1], err)
+		}
+		value, rest = s, raw[end+1:]
+	case strings.HasPrefix(raw, "'"):
+		end := strings.IndexByte(raw[1:], '\'')
+		if end == -1 {
+			return "", fmt.Errorf("unterminated string %s", raw)
+		}
+		value, rest = raw[1:end+1], raw[end+2:]
+	default:
+		if idx := strings.Index(raw, "#"); idx != -1 {
+			raw = strings.TrimSpace(raw[:idx])
+		}
+		return raw, nil
+	}
+	if rest = strings.TrimSpace(rest); rest != "" && !strings.HasPrefix(rest, "#") {
+		return "", fmt.Errorf("unexpected %q after string", rest)
+	}
+	return value, nil
+}
+
+// closingQuote returns the index of the unescaped quote that ends the basic
+// string at the start of raw, or -1 if it is unterminated.
+func closingQuote(raw string) int {
+	for i := 1; i < len(raw); i++ {
+		switch raw[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+// applyConfig sets flags from values unless they were given on the command line.
+func applyConfig(fs *flag.FlagSet, values map[string]string) error {
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+
+	for key, value := range values {
+		if explicit[key] || key == "config" || key == "no-config" {
+			continue
+		}
+		if fs.Lookup(key) == nil {
+			return fmt.Errorf("unknown config key %q", key)
+		}
+		if err := fs.Set(key, value); err != nil {
+			return fmt.Errorf("config key %q: %w", key, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseConfigValue(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    string
+		wantErr bool
+	}{
+		{`"bundle"`, "bundle", false},
+		{`"a" # "b"`, "a", false},
+		{`"say \"hi\"" # comment`, `say "hi"`, false},
+		{`"back\\slash"`, `back\slash`, false},
+		{`'C:\path' # 'x'`, `C:\path`, false},
+		{`true # enabled`, "true", false},
+		{`1.5`, "1.5", false},
+		{`"a" "b"`, "", true},
+		{`"unterminated`, "", true},
+		{`"escaped end\"`, "", true},
+		{`'unterminated`, "", true},
+	}
+	for _, tt := range tests {
+		got, err := parseConfigValue(tt.raw)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseConfigValue(%q) = %q, %v; want %q, error %v", tt.raw, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestApplyConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	config := "# defaults\nformat = \"bundle\" # single file\nno-stats = true\ndate-format = '2006/01/02'\n"
+	if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	values, err := loadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fs := flag.NewFlagSet("x2md", flag.ContinueOnError)
+	format := fs.String("format", "markdown", "")
+	noStats := fs.Bool("no-stats", false, "")
+	dateFormat := fs.String("date-format", "", "")
+	if err := fs.Parse([]string{"-format", "org"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyConfig(fs, values); err != nil {
+		t.Fatal(err)
+	}
+
+	if *format != "org" {
+		t.Errorf("format = %q, want the command-line value org", *format)
+	}
+	if !*noStats {
+		t.Error("no-stats from the config file was not applied")
+	}
+	if *dateFormat != "2006/01/02" {
+		t.Errorf("date-format = %q, want the config file value", *dateFormat)
+	}
+
+	if err := applyConfig(fs, map[string]string{"no-such-flag": "1"}); err == nil {
+		t.Error("unknown config key accepted")
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+// Content types.
+const (
+	ContentTweet   = "tweet"
+	ContentThread  = "thread"
+	ContentArticle = "article"
+)
+
+// Content is a fetched tweet, thread or article ready for rendering.
+type Content struct {
+	Type   string   // ContentTweet, ContentThread or ContentArticle
+	Tweet  *Tweet   // the tweet or article; the first tweet of a thread
+	Tweets []*Tweet // thread tweets, oldest first; just Tweet otherwise
+	Info   URLInfo
+}
+
+// fetchOptions controls how content is fetched before rendering.
+type fetchOptions struct {
+	Thread bool
+	// MaxDepth bounds how many tweets a thread fetch follows; 0 uses the default.
+	MaxDepth     int
+	ExpandQuotes bool
+	// IncludeParent fetches the tweet a single tweet replies to, for context.
+	IncludeParent bool
+	// Translate is the target language for single tweets; empty keeps the original.
+	Translate string
+	// Source selects the service posts are fetched from: SourceFxTwitter
+	// (also used when empty), SourceBluesky or SourceMastodon. It must match
+	// the URL's.
+	Source string
+}
+
+// newTweetContent wraps a single tweet, detecting an attached article.
+func newTweetContent(tweet *Tweet, info URLInfo) *Content {
+	c := &Content{Type: ContentTweet, Tweet: tweet, Tweets: []*Tweet{tweet}, Info: info}
+	if tweet.Article != nil {
+		c.Type = ContentArticle
+	}
+	return c
+}
+
+// FetchContent fetches the tweet, thread or article behind rawURL. A thread
+// fetch cancelled through ctx returns the partial thread alongside the error.
+func FetchContent(ctx context.Context, rawURL string, fo fetchOptions) (*Content, error) {
+	info, err := ParseURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	source := fo.Source
+	if source == "" {
+		source = SourceFxTwitter
+	}
+	if info.Source != source {
+		return nil, fmt.Errorf("%s 链接需配合 -source %s", rawURL, info.Source)
+	}
+	f, err := newFetcher(info)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.Type == URLTypeArticle {
+		tweet, err := FetchArticle(ctx, info.ScreenName, info.ID)
+		if err != nil {
+			return nil, fmt.Errorf("获取文章失败: %w", err)
+		}
+		return &Content{Type: ContentArticle, Tweet: tweet, Tweets: []*Tweet{tweet}, Info: info}, nil
+	}
+
+	if fo.Thread {
+		tweets, err := FetchThread(ctx, f, info.ScreenName, info.ID, fo.MaxDepth)
+		if err != nil && len(tweets) > 0 {
+			// Interrupted mid-thread: hand back the partial thread with the error.
+			return &Content{Type: ContentThread, Tweet: tweets[0], Tweets: tweets, Info: info}, err
+		}
+		if err != nil {
+			return nil, fmt.Errorf("获取线程失败: %w", err)
+		}
+		if fo.ExpandQuotes {
+			ExpandQuotes(ctx, f, tweets)
+		}
+		return &Content{Type: ContentThread, Tweet: tweets[0], Tweets: tweets, Info: info}, nil
+	}
+
+	var tweet *Tweet
+	if fo.Translate != "" {
+		tweet, err = FetchTweetTranslated(ctx, info.ScreenName, info.ID, fo.Translate)
+	} else {
+		tweet, err = f.FetchTweet(ctx, info.ScreenName, info.ID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("获取推文失败: %w", err)
+	}
+	if fo.Translate != "" && !hasTranslation(tweet) {
+		warnf("推文 %s 无法翻译为 %s，使用原文", info.ID, fo.Translate)
+	}
+	if fo.IncludeParent && tweet.ReplyingToStatus != "" {
+		screenName := anonymousScreenName
+		if handles := replyHandles(tweet.ReplyingTo); len(handles) > 0 {
+			screenName = handles[0]
+		}
+		parent, err := f.FetchTweet(ctx, screenName, tweet.ReplyingToStatus)
+		if err != nil {
+			warnf("获取被回复的推文失败 %s: %v", tweet.ReplyingToStatus, err)
+		} else {
+			tweet.Parent = parent
+		}
+	}
+	if fo.ExpandQuotes {
+		ExpandQuotes(ctx, f, []*Tweet{tweet})
+	}
+	return newTweetContent(tweet, info), nil
+}
+
+// Render renders the content with the built-in Markdown renderers.
+func (c *Content) Render(opts RenderOptions) string {
+	switch c.Type {
+	case ContentThread:
+		return RenderThread(c.Tweets, opts)
+	case ContentArticle:
+		return RenderArticle(c.Tweet, c.Info, opts)
+	default:
+		return RenderTweet(c.Tweet, opts)
+	}
+}
+
+// Write renders the content like Render, writing to w as it goes.
+func (c *Content) Write(w io.Writer, opts RenderOptions) error {
+	switch c.Type {
+	case ContentThread:
+		return WriteThread(w, c.Tweets, opts)
+	case ContentArticle:
+		return WriteArticle(w, c.Tweet, c.Info, opts)
+	default:
+		return WriteTweet(w, c.Tweet, opts)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestFetchContentTranslate(t *testing.T) {
+	translated := &Tweet{
+		ID: "1", Text: "Bonjour le monde", Lang: "fr",
+		Author:      &Author{Name: "Alice", ScreenName: "alice"},
+		Translation: &Translation{Text: "Hello world", SourceLang: "fr", TargetLang: "en"},
+	}
+	untranslated := &Tweet{ID: "2", Text: "Hola", Lang: "es", Author: translated.Author}
+	stub := stubFxTwitter(t, map[string]*Tweet{
+		"/alice/status/1/en": translated,
+		"/alice/status/2/en": untranslated,
+	})
+
+	c, err := FetchContent(context.Background(), "https://x.com/alice/status/1", fetchOptions{Translate: "en"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stub.paths) != 1 || stub.paths[0] != "/alice/status/1/en" {
+		t.Errorf("requested %v, want the translation endpoint", stub.paths)
+	}
+	got := c.Render(RenderOptions{KeepOriginal: true})
+	for _, want := range []string{"lang: fr\n", "translated_to: en\n", "\nHello world\n\n> Bonjour le monde\n"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("translated tweet missing %q:\n%s", want, got)
+		}
+	}
+	if got := c.Render(RenderOptions{}); strings.Contains(got, "> Bonjour") {
+		t.Errorf("original kept without -keep-original:\n%s", got)
+	}
+
+	// A tweet the backend could not translate falls back to the original.
+	c, err = FetchContent(context.Background(), "https://x.com/alice/status/2", fetchOptions{Translate: "en"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = c.Render(RenderOptions{KeepOriginal: true})
+	if !strings.Contains(got, "\nHola\n") || strings.Contains(got, "translated_to") || !strings.Contains(got, "lang: es\n") {
+		t.Errorf("untranslated fallback:\n%s", got)
+	}
+}
+
+func TestFetchContentIncludeParent(t *testing.T) {
+	alice := &Author{Name: "Alice", ScreenName: "alice"}
+	bob := &Author{Name: "Bob", ScreenName: "bob"}
+	root := &Tweet{ID: "1", Text: "Root question", Author: alice}
+	parent := &Tweet{ID: "2", Text: "Parent point", Author: alice, ReplyingTo: "bob", ReplyingToStatus: "1"}
+	child := &Tweet{ID: "3", Text: "My answer", Author: bob, ReplyingTo: "alice", ReplyingToStatus: "2"}
+	stub := stubFxTwitter(t, map[string]*Tweet{
+		"/bob/status/3":   child,
+		"/alice/status/2": parent,
+		"/bob/status/1":   root,
+	})
+
+	c, err := FetchContent(context.Background(), "https://x.com/bob/status/3", fetchOptions{IncludeParent: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Only the immediate parent is fetched, not the rest of the chain.
+	if want := []string{"/bob/status/3", "/alice/status/2"}; strings.Join(stub.paths, " ") != strings.Join(want, " ") {
+		t.Errorf("requested %v, want %v", stub.paths, want)
+	}
+	got := c.Render(RenderOptions{})
+	if !strings.Contains(got, "\n> Parent point\n> — @alice [🔗](https://x.com/alice/status/2)\n\nMy answer\n") {
+		t.Errorf("parent context missing:\n%s", got)
+	}
+	if strings.Contains(got, "Root question") {
+		t.Errorf("grandparent rendered:\n%s", got)
+	}
+
+	// A tweet that is not a reply renders without a context block.
+	stub.paths = nil
+	c, err = FetchContent(context.Background(), "https://x.com/bob/status/1", fetchOptions{IncludeParent: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stub.paths) != 1 || strings.Contains(c.Render(RenderOptions{}), "> —") {
+		t.Errorf("non-reply fetched %v", stub.paths)
+	}
+}
@@ -2,14 +2,37 @@ package main
 
 import (
 	"fmt"
+	"io"
+	"regexp"
 	"sort"
 	"strings"
+	"unicode"
 )
 
+// draftOptions controls optional HTML in DraftJSToMarkdown output.
+type draftOptions struct {
+	// PreserveColor keeps colored text in an HTML span with its color.
+	PreserveColor bool
+	// Figure renders images as HTML <figure> blocks.
+	Figure bool
+}
+
 // DraftJSToMarkdown converts Draft.js article content to Markdown.
-func DraftJSToMarkdown(content *ArticleContent, mediaEntities []ArticleMedia) string {
+// Blocks are separated by one blank line. A run of empty paragraphs between
+// two blocks is a deliberate section break and collapses to a single extra
+// blank line; empty paragraphs at the start or end are dropped.
+func DraftJSToMarkdown(content *ArticleContent, mediaEntities []ArticleMedia, dopts draftOptions) string {
+	var sb strings.Builder
+	writeDraftJS(&sb, content, mediaEntities, dopts, nil)
+	return sb.String()
+}
+
+// writeDraftJS is DraftJSToMarkdown writing each block to w once the next
+// block shows it is complete. post, when non-nil, is applied to every block
+// before it is written. It reports whether anything was written.
+func writeDraftJS(w io.StringWriter, content *ArticleContent, mediaEntities []ArticleMedia, dopts draftOptions, post func(string) string) bool {
 	if content == nil || len(content.Blocks) == 0 {
-		return ""
+		return false
 	}
 
 	// Build media lookup: mediaId -> image URL
@@ -21,100 +44,191 @@ func DraftJSToMarkdown(content *ArticleContent, mediaEntities []ArticleMedia) st
 		entityLookup[int(item.Key)] = item.Value
 	}
 
-	var parts []string
+	blocks := &blockWriter{w: w, post: post}
 	olCounter := 0 // track ordered list numbering
 
-	for _, block := range content.Blocks {
+	for i, block := range content.Blocks {
 		switch block.Type {
 		case "header-one":
 			olCounter = 0
-			text := applyInlineStyles(block.Text, block.InlineStyleRanges)
-			parts = append(parts, "# "+text)
+			text := renderBlockText(block, entityLookup, dopts.PreserveColor)
+			blocks.add("# " + text)
 
 		case "header-two":
 			olCounter = 0
-			text := applyInlineStyles(block.Text, block.InlineStyleRanges)
-			parts = append(parts, "## "+text)
+			text := renderBlockText(block, entityLookup, dopts.PreserveColor)
+			blocks.add("## " + text)
 
 		case "header-three":
 			olCounter = 0
-			text := applyInlineStyles(block.Text, block.InlineStyleRanges)
-			parts = append(parts, "### "+text)
+			text := renderBlockText(block, entityLookup, dopts.PreserveColor)
+			blocks.add("### " + text)
 
 		case "header-four":
 			olCounter = 0
-			text := applyInlineStyles(block.Text, block.InlineStyleRanges)
-			parts = append(parts, "#### "+text)
+			text := renderBlockText(block, entityLookup, dopts.PreserveColor)
+			blocks.add("#### " + text)
 
 		case "header-five":
 			olCounter = 0
-			text := applyInlineStyles(block.Text, block.InlineStyleRanges)
-			parts = append(parts, "##### "+text)
+			text := renderBlockText(block, entityLookup, dopts.PreserveColor)
+			blocks.add("##### " + text)
 
 		case "header-six":
 			olCounter = 0
-			text := applyInlineStyles(block.Text, block.InlineStyleRanges)
-			parts = append(parts, "###### "+text)
+			text := renderBlockText(block, entityLookup, dopts.PreserveColor)
+			blocks.add("###### " + text)
 
 		case "blockquote":
 			olCounter = 0
-			text := applyInlineStyles(block.Text, block.InlineStyleRanges)
+			text := renderBlockText(block, entityLookup, dopts.PreserveColor)
+			// Depth nests the quote: ">" for depth 0, ">>" for depth 1, ...
+			prefix := strings.Repeat(">", block.Depth+1) + " "
 			lines := strings.Split(text, "\n")
 			var quoted []string
 			for _, line := range lines {
-				quoted = append(quoted, "> "+line)
+				quoted = append(quoted, prefix+line)
+			}
+			// Consecutive blockquotes form one quoted region, separated by a
+			// blank quote line.
+			if i > 0 && content.Blocks[i-1].Type == "blockquote" && blocks.pending {
+				blocks.last += "\n>\n" + strings.Join(quoted, "\n")
+				continue
 			}
-			parts = append(parts, strings.Join(quoted, "\n"))
+			blocks.add(strings.Join(quoted, "\n"))
 
 		case "unordered-list-item":
 			olCounter = 0
-			text := applyInlineStyles(block.Text, block.InlineStyleRanges)
-			parts = append(parts, "- "+text)
+			text := renderBlockText(block, entityLookup, dopts.PreserveColor)
+			blocks.add("- " + text)
 
 		case "ordered-list-item":
 			olCounter++
-			text := applyInlineStyles(block.Text, block.InlineStyleRanges)
-			parts = append(parts, fmt.Sprintf("%d. %s", olCounter, text))
+			text := renderBlockText(block, entityLookup, dopts.PreserveColor)
+			blocks.add(fmt.Sprintf("%d. %s", olCounter, text))
 
 		case "code-block":
 			olCounter = 0
-			parts = append(parts, "```\n"+block.Text+"\n```")
+			// Draft.js stores each line of a multi-line snippet as its own
+			// code-block; merge consecutive ones into a single fence.
+			if i > 0 && content.Blocks[i-1].Type == "code-block" && blocks.pending {
+				blocks.last = strings.TrimSuffix(blocks.last, "\n```") + "\n" + block.Text + "\n```"
+				continue
+			}
+			blocks.add("```\n" + block.Text + "\n```")
 
 		case "atomic":
 			olCounter = 0
 			// Atomic blocks contain media or dividers referenced by entityRanges
-			rendered := renderAtomicBlock(block, entityLookup, mediaLookup)
+			rendered := renderAtomicBlock(block, entityLookup, mediaLookup, dopts.Figure)
 			if rendered != "" {
-				parts = append(parts, rendered)
+				blocks.add(rendered)
 			}
 
 		default: // "unstyled" and others
 			olCounter = 0
+			// Blocks are already separated by one blank line, so empty
+			// blocks, however many in a row, add nothing to it.
 			if strings.TrimSpace(block.Text) == "" {
-				parts = append(parts, "")
-			} else {
-				text := applyInlineStyles(block.Text, block.InlineStyleRanges)
-				parts = append(parts, text)
+				continue
 			}
+			text := renderBlockText(block, entityLookup, dopts.PreserveColor)
+			blocks.add(text)
 		}
 	}
 
-	return strings.TrimSpace(strings.Join(parts, "\n\n"))
+	blocks.close()
+	return blocks.written
+}
+
+// blockWriter writes rendered blocks separated by a blank line. The last
+// block is held back until the next one arrives so that consecutive code and
+// quote blocks can still be merged into it; output is trimmed at both ends.
+type blockWriter struct {
+	w    io.StringWriter
+	post func(string) string
+
+	last    string // the block held back, when pending
+	pending bool
+	written bool
 }
 
-// buildMediaLookup creates a map from mediaId to image URL.
-func buildMediaLookup(entities []ArticleMedia) map[string]string {
-	lookup := make(map[string]string)
+// add writes out the held-back block and holds back part.
+func (b *blockWriter) add(part string) {
+	b.flush(false)
+	b.last, b.pending = part, true
+}
+
+// close writes out the held-back block as the last one.
+func (b *blockWriter) close() {
+	b.flush(true)
+}
+
+func (b *blockWriter) flush(final bool) {
+	if !b.pending {
+		return
+	}
+	part := b.last
+	if b.post != nil {
+		part = b.post(part)
+	}
+	if b.written {
+		b.w.WriteString("\n\n")
+	} else {
+		part = strings.TrimLeftFunc(part, unicode.IsSpace)
+	}
+	if final {
+		part = strings.TrimRightFunc(part, unicode.IsSpace)
+	}
+	b.w.WriteString(part)
+	b.written, b.pending = true, false
+}
+
+// articleImage is an article image resolved from its media entity.
+type articleImage struct {
+	URL string
+	Alt string
+}
+
+// buildMediaLookup creates a map from media ID to image. Each image is also
+// indexed by its media_key and id, since references do not always carry the
+// media ID; a media ID always wins over another entity's key.
+func buildMediaLookup(entities []ArticleMedia) map[string]articleImage {
+	lookup := make(map[string]articleImage)
 	for _, e := range entities {
-		if e.MediaInfo != nil && e.MediaInfo.OriginalImgURL != "" {
-			lookup[e.MediaID] = e.MediaInfo.OriginalImgURL
+		if e.MediaInfo != nil && e.MediaInfo.OriginalImgURL != "" && e.MediaID != "" {
+			lookup[e.MediaID] = articleImage{URL: e.MediaInfo.OriginalImgURL, Alt: e.MediaInfo.AltText}
+		}
+	}
+	for _, e := range entities {
+		if e.MediaInfo == nil || e.MediaInfo.OriginalImgURL == "" {
+			continue
+		}
+		img := articleImage{URL: e.MediaInfo.OriginalImgURL, Alt: e.MediaInfo.AltText}
+		for _, key := range []string{e.MediaKey, e.ID} {
+			if _, taken := lookup[key]; key != "" && !taken {
+				lookup[key] = img
+			}
 		}
 	}
 	return lookup
 }
 
+// lookupMedia resolves a media reference by its mediaId, falling back to its
+// localMediaId.
+func lookupMedia(ref EntityMediaRef, mediaLookup map[string]articleImage) (articleImage, bool) {
+	if img, ok := mediaLookup[ref.MediaID]; ok && ref.MediaID != "" {
+		return img, true
+	}
+	if ref.LocalMediaID == "" {
+		return articleImage{}, false
+	}
+	img, ok := mediaLookup[ref.LocalMediaID]
+	return img, ok
+}
+
 // renderAtomicBlock renders an atomic block (media, divider).
-func renderAtomicBlock(block Block, entityLookup map[int]EntityValue, mediaLookup map[string]string) string {
+func renderAtomicBlock(block Block, entityLookup map[int]EntityValue, mediaLookup map[string]articleImage, figure bool) string {
 	for _, er := range block.EntityRanges {
 		entity, ok := entityLookup[er.Key]
 		if !ok {
@@ -123,7 +237,7 @@ func renderAtomicBlock(block Block, entityLookup map[int]EntityValue, mediaLooku
 
 		switch entity.Type {
 		case "MEDIA":
-			return renderMediaEntity(entity, mediaLookup)
+			return renderMediaEntity(entity, mediaLookup, figure)
 		case "DIVIDER":
 			return "---"
 		}
@@ -131,48 +245,126 @@ func renderAtomicBlock(block Block, entityLookup map[int]EntityValue, mediaLooku
 	return ""
 }
 
-// renderMediaEntity renders a MEDIA entity as Markdown image(s).
-func renderMediaEntity(entity EntityValue, mediaLookup map[string]string) string {
+// renderMediaEntity renders a MEDIA entity as Markdown image(s). An image's
+// alt text falls back to the entity caption, which is also shown in italics
+// beneath the images. With figure, each image is an HTML figure captioned
+// with the entity caption or else its own alt text.
+func renderMediaEntity(entity EntityValue, mediaLookup map[string]articleImage, figure bool) string {
+	caption := singleLine(entity.Data.Caption)
 	var images []string
 	for _, ref := range entity.Data.MediaItems {
-		if url, ok := mediaLookup[ref.MediaID]; ok {
-			images = append(images, fmt.Sprintf("![image](%s)", url))
+		img, ok := lookupMedia(ref, mediaLookup)
+		if !ok {
+			continue
 		}
+		alt := singleLine(img.Alt)
+		if figure {
+			figCaption := caption
+			if figCaption == "" {
+				figCaption = alt
+			}
+			images = append(images, figureHTML(img.URL, alt, figCaption))
+			continue
+		}
+		if alt == "" {
+			alt = caption
+		}
+		if alt == "" {
+			alt = "image"
+		}
+		images = append(images, fmt.Sprintf("![%s](%s)", alt, img.URL))
+	}
+	if len(images) == 0 {
+		return ""
+	}
+	md := strings.Join(images, "\n\n")
+	if caption != "" && !figure {
+		md += "\n*" + caption + "*"
+	}
+	return md
+}
+
+// singleLine collapses whitespace, including newlines, so text fits in image
+// alt text or a one-line caption.
+func singleLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// linkRange is a LINK entity resolved to its URL.
+type linkRange struct {
+	Offset int
+	Length int
+	URL    string
+}
+
+// renderBlockText applies inline styles and LINK entities to a block's text.
+func renderBlockText(block Block, entityLookup map[int]EntityValue, preserveColor bool) string {
+	var links []linkRange
+	for _, er := range block.EntityRanges {
+		entity, ok := entityLookup[er.Key]
+		if !ok || entity.Type != "LINK" || entity.Data.URL == "" {
+			continue
+		}
+		links = append(links, linkRange{Offset: er.Offset, Length: er.Length, URL: entity.Data.URL})
 	}
-	return strings.Join(images, "\n\n")
+	return applyInlineStyles(block.Text, block.InlineStyleRanges, links, preserveColor)
 }
 
 // styleRange represents a style boundary event for inline style processing.
 type styleRange struct {
-	pos   int
-	style string
-	start bool
+	pos    int
+	marker string
+	start  bool
+	link   bool
 }
 
-// applyInlineStyles applies Bold, Italic, Code, Underline styles to text.
-func applyInlineStyles(text string, styles []InlineStyleRange) string {
-	if len(styles) == 0 {
+// applyInlineStyles applies Bold, Italic, Code, Highlight and (with
+// preserveColor) color styles and links to text. Underline has no Markdown
+// form and is dropped. Offsets are in UTF-16 code units, as counted by X's
+// Draft.js editor.
+func applyInlineStyles(text string, styles []InlineStyleRange, links []linkRange, preserveColor bool) string {
+	if len(styles) == 0 && len(links) == 0 {
 		return text
 	}
 
 	runes := []rune(text)
 	n := len(runes)
 
-	// Collect all style boundaries
+	// Collect all style boundaries. Offsets are clamped to the block text, and
+	// ranges left empty by clamping are dropped so that every opened marker is
+	// closed within the block.
 	var events []styleRange
 	for _, s := range styles {
-		end := s.Offset + s.Length
-		if end > n {
-			end = n
+		start := utf16OffsetToRuneIndex(runes, s.Offset)
+		end := utf16OffsetToRuneIndex(runes, s.Offset+s.Length)
+		openMarker := styleMarker(s.Style)
+		closeMarker := openMarker
+		if color, ok := styleColor(s.Style); ok && preserveColor {
+			openMarker, closeMarker = `<span style="color:`+color+`">`, "</span>"
+		}
+		if openMarker == "" || end <= start {
+			continue
+		}
+		events = append(events,
+			styleRange{pos: start, marker: openMarker, start: true},
+			styleRange{pos: end, marker: closeMarker, start: false},
+		)
+	}
+	for _, l := range links {
+		start := utf16OffsetToRuneIndex(runes, l.Offset)
+		end := utf16OffsetToRuneIndex(runes, l.Offset+l.Length)
+		if end <= start {
+			continue
 		}
 		events = append(events,
-			styleRange{pos: s.Offset, style: s.Style, start: true},
-			styleRange{pos: end, style: s.Style, start: false},
+			styleRange{pos: start, marker: "[", start: true, link: true},
+			styleRange{pos: end, marker: "](" + l.URL + ")", start: false, link: true},
 		)
 	}
 
-	// Sort events: process ends before starts at the same position
-	sort.Slice(events, func(i, j int) bool {
+	// Sort events: process ends before starts at the same position.
+	// Links open before and close after styles so styles nest inside them.
+	sort.SliceStable(events, func(i, j int) bool {
 		if events[i].pos != events[j].pos {
 			return events[i].pos < events[j].pos
 		}
@@ -180,6 +372,9 @@ func applyInlineStyles(text string, styles []InlineStyleRange) string {
 		if events[i].start != events[j].start {
 			return !events[i].start
 		}
+		if events[i].link != events[j].link {
+			return events[i].link == events[i].start
+		}
 		return false
 	})
 
@@ -190,8 +385,7 @@ func applyInlineStyles(text string, styles []InlineStyleRange) string {
 	for pos := 0; pos <= n; pos++ {
 		// Process all events at this position (ends first, then starts)
 		for eventIdx < len(events) && events[eventIdx].pos == pos {
-			marker := styleMarker(events[eventIdx].style)
-			result.WriteString(marker)
+			result.WriteString(events[eventIdx].marker)
 			eventIdx++
 		}
 		if pos < n {
@@ -211,7 +405,31 @@ func styleMarker(style string) string {
 		return "*"
 	case "Code", "CODE":
 		return "`"
+	case "Highlight", "HIGHLIGHT":
+		return "=="
 	default:
 		return ""
 	}
 }
+
+// styleColor extracts the CSS color from a "color-..." style name, such as
+// "color-red" or "color-1DA1F2". Bare hex values get a leading "#". Values
+// that are not a plain color name or hex code are rejected.
+func styleColor(style string) (string, bool) {
+	if len(style) < len("color-") || !strings.EqualFold(style[:len("color-")], "color-") {
+		return "", false
+	}
+	color := strings.TrimPrefix(style[len("color-"):], "#")
+	if !colorValueRe.MatchString(color) {
+		return "", false
+	}
+	if hexColorRe.MatchString(color) {
+		color = "#" + color
+	}
+	return color, true
+}
+
+var (
+	colorValueRe = regexp.MustCompile(`^[A-Za-z0-9]+$`)
+	hexColorRe   = regexp.MustCompile(`^(?:[0-9A-Fa-f]{3}|[0-9A-Fa-f]{6}|[0-9A-Fa-f]{8})$`)
+)
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// draft converts blocks with the given entity map using default options.
+func draft(entities []EntityMapItem, blocks ...Block) string {
+	return DraftJSToMarkdown(&ArticleContent{Blocks: blocks, EntityMap: entities}, nil, draftOptions{})
+}
+
+func TestDraftJSEmojiBeforeBold(t *testing.T) {
+	// "😀" is two UTF-16 code units, so "bold" starts at offset 3.
+	block := Block{
+		Type:              "unstyled",
+		Text:              "😀 bold text",
+		InlineStyleRanges: []InlineStyleRange{{Offset: 3, Length: 4, Style: "Bold"}},
+	}
+	if got, want := draft(nil, block), "😀 **bold** text"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestDraftJSEmojiBeforeLink(t *testing.T) {
+	block := Block{
+		Type:         "unstyled",
+		Text:         "🧵🧵 see docs",
+		EntityRanges: []EntityRange{{Key: 0, Offset: 9, Length: 4}},
+	}
+	entities := []EntityMapItem{{Key: 0, Value: EntityValue{Type: "LINK", Data: EntityData{URL: "https://example.com"}}}}
+	if got, want := draft(entities, block), "🧵🧵 see [docs](https://example.com)"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestDraftJSEmptyBlocks(t *testing.T) {
+	para := func(text string) Block { return Block{Type: "unstyled", Text: text} }
+	tests := []struct {
+		name   string
+		blocks []Block
+		want   string
+	}{
+		{"adjacent", []Block{para("A"), para("B")}, "A\n\nB"},
+		{"one empty", []Block{para("A"), para(""), para("B")}, "A\n\nB"},
+		{"two empty", []Block{para("A"), para(""), para(" "), para("B")}, "A\n\nB"},
+		{"leading and trailing", []Block{para(""), para("A"), para(""), para("")}, "A"},
+		{"before heading", []Block{para("A"), para(""), para(""), {Type: "header-two", Text: "H"}}, "A\n\n## H"},
+	}
+	for _, tt := range tests {
+		if got := draft(nil, tt.blocks...); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDraftJSConsecutiveCodeBlocks(t *testing.T) {
+	code := func(text string) Block { return Block{Type: "code-block", Text: text} }
+	got := draft(nil,
+		Block{Type: "unstyled", Text: "Example:"},
+		code("func main() {"),
+		code(`	fmt.Println("hi")`),
+		code("}"),
+		Block{Type: "unstyled", Text: "Done."},
+	)
+	want := "Example:\n\n```\nfunc main() {\n\tfmt.Println(\"hi\")\n}\n```\n\nDone."
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// A paragraph between code blocks keeps them as separate fences.
+	got = draft(nil, code("a"), Block{Type: "unstyled", Text: "then"}, code("b"))
+	if want := "```\na\n```\n\nthen\n\n```\nb\n```"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestDraftJSBlockquotes(t *testing.T) {
+	quote := func(text string, depth int) Block { return Block{Type: "blockquote", Text: text, Depth: depth} }
+	tests := []struct {
+		name   string
+		blocks []Block
+		want   string
+	}{
+		{"consecutive", []Block{quote("First", 0), quote("Second", 0)}, "> First\n>\n> Second"},
+		{"nested", []Block{quote("Outer", 0), quote("Inner", 1)}, "> Outer\n>\n>> Inner"},
+		{"multi-line", []Block{quote("a\nb", 0)}, "> a\n> b"},
+		{
+			"separated by text",
+			[]Block{quote("One", 0), {Type: "unstyled", Text: "between"}, quote("Two", 0)},
+			"> One\n\nbetween\n\n> Two",
+		},
+	}
+	for _, tt := range tests {
+		if got := draft(nil, tt.blocks...); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDraftJSMediaCaption(t *testing.T) {
+	media := func(id, alt string) ArticleMedia {
+		return ArticleMedia{MediaID: id, MediaInfo: &MediaInfo{OriginalImgURL: "https://pbs.twimg.com/media/" + id + ".jpg", AltText: alt}}
+	}
+	entity := func(caption string, ids ...string) []EntityMapItem {
+		var refs []EntityMediaRef
+		for _, id := range ids {
+			refs = append(refs, EntityMediaRef{MediaID: id})
+		}
+		return []EntityMapItem{{Key: 0, Value: EntityValue{Type: "MEDIA", Data: EntityData{Caption: caption, MediaItems: refs}}}}
+	}
+	atomic := Block{Type: "atomic", Text: " ", EntityRanges: []EntityRange{{Key: 0, Offset: 0, Length: 1}}}
+	entities := []ArticleMedia{media("1", ""), media("2", "A chart")}
+
+	tests := []struct {
+		name     string
+		entities []EntityMapItem
+		want     string
+	}{
+		{"caption as alt", entity("Figure 1", "1"), "![Figure 1](https://pbs.twimg.com/media/1.jpg)\n*Figure 1*"},
+		{"alt and caption", entity("Sales by\nquarter", "2"), "![A chart](https://pbs.twimg.com/media/2.jpg)\n*Sales by quarter*"},
+		{"no caption", entity("", "1"), "![image](https://pbs.twimg.com/media/1.jpg)"},
+		{
+			"gallery",
+			entity("Before and after", "1", "2"),
+			"![Before and after](https://pbs.twimg.com/media/1.jpg)\n\n![A chart](https://pbs.twimg.com/media/2.jpg)\n*Before and after*",
+		},
+	}
+	for _, tt := range tests {
+		content := &ArticleContent{Blocks: []Block{atomic}, EntityMap: tt.entities}
+		if got := DraftJSToMarkdown(content, entities, draftOptions{}); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	// With -figure the caption becomes the figcaption.
+	content := &ArticleContent{Blocks: []Block{atomic}, EntityMap: entity("Figure 1", "2")}
+	if got := DraftJSToMarkdown(content, entities, draftOptions{Figure: true}); !strings.Contains(got, "<figcaption>Figure 1</figcaption>") {
+		t.Errorf("figure caption missing: %q", got)
+	}
+}
+
+func TestDraftJSMediaKeyLookup(t *testing.T) {
+	entities := []ArticleMedia{
+		{MediaKey: "3_111", MediaInfo: &MediaInfo{OriginalImgURL: "https://pbs.twimg.com/media/by-key.jpg"}},
+		{ID: "222", MediaInfo: &MediaInfo{OriginalImgURL: "https://pbs.twimg.com/media/by-id.jpg"}},
+		{MediaID: "333", MediaKey: "222", MediaInfo: &MediaInfo{OriginalImgURL: "https://pbs.twimg.com/media/by-media-id.jpg"}},
+	}
+	tests := []struct {
+		name string
+		ref  EntityMediaRef
+		want string
+	}{
+		{"media_key via mediaId", EntityMediaRef{MediaID: "3_111"}, "by-key.jpg"},
+		{"media_key via localMediaId", EntityMediaRef{LocalMediaID: "3_111"}, "by-key.jpg"},
+		{"unknown mediaId falls back", EntityMediaRef{MediaID: "999", LocalMediaID: "3_111"}, "by-key.jpg"},
+		// A media ID wins over another entity's media_key.
+		{"media ID first", EntityMediaRef{MediaID: "333"}, "by-media-id.jpg"},
+		{"id beats later key", EntityMediaRef{MediaID: "222"}, "by-id.jpg"},
+	}
+	for _, tt := range tests {
+		content := &ArticleContent{
+			Blocks: []Block{{Type: "atomic", Text: " ", EntityRanges: []EntityRange{{Key: 0, Offset: 0, Length: 1}}}},
+			EntityMap: []EntityMapItem{{Key: 0, Value: EntityValue{
+				Type: "MEDIA",
+				Data: EntityData{MediaItems: []EntityMediaRef{tt.ref}},
+			}}},
+		}
+		want := "![image](https://pbs.twimg.com/media/" + tt.want + ")"
+		if got := DraftJSToMarkdown(content, entities, draftOptions{}); got != want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, want)
+		}
+	}
+}
+
+func TestApplyInlineStylesOverflow(t *testing.T) {
+	tests := []struct {
+		name   string
+		styles []InlineStyleRange
+		want   string
+	}{
+		{"past end", []InlineStyleRange{{Offset: 6, Length: 50, Style: "Bold"}}, "hello **world**"},
+		{"starts past end", []InlineStyleRange{{Offset: 40, Length: 5, Style: "Italic"}}, "hello world"},
+		{"negative offset", []InlineStyleRange{{Offset: -3, Length: 8, Style: "Code"}}, "`hello` world"},
+		{"empty range", []InlineStyleRange{{Offset: 3, Length: 0, Style: "Bold"}}, "hello world"},
+		{
+			"overlapping overflow",
+			[]InlineStyleRange{{Offset: 0, Length: 99, Style: "Bold"}, {Offset: 6, Length: 99, Style: "Italic"}},
+			"**hello *world***",
+		},
+	}
+	for _, tt := range tests {
+		got := applyInlineStyles("hello world", tt.styles, nil, false)
+		if got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+		for _, marker := range []string{"**", "`"} {
+			if strings.Count(got, marker)%2 != 0 {
+				t.Errorf("%s: unbalanced %q in %q", tt.name, marker, got)
+			}
+		}
+	}
+
+	// An overflowing link is closed at the end of the block too.
+	got := applyInlineStyles("see docs", nil, []linkRange{{Offset: 4, Length: 20, URL: "https://example.com"}}, false)
+	if want := "see [docs](https://example.com)"; got != want {
+		t.Errorf("link: got %q, want %q", got, want)
+	}
+}
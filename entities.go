@@ -0,0 +1,80 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// entityRe matches spans linkEntities must leave alone (Markdown links and
+// images, autolinks and HTML tags, bare URLs, inline code) as well as the
+// @mentions and #hashtags it links.
+var entityRe = regexp.MustCompile("!?\\[[^\\]]*\\]\\([^)]*\\)|<[^>\\n]*>|https?://[^\\s<>]+|`[^`\\n]*`|" +
+	`@(\w{1,15})|#([\p{L}\p{N}_]+)`)
+
+// linkEntities turns @mentions and #hashtags in Markdown text into links to
+// their X profile and hashtag pages. Text already inside links, URLs, HTML
+// tags and code is left untouched, as are e-mail addresses and fenced code.
+// Text from another service (origin SourceBluesky or SourceMastodon) is
+// returned unchanged, since its handles are not X accounts.
+func linkEntities(text, origin string) string {
+	if origin != "" {
+		return text
+	}
+	lines := strings.Split(text, "\n")
+	inFence := false
+	for i, line := range lines {
+		if strings.HasPrefix(strings.TrimLeft(line, " "), "```") {
+			inFence = !inFence
+			continue
+		}
+		if !inFence {
+			lines[i] = linkEntitiesInLine(line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func linkEntitiesInLine(line string) string {
+	var sb strings.Builder
+	last := 0
+	for _, m := range entityRe.FindAllStringSubmatchIndex(line, -1) {
+		start, end := m[0], m[1]
+		var link string
+		switch {
+		case m[2] >= 0 && !wordBefore(line, start) && !wordAfter(line, end):
+			link = "[" + line[start:end] + "](https://x.com/" + line[m[2]:m[3]] + ")"
+		case m[4] >= 0 && !wordBefore(line, start) && strings.IndexFunc(line[m[4]:m[5]], unicode.IsLetter) >= 0:
+			link = "[" + line[start:end] + "](https://x.com/hashtag/" + line[m[4]:m[5]] + ")"
+		default:
+			continue
+		}
+		sb.WriteString(line[last:start])
+		sb.WriteString(link)
+		last = end
+	}
+	if last == 0 {
+		return line
+	}
+	sb.WriteString(line[last:])
+	return sb.String()
+}
+
+// wordAfter reports whether the character at pos continues a word or a
+// handle, so neither a handle longer than X allows nor the user part of a
+// fediverse handle such as @alice@mastodon.social is linked by its prefix.
+func wordAfter(s string, pos int) bool {
+	r, _ := utf8.DecodeRuneInString(s[pos:])
+	return r == '_' || r == '@' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// wordBefore reports whether the character before pos is a letter, digit or
+// underscore, as in an e-mail address or the middle of a word.
+func wordBefore(s string, pos int) bool {
+	if pos == 0 {
+		return false
+	}
+	r, _ := utf8.DecodeLastRuneInString(s[:pos])
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLinkEntities(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"hi @bob", "hi [@bob](https://x.com/bob)"},
+		{"**@bob** said", "**[@bob](https://x.com/bob)** said"},
+		{"#golang rocks", "[#golang](https://x.com/hashtag/golang) rocks"},
+		{"#中文", "[#中文](https://x.com/hashtag/中文)"},
+		{"mail me@example.com", "mail me@example.com"},
+		{"[@bob](https://x.com/bob)", "[@bob](https://x.com/bob)"},
+		{"see https://example.com/@bob#frag", "see https://example.com/@bob#frag"},
+		{"run `@bob`", "run `@bob`"},
+		{"issue #123", "issue #123"},
+		{"@waytoolonghandle_x", "@waytoolonghandle_x"},
+		{"```\n@bob\n```", "```\n@bob\n```"},
+		{"cc @alice@mastodon.social", "cc @alice@mastodon.social"},
+	}
+	for _, tt := range tests {
+		if got := linkEntities(tt.in, ""); got != tt.want {
+			t.Errorf("linkEntities(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestLinkEntitiesOtherSources(t *testing.T) {
+	tests := []struct {
+		origin string
+		in     string
+	}{
+		{SourceMastodon, "hi @alice@mastodon.social #golang"},
+		{SourceMastodon, "hi @alice"},
+		{SourceBluesky, "hi @alice.bsky.social #golang"},
+	}
+	for _, tt := range tests {
+		if got := linkEntities(tt.in, tt.origin); got != tt.in {
+			t.Errorf("linkEntities(%q, %s) = %q, want it unchanged", tt.in, tt.origin, got)
+		}
+	}
+
+	tweet := testThread("thanks @alice.bsky.social")[0]
+	tweet.Origin = SourceBluesky
+	if got := RenderTweet(tweet, RenderOptions{LinkEntities: true}); strings.Contains(got, "[@alice") {
+		t.Errorf("Bluesky handle linked to X:\n%s", got)
+	}
+}
+
+func TestRenderArticleLinkEntities(t *testing.T) {
+	tweet, info := testArticle(Block{
+		Type: "unstyled",
+		Text: "Thanks @bob for the bold idea",
+		InlineStyleRanges: []InlineStyleRange{
+			{Offset: 7, Length: 4, Style: "Bold"},
+			{Offset: 20, Length: 4, Style: "Bold"},
+		},
+	}, Block{
+		Type:         "unstyled",
+		Text:         "Ask @carol",
+		EntityRanges: []EntityRange{{Key: 0, Offset: 4, Length: 6}},
+	})
+	tweet.Article.Content.EntityMap = []EntityMapItem{{Key: 0, Value: EntityValue{
+		Type: "LINK",
+		Data: EntityData{URL: "https://x.com/carol"},
+	}}}
+
+	got := RenderArticle(tweet, info, RenderOptions{LinkEntities: true})
+	if !strings.Contains(got, "\nThanks **[@bob](https://x.com/bob)** for the **bold** idea\n") {
+		t.Errorf("mention inside bold not linked:\n%s", got)
+	}
+	// Mentions already inside a link are not wrapped again.
+	if !strings.Contains(got, "\nAsk [@carol](https://x.com/carol)\n") || strings.Contains(got, "[[@carol]") {
+		t.Errorf("linked mention double-wrapped:\n%s", got)
+	}
+	if got := RenderArticle(tweet, info, RenderOptions{}); strings.Contains(got, "(https://x.com/bob)") {
+		t.Errorf("mention linked without -link-entities:\n%s", got)
+	}
+}
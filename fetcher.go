@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// Sources accepted by -source.
+const (
+	SourceFxTwitter = "fxtwitter"
+	SourceBluesky   = "bluesky"
+	SourceMastodon  = "mastodon"
+)
+
+// Fetcher fetches a single post from a service as the shared Tweet model.
+// Thread traversal, quote expansion and -include-parent go through it, so a
+// Fetcher must fill in ReplyingTo and ReplyingToStatus for replies.
+type Fetcher interface {
+	FetchTweet(ctx context.Context, screenName, id string) (*Tweet, error)
+}
+
+// fxTwitterFetcher fetches tweets from the FxTwitter API.
+type fxTwitterFetcher struct{}
+
+func (fxTwitterFetcher) FetchTweet(ctx context.Context, screenName, id string) (*Tweet, error) {
+	return FetchTweet(ctx, screenName, id)
+}
+
+// newFetcher returns the Fetcher for the URL's source; an empty source means
+// FxTwitter.
+func newFetcher(info URLInfo) (Fetcher, error) {
+	switch info.Source {
+	case "", SourceFxTwitter:
+		return fxTwitterFetcher{}, nil
+	case SourceBluesky:
+		return blueskyFetcher{}, nil
+	case SourceMastodon:
+		return mastodonFetcher{instance: info.Host}, nil
+	}
+	return nil, fmt.Errorf("unsupported source: %s", info.Source)
+}
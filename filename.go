@@ -0,0 +1,232 @@
+package main
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// maxSlugRunes bounds the slug part of generated filenames.
+const maxSlugRunes = 50
+
+// autoFilename derives a file name such as "2024-01-15-elonmusk-some-title.md"
+// from the tweet's date, author, and title: the given title if non-empty,
+// else the article title or first words of text.
+func autoFilename(tweet *Tweet, title string) string {
+	var parts []string
+
+	if date := filenameDate(tweet); date != "" {
+		parts = append(parts, date)
+	}
+	if tweet.Author != nil {
+		if author := slugify(tweet.Author.ScreenName); author != "" {
+			parts = append(parts, author)
+		}
+	}
+	if slug := titleSlug(tweet, title); slug != "" {
+		parts = append(parts, slug)
+	}
+
+	if len(parts) == 0 {
+		parts = append(parts, tweet.ID)
+	}
+	return strings.Join(parts, "-") + ".md"
+}
+
+// filenameDate returns the tweet's date as YYYY-MM-DD, or "" if unknown.
+func filenameDate(tweet *Tweet) string {
+	t, ok := tweetTime(tweet)
+	if !ok {
+		return ""
+	}
+	return t.UTC().Format("2006-01-02")
+}
+
+// titleSlug slugifies title, falling back to the article title or the first
+// words of the tweet text.
+func titleSlug(tweet *Tweet, title string) string {
+	if title == "" {
+		title = tweet.Text
+		if tweet.Article != nil && tweet.Article.Title != "" {
+			title = tweet.Article.Title
+		}
+	}
+	return slugify(title)
+}
+
+// outputPlaceholderRe matches -o placeholders such as {author}.
+var outputPlaceholderRe = regexp.MustCompile(`\{(author|id|date|type|slug)\}`)
+
+// expandOutputPath substitutes {author}, {id}, {date}, {type} and {slug} in
+// an -o pattern with values from c. Values are sanitized so they cannot add
+// path separators; missing ones become "unknown". Other text is kept as is.
+func expandOutputPath(pattern string, c *Content, title string) string {
+	tweet := c.Tweet
+	return outputPlaceholderRe.ReplaceAllStringFunc(pattern, func(m string) string {
+		var value string
+		switch m {
+		case "{author}":
+			if tweet.Author != nil {
+				value = slugify(tweet.Author.ScreenName)
+			}
+		case "{id}":
+			value = slugify(tweet.ID)
+		case "{date}":
+			value = filenameDate(tweet)
+		case "{type}":
+			value = c.Type
+		case "{slug}":
+			value = titleSlug(tweet, title)
+		}
+		if value == "" {
+			value = "unknown"
+		}
+		return value
+	})
+}
+
+// slugify lowercases s and joins its letters and digits (including CJK) with
+// single hyphens, truncated to maxSlugRunes. Apostrophes are dropped.
+func slugify(s string) string {
+	var sb strings.Builder
+	count := 0
+	pendingHyphen := false
+
+	for _, r := range strings.ToLower(s) {
+		if count >= maxSlugRunes {
+			break
+		}
+		if r == '\'' || r == '’' {
+			continue
+		}
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			pendingHyphen = sb.Len() > 0
+			continue
+		}
+		if pendingHyphen {
+			sb.WriteRune('-')
+			count++
+			pendingHyphen = false
+		}
+		sb.WriteRune(r)
+		count++
+	}
+
+	return strings.Trim(sb.String(), "-")
+}
+
+// uniquePath returns dir/name, or dir/name-2, dir/name-3, ... if it already exists.
+func uniquePath(dir, name string) string {
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+
+	path := filepath.Join(dir, name)
+	for i := 2; ; i++ {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return path
+		}
+		path = filepath.Join(dir, fmt.Sprintf("%s-%d%s", base, i, ext))
+	}
+}
+
+// mediaExtensions maps common media content types to the extension used when
+// a file name has none; other types fall back to mime.ExtensionsByType.
+var mediaExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+	"video/mp4":  ".mp4",
+}
+
+// originalFilename names a file downloaded from rawURL for -image-naming
+// original: the Content-Disposition filename when the response has one,
+// otherwise the last segment of the URL path. A missing name, or one that
+// would clash with the manifest or index, becomes "image". A name without a
+// media extension gets one from the Content-Type, so a download can never
+// pass for a Markdown or JSON file.
+func originalFilename(rawURL string, h http.Header) string {
+	var name string
+	if _, params, err := mime.ParseMediaType(h.Get("Content-Disposition")); err == nil {
+		name = safeBaseName(params["filename"])
+	}
+	if name == "" {
+		if u, err := url.Parse(rawURL); err == nil {
+			name = safeBaseName(path.Base(u.Path))
+		}
+	}
+	if name == "" || strings.EqualFold(name, manifestName) || strings.EqualFold(name, indexName) {
+		name = "image"
+	}
+	if !isMediaExtension(filepath.Ext(name)) {
+		name += mediaTypeExtension(h.Get("Content-Type"))
+	}
+	return name
+}
+
+// isMediaExtension reports whether ext is the extension of an image or video
+// file.
+func isMediaExtension(ext string) bool {
+	ext = strings.ToLower(ext)
+	for _, e := range mediaExtensions {
+		if e == ext {
+			return true
+		}
+	}
+	t := mime.TypeByExtension(ext)
+	return strings.HasPrefix(t, "image/") || strings.HasPrefix(t, "video/")
+}
+
+// mediaTypeExtension is contentTypeExtension for a media download, falling
+// back to ".mp4" for other videos and ".jpg" for anything else.
+func mediaTypeExtension(contentType string) string {
+	if ext := contentTypeExtension(contentType); isMediaExtension(ext) {
+		return ext
+	}
+	if strings.HasPrefix(contentType, "video/") {
+		return ".mp4"
+	}
+	return ".jpg"
+}
+
+// safeBaseName returns the last element of name if it is usable as a file
+// name, or "" for empty, "." and ".." names. Spaces and brackets, which would
+// break the Markdown link to the file, become "-".
+func safeBaseName(name string) string {
+	name = strings.TrimSpace(name)
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		name = name[i+1:]
+	}
+	if name == "." || name == ".." {
+		return ""
+	}
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) || strings.ContainsRune("()[]", r) {
+			return '-'
+		}
+		return r
+	}, name)
+}
+
+// contentTypeExtension returns the file extension for a Content-Type value,
+// or "" when it is unknown.
+func contentTypeExtension(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	if ext, ok := mediaExtensions[mediaType]; ok {
+		return ext
+	}
+	if exts, err := mime.ExtensionsByType(mediaType); err == nil && len(exts) > 0 {
+		return exts[0]
+	}
+	return ""
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"Some Title", "some-title"},
+		{"Hello, World! (Part 2)", "hello-world-part-2"},
+		{"Don't panic: it's fine...", "dont-panic-its-fine"},
+		{"--Leading & trailing--", "leading-trailing"},
+		{"中文标题：测试", "中文标题-测试"},
+		{"Go 语言入门 🚀 Guide", "go-语言入门-guide"},
+		{"!!!", ""},
+		{strings.Repeat("a", 80), strings.Repeat("a", maxSlugRunes)},
+	}
+	for _, tt := range tests {
+		if got := slugify(tt.in); got != tt.want {
+			t.Errorf("slugify(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestAutoFilename(t *testing.T) {
+	tweet := testThread("Ignored text")[0]
+	if got, want := autoFilename(tweet, "Hello, 世界!"), "2024-01-15-alice-hello-世界.md"; got != want {
+		t.Errorf("autoFilename() = %q, want %q", got, want)
+	}
+	if got, want := autoFilename(tweet, ""), "2024-01-15-alice-ignored-text.md"; got != want {
+		t.Errorf("autoFilename() without title = %q, want %q", got, want)
+	}
+}
+
+func TestUniquePath(t *testing.T) {
+	dir := t.TempDir()
+	if got := uniquePath(dir, "a.md"); got != filepath.Join(dir, "a.md") {
+		t.Errorf("uniquePath() = %q for a free name", got)
+	}
+	for _, name := range []string{"a.md", "a-2.md"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := uniquePath(dir, "a.md"); got != filepath.Join(dir, "a-3.md") {
+		t.Errorf("uniquePath() = %q, want a-3.md", got)
+	}
+}
+
+func TestExpandOutputPath(t *testing.T) {
+	tweet := testThread("Release notes: v2 is out!")[0]
+	c := newTweetContent(tweet, tweetURLInfo(tweet))
+	tests := []struct {
+		pattern string
+		title   string
+		want    string
+	}{
+		{"{author}-{id}.md", "", "alice-1.md"},
+		{"out/{date}/{type}-{slug}.md", "", "out/2024-01-15/tweet-release-notes-v2-is-out.md"},
+		{"{slug}.md", "My Title", "my-title.md"},
+		{"{unknown}-{id}.md", "", "{unknown}-1.md"},
+	}
+	for _, tt := range tests {
+		if got := expandOutputPath(tt.pattern, c, tt.title); got != tt.want {
+			t.Errorf("expandOutputPath(%q) = %q, want %q", tt.pattern, got, tt.want)
+		}
+	}
+
+	// Values cannot escape the pattern's directory.
+	evil := testThread("x")[0]
+	evil.ID = "../../etc/passwd"
+	evil.Author = nil
+	c = newTweetContent(evil, tweetURLInfo(evil))
+	if got, want := expandOutputPath("{author}/{id}.md", c, ""), "unknown/etc-passwd.md"; got != want {
+		t.Errorf("expandOutputPath() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// listItemRe matches a list item line, capturing an ordered item's number
+	// and the item text.
+	listItemRe = regexp.MustCompile(`^(?:[-*+]|(\d+)[.)])\s+(.*)$`)
+	// refLabelRe matches a "[3]" label at the start of a reference item.
+	refLabelRe = regexp.MustCompile(`^\[(\d+)\]\s*`)
+	// itemURLRe finds the first link target or bare URL in a reference item.
+	itemURLRe = regexp.MustCompile(`\]\((https?://[^)\s]+)\)|https?://[^\s<>)]+`)
+	// inlineRefRe matches what a body line may use to cite a reference: a
+	// Markdown link or image, or a bare "[3]" marker. Code spans are matched
+	// too, so that citations inside them are left alone.
+	inlineRefRe = regexp.MustCompile("``.*?``|`[^`]*`|" + `!?\[([^\]]*)\]\(([^)\s]+)\)|\[(\d+)\]`)
+	// headingRe matches an ATX heading line.
+	headingRe = regexp.MustCompile(`^#{1,6} `)
+)
+
+// reference is an item of the trailing source list that applyFootnotes
+// turns into a footnote.
+type reference struct {
+	label string // footnote label, the item's number
+	url   string
+	text  string // item text without its list marker and label
+	line  string // the original list line
+	cited bool
+}
+
+// applyFootnotes is a best-effort conversion of an article's trailing list
+// of source links into Markdown footnotes. When every item of the final list
+// holds a link, body citations of an item, either "[n]" markers or links to
+// the item's URL, become "[^n]" references and the cited items move into
+// footnote definitions at the end. Uncited items stay in the list, since
+// renderers drop footnotes nothing refers to; text without such a list, or
+// with no citations, is returned unchanged.
+func applyFootnotes(md string) string {
+	lines := strings.Split(strings.TrimRight(md, "\n"), "\n")
+
+	// Collect the trailing list; Draft.js items are separated by blank lines.
+	start := len(lines)
+	var refs []*reference
+	for i := len(lines) - 1; i >= 0; i-- {
+		if strings.TrimSpace(lines[i]) == "" {
+			continue
+		}
+		m := listItemRe.FindStringSubmatch(lines[i])
+		if m == nil {
+			break
+		}
+		u := itemURLRe.FindStringSubmatch(m[2])
+		if u == nil {
+			return md
+		}
+		ref := &reference{label: m[1], url: u[1], text: m[2], line: lines[i]}
+		if ref.url == "" {
+			ref.url = u[0]
+		}
+		if l := refLabelRe.FindStringSubmatch(ref.text); l != nil {
+			ref.label, ref.text = l[1], ref.text[len(l[0]):]
+		}
+		refs = append([]*reference{ref}, refs...)
+		start = i
+	}
+	if len(refs) == 0 {
+		return md
+	}
+	for i, ref := range refs {
+		if ref.label == "" {
+			ref.label = strconv.Itoa(i + 1)
+		}
+	}
+
+	body := lines[:start]
+	inFence := false
+	for i, line := range body {
+		if trimmed := strings.TrimSpace(line); strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inFence = !inFence
+		}
+		if !inFence {
+			body[i] = citeReferences(line, refs)
+		}
+	}
+
+	var cited, uncited []string
+	for _, ref := range refs {
+		if ref.cited {
+			cited = append(cited, fmt.Sprintf("[^%s]: %s", ref.label, ref.text))
+		} else {
+			uncited = append(uncited, ref.line)
+		}
+	}
+	if len(cited) == 0 {
+		return md
+	}
+
+	text := strings.TrimRight(strings.Join(body, "\n"), "\n")
+	if len(uncited) == 0 {
+		// The heading introduced a list that no longer exists.
+		text = dropTrailingHeading(text)
+	} else {
+		text += "\n\n" + strings.Join(uncited, "\n\n")
+	}
+	return text + "\n\n" + strings.Join(cited, "\n")
+}
+
+// citeReferences rewrites the citations of refs in a body line as footnote
+// references, marking the refs it finds as cited. Code spans are kept as is.
+func citeReferences(line string, refs []*reference) string {
+	return inlineRefRe.ReplaceAllStringFunc(line, func(m string) string {
+		if strings.HasPrefix(m, "`") {
+			return m
+		}
+		sub := inlineRefRe.FindStringSubmatch(m)
+		for _, ref := range refs {
+			switch {
+			case sub[3] != "" && sub[3] == ref.label:
+				ref.cited = true
+				return "[^" + ref.label + "]"
+			case sub[2] != "" && !strings.HasPrefix(m, "!") && sub[2] == ref.url:
+				ref.cited = true
+				return sub[1] + "[^" + ref.label + "]"
+			}
+		}
+		return m
+	})
+}
+
+// dropTrailingHeading removes a final heading line such as "## References".
+func dropTrailingHeading(text string) string {
+	i := strings.LastIndex(text, "\n")
+	if !headingRe.MatchString(text[i+1:]) {
+		return text
+	}
+	return strings.TrimRight(text[:max(i, 0)], "\n")
+}
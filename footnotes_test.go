@@ -0,0 +1,91 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestApplyFootnotes(t *testing.T) {
+	tests := []struct {
+		name string
+		md   string
+		want string
+	}{
+		{
+			"numbered markers",
+			"Claim one [1] and two [2].\n\n## Sources\n\n1. https://a.example/one\n\n2. [Two](https://b.example/two)\n",
+			"Claim one [^1] and two [^2].\n\n[^1]: https://a.example/one\n[^2]: [Two](https://b.example/two)",
+		},
+		{
+			"links to sources",
+			"See [the report](https://a.example/one).\n\n- [Report](https://a.example/one)\n\n- [Other](https://b.example/two)\n",
+			"See the report[^1].\n\n- [Other](https://b.example/two)\n\n[^1]: [Report](https://a.example/one)",
+		},
+		{
+			"labelled items",
+			"As shown [3].\n\n- [3] https://a.example/three\n",
+			"As shown [^3].\n\n[^3]: https://a.example/three",
+		},
+		{
+			"code span",
+			"Index with `arr[1]` as in [1].\n\n1. https://a.example/one\n",
+			"Index with `arr[1]` as in [^1].\n\n[^1]: https://a.example/one",
+		},
+		{
+			"double backtick span",
+			"Use ``a`[1]`` here.\n\n1. https://a.example/one\n",
+			"Use ``a`[1]`` here.\n\n1. https://a.example/one\n",
+		},
+		{
+			"fenced code",
+			"```\nx := arr[1]\n```\n\nText.\n\n1. https://a.example/one\n",
+			"```\nx := arr[1]\n```\n\nText.\n\n1. https://a.example/one\n",
+		},
+		{
+			"list without links",
+			"Claim [1].\n\n- first\n\n- second\n",
+			"Claim [1].\n\n- first\n\n- second\n",
+		},
+		{
+			"no list",
+			"Just text [1].\n",
+			"Just text [1].\n",
+		},
+	}
+	for _, tt := range tests {
+		if got := applyFootnotes(tt.md); got != tt.want {
+			t.Errorf("%s:\ngot  %q\nwant %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRenderArticleFootnotes(t *testing.T) {
+	tweet, info := testArticle(
+		Block{Type: "unstyled", Text: "Prices rose [1], as the survey found."},
+		Block{Type: "code-block", Text: "rows[1]"},
+		Block{Type: "header-two", Text: "References"},
+		Block{Type: "ordered-list-item", Text: "Price index", EntityRanges: []EntityRange{{Key: 0, Offset: 0, Length: 11}}},
+	)
+	tweet.Article.Content.EntityMap = []EntityMapItem{{Key: 0, Value: EntityValue{
+		Type: "LINK", Data: EntityData{URL: "https://stats.example/cpi"},
+	}}}
+
+	got := docBody(RenderArticle(tweet, info, RenderOptions{Footnotes: true}))
+	for _, want := range []string{
+		"Prices rose [^1], as the survey found.",
+		"```\nrows[1]\n```",
+		"[^1]: [Price index](https://stats.example/cpi)\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "References") || strings.Contains(got, "1. [Price index]") {
+		t.Errorf("source list kept after every item was cited:\n%s", got)
+	}
+
+	// Without -footnotes the list stays as written.
+	if got := RenderArticle(tweet, info, RenderOptions{}); !strings.Contains(got, "Prices rose [1]") || strings.Contains(got, "[^1]") {
+		t.Errorf("footnotes applied without -footnotes:\n%s", got)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"html"
 	"regexp"
+	"strconv"
 	"strings"
 )
 
@@ -46,7 +47,7 @@ func HTMLToMarkdown(htmlStr string) string {
 	return strings.TrimSpace(s)
 }
 
-var preBlockRe = regexp.MustCompile(`(?is)<pre[^>]*>(.*?)</pre>`)
+var preBlockRe = regexp.MustCompile(`(?is)<pre(?:\s[^>]*)?>(.*?)</pre>`)
 var codeInPreRe = regexp.MustCompile(`(?is)<code(?:\s+class="language-([^"]*)")?[^>]*>(.*?)</code>`)
 
 func processPreBlocks(s string) string {
@@ -74,12 +75,12 @@ func processPreBlocks(s string) string {
 }
 
 var (
-	h1Re = regexp.MustCompile(`(?is)<h1[^>]*>(.*?)</h1>`)
-	h2Re = regexp.MustCompile(`(?is)<h2[^>]*>(.*?)</h2>`)
-	h3Re = regexp.MustCompile(`(?is)<h3[^>]*>(.*?)</h3>`)
-	h4Re = regexp.MustCompile(`(?is)<h4[^>]*>(.*?)</h4>`)
-	h5Re = regexp.MustCompile(`(?is)<h5[^>]*>(.*?)</h5>`)
-	h6Re = regexp.MustCompile(`(?is)<h6[^>]*>(.*?)</h6>`)
+	h1Re = regexp.MustCompile(`(?is)<h1(?:\s[^>]*)?>(.*?)</h1>`)
+	h2Re = regexp.MustCompile(`(?is)<h2(?:\s[^>]*)?>(.*?)</h2>`)
+	h3Re = regexp.MustCompile(`(?is)<h3(?:\s[^>]*)?>(.*?)</h3>`)
+	h4Re = regexp.MustCompile(`(?is)<h4(?:\s[^>]*)?>(.*?)</h4>`)
+	h5Re = regexp.MustCompile(`(?is)<h5(?:\s[^>]*)?>(.*?)</h5>`)
+	h6Re = regexp.MustCompile(`(?is)<h6(?:\s[^>]*)?>(.*?)</h6>`)
 )
 
 func processHeadings(s string) string {
@@ -110,7 +111,7 @@ func processHeadings(s string) string {
 	return s
 }
 
-var blockquoteRe = regexp.MustCompile(`(?is)<blockquote[^>]*>(.*?)</blockquote>`)
+var blockquoteRe = regexp.MustCompile(`(?is)<blockquote(?:\s[^>]*)?>(.*?)</blockquote>`)
 
 func processBlockquotes(s string) string {
 	return blockquoteRe.ReplaceAllStringFunc(s, func(match string) string {
@@ -127,9 +128,9 @@ func processBlockquotes(s string) string {
 }
 
 var (
-	ulRe = regexp.MustCompile(`(?is)<ul[^>]*>(.*?)</ul>`)
-	olRe = regexp.MustCompile(`(?is)<ol[^>]*>(.*?)</ol>`)
-	liRe = regexp.MustCompile(`(?is)<li[^>]*>(.*?)</li>`)
+	ulRe = regexp.MustCompile(`(?is)<ul(?:\s[^>]*)?>(.*?)</ul>`)
+	olRe = regexp.MustCompile(`(?is)<ol(?:\s([^>]*))?>(.*?)</ol>`)
+	liRe = regexp.MustCompile(`(?is)<li(?:\s[^>]*)?>(.*?)</li>`)
 )
 
 func processLists(s string) string {
@@ -139,20 +140,26 @@ func processLists(s string) string {
 		items := liRe.FindAllStringSubmatch(inner, -1)
 		var lines []string
 		for _, item := range items {
-			text := strings.TrimSpace(stripTags(item[1]))
-			lines = append(lines, "- "+text)
+			lines = append(lines, "- "+listItemText(item[1], "  "))
 		}
 		return "\n\n" + strings.Join(lines, "\n") + "\n\n"
 	})
 
 	// Ordered lists
 	s = olRe.ReplaceAllStringFunc(s, func(match string) string {
-		inner := olRe.FindStringSubmatch(match)[1]
-		items := liRe.FindAllStringSubmatch(inner, -1)
+		parts := olRe.FindStringSubmatch(match)
+		// Continued lists carry start="N"; numbering begins there.
+		start := 1
+		if v, ok := htmlAttr(parts[1], startAttrRe); ok {
+			if n, err := strconv.Atoi(strings.TrimSpace(v)); err == nil && n >= 0 {
+				start = n
+			}
+		}
+		items := liRe.FindAllStringSubmatch(parts[2], -1)
 		var lines []string
 		for i, item := range items {
-			text := strings.TrimSpace(stripTags(item[1]))
-			lines = append(lines, fmt.Sprintf("%d. %s", i+1, text))
+			marker := fmt.Sprintf("%d. ", start+i)
+			lines = append(lines, marker+listItemText(item[1], strings.Repeat(" ", len(marker))))
 		}
 		return "\n\n" + strings.Join(lines, "\n") + "\n\n"
 	})
@@ -160,24 +167,49 @@ func processLists(s string) string {
 	return s
 }
 
-var pRe = regexp.MustCompile(`(?is)<p[^>]*>(.*?)</p>`)
+var listParagraphRe = regexp.MustCompile(`(?is)</p>\s*<p(?:\s[^>]*)?>`)
+
+// listItemText flattens a list item's HTML. Paragraphs inside the item become
+// blank-line separated, <br> becomes a hard line break (two trailing spaces),
+// and continuation lines are indented to align with the item's content.
+func listItemText(inner, indent string) string {
+	inner = strings.TrimSpace(inner)
+	inner = listParagraphRe.ReplaceAllString(inner, "\n\n")
+	inner = brRe.ReplaceAllString(inner, "  \n")
+	inner = strings.TrimSpace(stripTags(inner))
+
+	lines := strings.Split(inner, "\n")
+	for i := 1; i < len(lines); i++ {
+		line := strings.TrimLeft(lines[i], " \t")
+		if line != "" {
+			line = indent + line
+		}
+		lines[i] = line
+	}
+	return strings.Join(lines, "\n")
+}
+
+var pRe = regexp.MustCompile(`(?is)<p(?:\s[^>]*)?>(.*?)</p>`)
 
 func processParagraphs(s string) string {
 	return pRe.ReplaceAllString(s, "\n\n$1\n\n")
 }
 
-var hrRe = regexp.MustCompile(`(?i)<hr\s*/?>`)
+var hrRe = regexp.MustCompile(`(?i)<hr(?:\s[^>]*)?/?>`)
 
 func processHorizontalRules(s string) string {
 	return hrRe.ReplaceAllString(s, "\n\n---\n\n")
 }
 
-var linkRe = regexp.MustCompile(`(?is)<a\s[^>]*href="([^"]*)"[^>]*>(.*?)</a>`)
+var linkRe = regexp.MustCompile(`(?is)<a\s([^>]*)>(.*?)</a>`)
 
 func processLinks(s string) string {
 	return linkRe.ReplaceAllStringFunc(s, func(match string) string {
 		parts := linkRe.FindStringSubmatch(match)
-		href := parts[1]
+		href, ok := htmlAttr(parts[1], hrefAttrRe)
+		if !ok {
+			return match
+		}
 		text := strings.TrimSpace(stripTags(parts[2]))
 		if text == "" {
 			text = href
@@ -186,22 +218,44 @@ func processLinks(s string) string {
 	})
 }
 
-var imgRe = regexp.MustCompile(`(?i)<img\s[^>]*src="([^"]*)"[^>]*(?:alt="([^"]*)")?[^>]*/?>`)
+var imgRe = regexp.MustCompile(`(?i)<img\s([^>]*?)/?>`)
 
 func processImages(s string) string {
 	return imgRe.ReplaceAllStringFunc(s, func(match string) string {
-		parts := imgRe.FindStringSubmatch(match)
-		src := parts[1]
-		alt := ""
-		if len(parts) > 2 {
-			alt = parts[2]
+		attrs := imgRe.FindStringSubmatch(match)[1]
+		src, ok := htmlAttr(attrs, srcAttrRe)
+		if !ok {
+			return match
 		}
+		alt, _ := htmlAttr(attrs, altAttrRe)
 		return "![" + alt + "](" + src + ")"
 	})
 }
 
+// Attribute matchers for htmlAttr; values may be double-, single- or unquoted.
 var (
-	boldRe  = regexp.MustCompile(`(?is)<(?:strong|b)>(.*?)</(?:strong|b)>`)
+	hrefAttrRe  = attrRe("href")
+	srcAttrRe   = attrRe("src")
+	altAttrRe   = attrRe("alt")
+	startAttrRe = attrRe("start")
+)
+
+func attrRe(name string) *regexp.Regexp {
+	return regexp.MustCompile(`(?i)(?:^|\s)` + name + `\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+}
+
+// htmlAttr extracts an attribute value from a tag's attribute list and
+// decodes its entities, so hrefs like "?a=1&amp;b=2" come out as "?a=1&b=2".
+func htmlAttr(attrs string, re *regexp.Regexp) (string, bool) {
+	m := re.FindStringSubmatch(attrs)
+	if m == nil {
+		return "", false
+	}
+	return html.UnescapeString(m[1] + m[2] + m[3]), true
+}
+
+var (
+	boldRe  = regexp.MustCompile(`(?is)<(?:strong|b)(?:\s[^>]*)?>(.*?)</(?:strong|b)>`)
 )
 
 func processBold(s string) string {
@@ -209,20 +263,42 @@ func processBold(s string) string {
 }
 
 var (
-	italicRe = regexp.MustCompile(`(?is)<(?:em|i)>(.*?)</(?:em|i)>`)
+	italicRe = regexp.MustCompile(`(?is)<(?:em|i)(?:\s[^>]*)?>(.*?)</(?:em|i)>`)
 )
 
 func processItalic(s string) string {
 	return italicRe.ReplaceAllString(s, "*$1*")
 }
 
-var inlineCodeRe = regexp.MustCompile(`(?is)<code>(.*?)</code>`)
+var inlineCodeRe = regexp.MustCompile(`(?is)<code(?:\s[^>]*)?>(.*?)</code>`)
+var backtickRunRe = regexp.MustCompile("`+")
 
+// processInlineCode converts <code> to a code span. Per CommonMark, content
+// containing backticks gets a longer delimiter, padded with spaces when the
+// content starts or ends with a backtick.
 func processInlineCode(s string) string {
-	return inlineCodeRe.ReplaceAllString(s, "`$1`")
+	return inlineCodeRe.ReplaceAllStringFunc(s, func(match string) string {
+		content := inlineCodeRe.FindStringSubmatch(match)[1]
+		// Entities are decoded later; measure backticks as they will appear.
+		decoded := html.UnescapeString(stripTags(content))
+
+		longest := 0
+		for _, run := range backtickRunRe.FindAllString(decoded, -1) {
+			if len(run) > longest {
+				longest = len(run)
+			}
+		}
+		fence := strings.Repeat("`", longest+1)
+
+		pad := ""
+		if strings.HasPrefix(decoded, "`") || strings.HasSuffix(decoded, "`") {
+			pad = " "
+		}
+		return fence + pad + content + pad + fence
+	})
 }
 
-var brRe = regexp.MustCompile(`(?i)<br\s*/?>`)
+var brRe = regexp.MustCompile(`(?i)<br(?:\s[^>]*)?/?>`)
 
 func processLineBreaks(s string) string {
 	return brRe.ReplaceAllString(s, "\n")
@@ -233,12 +309,3 @@ var tagRe = regexp.MustCompile(`<[^>]*>`)
 func stripTags(s string) string {
 	return tagRe.ReplaceAllString(s, "")
 }
-
-var multiNewlineRe = regexp.MustCompile(`\n{3,}`)
-var trailingSpaceRe = regexp.MustCompile(`[ \t]+\n`)
-
-func cleanWhitespace(s string) string {
-	s = trailingSpaceRe.ReplaceAllString(s, "\n")
-	s = multiNewlineRe.ReplaceAllString(s, "\n\n")
-	return s
-}
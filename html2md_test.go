@@ -0,0 +1,103 @@
+package main
+
+import "testing"
+
+func TestHTMLInlineCodeBackticks(t *testing.T) {
+	tests := []struct {
+		html string
+		want string
+	}{
+		{"<p>Run <code>go test</code> now</p>", "Run `go test` now"},
+		{"<p>Use <code>a`b</code> here</p>", "Use ``a`b`` here"},
+		{"<p><code>`tick</code></p>", "`` `tick ``"},
+		{"<p><code>x``y</code></p>", "```x``y```"},
+		{"<p><code class=\"lang-go\">&lt;b&gt;</code></p>", "`<b>`"},
+	}
+	for _, tt := range tests {
+		if got := HTMLToMarkdown(tt.html); got != tt.want {
+			t.Errorf("HTMLToMarkdown(%q) = %q, want %q", tt.html, got, tt.want)
+		}
+	}
+}
+
+func TestHTMLLinkAttributes(t *testing.T) {
+	tests := []struct {
+		html string
+		want string
+	}{
+		{`<a href="https://example.com/?a=1&amp;b=2">q</a>`, "[q](https://example.com/?a=1&b=2)"},
+		{`<a href='https://example.com/single'>single</a>`, "[single](https://example.com/single)"},
+		{`<a class="x" href='https://example.com/?a=1&amp;b=2'>both</a>`, "[both](https://example.com/?a=1&b=2)"},
+		{`<a href=https://example.com/bare>bare</a>`, "[bare](https://example.com/bare)"},
+		{`<a data-href="https://wrong.example" href="https://right.example">r</a>`, "[r](https://right.example)"},
+		{`<img src='https://example.com/i.png?w=1&amp;h=2' alt='A &amp; B'>`, "![A & B](https://example.com/i.png?w=1&h=2)"},
+	}
+	for _, tt := range tests {
+		if got := HTMLToMarkdown(tt.html); got != tt.want {
+			t.Errorf("HTMLToMarkdown(%q) = %q, want %q", tt.html, got, tt.want)
+		}
+	}
+}
+
+func TestHTMLUppercaseAndVoidTags(t *testing.T) {
+	tests := []struct {
+		html string
+		want string
+	}{
+		{"<P>One</P><P>Two</P>", "One\n\nTwo"},
+		{"<p>a<BR>b<br/>c<Br />d</p>", "a\nb\nc\nd"},
+		{`<IMG SRC="https://example.com/a.png" ALT="A">`, "![A](https://example.com/a.png)"},
+		{`<img src="https://example.com/b.png"/>`, "![](https://example.com/b.png)"},
+		{"<P>Above</P><HR><p>Below</p>", "Above\n\n---\n\nBelow"},
+		{"<H2 class=\"t\">Title</H2><STRONG>bold</STRONG> <Em>it</Em>", "## Title\n\n**bold** *it*"},
+		// Tags that merely start with a known name are not mistaken for it.
+		{"<pre>x</pre><PRE>y</PRE><bdi>z</bdi>", "```\nx\n```\n\n```\ny\n```\n\nz"},
+	}
+	for _, tt := range tests {
+		if got := HTMLToMarkdown(tt.html); got != tt.want {
+			t.Errorf("HTMLToMarkdown(%q) = %q, want %q", tt.html, got, tt.want)
+		}
+	}
+}
+
+func TestHTMLListItemParagraphs(t *testing.T) {
+	tests := []struct {
+		html string
+		want string
+	}{
+		{
+			"<ul><li><p>First paragraph</p><p>Second paragraph</p></li><li>Next</li></ul>",
+			"- First paragraph\n\n  Second paragraph\n- Next",
+		},
+		{
+			"<ol><li><p>One</p>\n<p>Two</p></li></ol>",
+			"1. One\n\n   Two",
+		},
+		{"<ul><li>line<br>break</li></ul>", "- line  \n  break"},
+		// Outside list items <br> is a plain newline with no trailing spaces.
+		{"<p>line<br>break</p>", "line\nbreak"},
+	}
+	for _, tt := range tests {
+		if got := HTMLToMarkdown(tt.html); got != tt.want {
+			t.Errorf("HTMLToMarkdown(%q) = %q, want %q", tt.html, got, tt.want)
+		}
+	}
+}
+
+func TestHTMLOrderedListStart(t *testing.T) {
+	tests := []struct {
+		html string
+		want string
+	}{
+		{`<ol start="3"><li>Three</li><li>Four</li></ol>`, "3. Three\n4. Four"},
+		{`<ol start=10><li>Ten</li></ol>`, "10. Ten"},
+		{`<ol><li>One</li><li>Two</li></ol>`, "1. One\n2. Two"},
+		// An unusable start falls back to 1.
+		{`<ol start="x"><li>One</li></ol>`, "1. One"},
+	}
+	for _, tt := range tests {
+		if got := HTMLToMarkdown(tt.html); got != tt.want {
+			t.Errorf("HTMLToMarkdown(%q) = %q, want %q", tt.html, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// defaultTrackingParams are the query parameters -clean-links removes unless
+// -clean-params says otherwise. Patterns use path.Match syntax.
+const defaultTrackingParams = "utm_*,ref_src,ref_url,s,t"
+
+// parseParamPatterns splits a comma-separated -clean-params value.
+func parseParamPatterns(list string) []string {
+	var patterns []string
+	for _, p := range strings.Split(list, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns
+}
+
+// cleanLinkRe matches Markdown images and inline code (both left alone),
+// Markdown link destinations, <...> autolinks and bare http(s) URLs.
+var cleanLinkRe = regexp.MustCompile("`[^`\n]*`|" + `!\[[^\]]*\]\([^)]*\)|(\[[^\]]*\]\()([^)\s]+)\)|<(https?://[^>\s]+)>|https?://[^\s<>()\[\]]+`)
+
+// cleanLinks strips query parameters matching patterns from every link in
+// Markdown text. Image URLs are kept as they are, and so is code, inline or
+// fenced.
+func cleanLinks(text string, patterns []string) string {
+	if len(patterns) == 0 {
+		return text
+	}
+	lines := strings.Split(text, "\n")
+	inFence := false
+	for i, line := range lines {
+		if strings.HasPrefix(strings.TrimLeft(line, " "), "```") {
+			inFence = !inFence
+			continue
+		}
+		if !inFence {
+			lines[i] = cleanLinksInLine(line, patterns)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func cleanLinksInLine(line string, patterns []string) string {
+	return cleanLinkRe.ReplaceAllStringFunc(line, func(m string) string {
+		parts := cleanLinkRe.FindStringSubmatch(m)
+		switch {
+		case strings.HasPrefix(m, "!"), strings.HasPrefix(m, "`"):
+			return m
+		case parts[1] != "":
+			return parts[1] + cleanURL(parts[2], patterns) + ")"
+		case parts[3] != "":
+			return "<" + cleanURL(parts[3], patterns) + ">"
+		default:
+			return cleanURL(m, patterns)
+		}
+	})
+}
+
+// cleanURL removes query parameters whose names match any of patterns,
+// keeping the remaining parameters in their original order.
+func cleanURL(rawURL string, patterns []string) string {
+	base, query, ok := strings.Cut(rawURL, "?")
+	if !ok {
+		return rawURL
+	}
+	query, fragment, hasFragment := strings.Cut(query, "#")
+
+	var kept []string
+	for _, param := range strings.Split(query, "&") {
+		key, _, _ := strings.Cut(param, "=")
+		if k, err := url.QueryUnescape(key); err == nil {
+			key = k
+		}
+		if param == "" || matchesAny(key, patterns) {
+			continue
+		}
+		kept = append(kept, param)
+	}
+
+	cleaned := base
+	if len(kept) > 0 {
+		cleaned += "?" + strings.Join(kept, "&")
+	}
+	if hasFragment {
+		cleaned += "#" + fragment
+	}
+	return cleaned
+}
+
+func matchesAny(name string, patterns []string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCleanLinks(t *testing.T) {
+	patterns := parseParamPatterns(defaultTrackingParams)
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"https://example.com/a?utm_source=x&utm_medium=y", "https://example.com/a"},
+		{"https://example.com/a?id=7&utm_source=x&page=2", "https://example.com/a?id=7&page=2"},
+		{"[post](https://example.com/p?ref_src=twsrc&s=20#top)", "[post](https://example.com/p#top)"},
+		{"<https://example.com/?t=abc&q=go>", "<https://example.com/?q=go>"},
+		{"https://example.com/search?q=utm_source", "https://example.com/search?q=utm_source"},
+		// Images keep their URLs untouched.
+		{"![img](https://example.com/i.jpg?utm_source=x)", "![img](https://example.com/i.jpg?utm_source=x)"},
+		// So does code.
+		{"run `curl https://example.com/?utm_source=x`", "run `curl https://example.com/?utm_source=x`"},
+		{"```\ncurl https://example.com/?utm_source=x\n```\nhttps://example.com/?utm_source=x", "```\ncurl https://example.com/?utm_source=x\n```\nhttps://example.com/"},
+	}
+	for _, tt := range tests {
+		if got := cleanLinks(tt.in, patterns); got != tt.want {
+			t.Errorf("cleanLinks(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+	if in := "https://example.com/?utm_source=x"; cleanLinks(in, nil) != in {
+		t.Error("cleanLinks changed a link without patterns")
+	}
+}
+
+func TestRenderCleanLinks(t *testing.T) {
+	opts := RenderOptions{CleanParams: parseParamPatterns(defaultTrackingParams)}
+
+	tweet := testThread("Read https://example.com/post?utm_campaign=launch&id=3")[0]
+	if got := RenderTweet(tweet, opts); !strings.Contains(got, "Read https://example.com/post?id=3\n") {
+		t.Errorf("tweet link not cleaned:\n%s", got)
+	}
+
+	article, info := testArticle(Block{
+		Type:         "unstyled",
+		Text:         "See docs",
+		EntityRanges: []EntityRange{{Key: 0, Offset: 4, Length: 4}},
+	})
+	article.Article.Content.EntityMap = []EntityMapItem{{Key: 0, Value: EntityValue{
+		Type: "LINK",
+		Data: EntityData{URL: "https://example.com/docs?utm_source=x"},
+	}}}
+	if got := RenderArticle(article, info, opts); !strings.Contains(got, "See [docs](https://example.com/docs)\n") {
+		t.Errorf("article link not cleaned:\n%s", got)
+	}
+}
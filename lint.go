@@ -0,0 +1,117 @@
+package main
+
+import (
+	"io"
+	"regexp"
+	"strings"
+)
+
+// lintWriter canonicalizes Markdown as it is written: trailing whitespace is
+// dropped, runs of blank lines collapse to one, leading and trailing blank
+// lines go, and the output ends with exactly one newline. Fenced code blocks
+// pass through unchanged. A two-space hard line break after text is kept in
+// list items, and everywhere when hardBreaks is set.
+// Flush must be called once everything has been written.
+type lintWriter struct {
+	w       io.StringWriter
+	line    strings.Builder // current, unterminated line
+	blank   bool            // a blank line is pending before the next text
+	started bool            // a non-blank line has been written
+	inFence bool
+	inList  bool // the current line belongs to a list item
+
+	// hardBreaks keeps two-space hard line breaks outside lists too, for
+	// text that uses them on purpose (-paragraphs breaks).
+	hardBreaks bool
+}
+
+func newLintWriter(w io.StringWriter) *lintWriter {
+	return &lintWriter{w: w}
+}
+
+// WriteString implements io.StringWriter. Errors from the underlying writer
+// are left for it to report, as bufio.Writer does on Flush.
+func (l *lintWriter) WriteString(s string) (int, error) {
+	n := len(s)
+	for {
+		i := strings.IndexByte(s, '\n')
+		if i < 0 {
+			l.line.WriteString(s)
+			return n, nil
+		}
+		l.line.WriteString(s[:i])
+		l.endLine()
+		s = s[i+1:]
+	}
+}
+
+// Flush writes out a final unterminated line.
+func (l *lintWriter) Flush() {
+	if l.line.Len() > 0 {
+		l.endLine()
+	}
+}
+
+func (l *lintWriter) endLine() {
+	line := l.line.String()
+	l.line.Reset()
+
+	fence := strings.HasPrefix(strings.TrimLeft(line, " "), "```")
+	if !l.inFence && !fence {
+		l.trackList(line)
+		line = trimLineEnd(line, l.hardBreaks || l.inList)
+		if line == "" {
+			l.blank = l.started
+			return
+		}
+	}
+	if fence {
+		l.inFence = !l.inFence
+	}
+	if l.blank {
+		l.w.WriteString("\n")
+		l.blank = false
+	}
+	l.w.WriteString(line + "\n")
+	l.started = true
+}
+
+// listStartRe matches a line starting a Markdown list item, however indented.
+var listStartRe = regexp.MustCompile(`^\s*(?:[-*+]|\d+[.)])\s`)
+
+// trackList updates inList for line: a list item starts a list, indented
+// lines and blank lines continue it, and any other text ends it.
+func (l *lintWriter) trackList(line string) {
+	switch {
+	case listStartRe.MatchString(line):
+		l.inList = true
+	case strings.TrimSpace(line) == "", strings.HasPrefix(line, "  "):
+	default:
+		l.inList = false
+	}
+}
+
+// trimLineEnd drops trailing spaces and tabs. With keepBreak, a two-space
+// hard line break after text is kept.
+func trimLineEnd(line string, keepBreak bool) string {
+	trimmed := strings.TrimRight(line, " \t")
+	if keepBreak && trimmed != "" && line[len(trimmed):] == "  " {
+		return line
+	}
+	return trimmed
+}
+
+// cleanWhitespace applies the lintWriter rules to a whole document.
+func cleanWhitespace(s string) string {
+	return lintDocument(s, false)
+}
+
+// lintDocument is cleanWhitespace with lintWriter.hardBreaks set to hardBreaks.
+func lintDocument(s string, hardBreaks bool) string {
+	var sb strings.Builder
+	lw := newLintWriter(&sb)
+	lw.hardBreaks = hardBreaks
+	lw.WriteString(s)
+	lw.Flush()
+	return sb.String()
+}
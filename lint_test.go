@@ -0,0 +1,62 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLintHardBreaks(t *testing.T) {
+	tests := []struct {
+		name       string
+		in         string
+		hardBreaks bool
+		want       string
+	}{
+		{"paragraph", "text  \nmore\n", false, "text\nmore\n"},
+		{"list item", "- item  \n  continued\n", false, "- item  \n  continued\n"},
+		{"list continuation", "1. item\n\n   para  \n   line\n", false, "1. item\n\n   para  \n   line\n"},
+		{"after list", "- item\n\ntext  \nmore\n", false, "- item\n\ntext\nmore\n"},
+		{"hard breaks on", "text  \nmore\n", true, "text  \nmore\n"},
+		{"other trailing space", "a \nb\t\nc   \n", true, "a\nb\nc\n"},
+	}
+	for _, tt := range tests {
+		if got := lintDocument(tt.in, tt.hardBreaks); got != tt.want {
+			t.Errorf("%s: lintDocument(%q, %v) = %q, want %q", tt.name, tt.in, tt.hardBreaks, got, tt.want)
+		}
+	}
+}
+
+func TestCleanWhitespaceMessy(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"blank runs", "a\n\n\n\nb\n\n\n", "a\n\nb\n"},
+		{"trailing spaces", "a   \n\t\nb\t\n", "a\n\nb\n"},
+		{"leading blank lines", "\n\n  \na\n", "a\n"},
+		{"missing final newline", "a\nb", "a\nb\n"},
+		{"only whitespace", " \n\n\t\n", ""},
+		{"fenced code untouched", "```\ncode  \n\n\n\nmore\n```\n\n\n\nafter  ", "```\ncode  \n\n\n\nmore\n```\n\nafter\n"},
+		{"quote lines", "# Title\n\n> quote  \n>\n> more\n", "# Title\n\n> quote\n>\n> more\n"},
+	}
+	for _, tt := range tests {
+		if got := cleanWhitespace(tt.in); got != tt.want {
+			t.Errorf("%s: cleanWhitespace(%q) = %q, want %q", tt.name, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRenderLinted(t *testing.T) {
+	// Stray blank lines and trailing spaces in tweet text do not survive rendering.
+	tweet := testThread("First line   \n\n\n\n\nSecond line\n\n\n")[0]
+	got := RenderTweet(tweet, RenderOptions{})
+	if !strings.HasSuffix(got, "\nFirst line\n\nSecond line\n") {
+		t.Errorf("tweet body not linted:\n%q", got)
+	}
+	thread := testThread("One  \n\n\n", "\n\nTwo")
+	got = RenderThread(thread, RenderOptions{})
+	if strings.Contains(got, "\n\n\n") || strings.Contains(got, " \n") || !strings.HasSuffix(got, "Two\n") {
+		t.Errorf("thread not linted:\n%q", got)
+	}
+}
@@ -1,25 +1,102 @@
 package main
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"html"
 	"io"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
-	"time"
+	"text/template"
 )
 
 func main() {
-	outputFile := flag.String("o", "", "输出文件路径（默认 stdout）")
+	showVersion := flag.Bool("version", false, "打印版本信息并退出")
+	templatePath := flag.String("template", "", "使用 Go text/template 模板文件渲染，替代内置格式")
+	inputFile := flag.String("input", "", "从保存的 FxTwitter JSON 文件读取，而不是联网获取")
+	outputFile := flag.String("o", "", "输出文件路径（默认或 - 表示 stdout），支持 {author}、{id}、{date}、{type}、{slug} 占位符")
+	outputDir := flag.String("o-dir", "", "输出目录，按日期、作者和标题自动命名文件")
+	configPath := flag.String("config", "", "配置文件路径（默认 ~/.config/x2md/config.toml）")
+	noConfig := flag.Bool("no-config", false, "忽略配置文件")
+	appendOut := flag.Bool("append", false, "配合 -o，追加到已有文件末尾（--- 分隔，frontmatter 降级为小标题块）")
+	combine := flag.Bool("combine", false, "将多个 URL 的内容合并输出为一个文档")
 	thread := flag.Bool("thread", false, "展开整个线程（默认只提取单条）")
+	maxDepth := flag.Int("max-depth", maxThreadDepth, "线程模式下最多获取的推文条数")
 	images := flag.Bool("images", false, "下载图片到本地目录")
+	imageDir := flag.String("image-dir", "", "图片保存目录（默认 images/ 或 <输出文件名>_images/）")
+	imageBase := flag.String("image-base", "", "配合 -images，图片链接改为相对该目录的路径（通常为输出文件所在目录）")
+	maxImages := flag.Int("max-images", 0, "配合 -images，最多下载 N 张图片，其余保留远程链接（0 表示不限）")
+	imageLinks := flag.String("image-links", imageLinksMarkdown, "本地图片引用方式：markdown 或 wiki（Obsidian ![[...]]）")
+	imageNaming := flag.String("image-naming", imageNamingIndex, "下载图片的文件名：index（img_1.jpg 等序号）或 original（优先取 Content-Disposition，其次取 URL 中的文件名）")
+	format := flag.String("format", formatMarkdown, "输出格式：markdown、bundle（图片以 base64 内嵌为单文件）、org（Emacs Org-mode）或 txt（纯文本）")
+	threadStyle := flag.String("thread-style", ThreadStyleSeparated, "线程拼接方式：separated（--- 分隔）或 continuous（连续段落）")
+	threadOrder := flag.String("thread-order", ThreadOrderOldest, "线程输出顺序：oldest（从旧到新）或 newest（从新到旧）")
+	threadPermalinks := flag.Bool("thread-permalinks", false, "线程模式下为每条推文附加原文链接")
+	includeParent := flag.Bool("include-parent", false, "单条推文模式下，在正文前以引用块附上被回复的推文")
+	expandQuotes := flag.Bool("expand-quotes", false, "展开被引用推文之前的线程推文（折叠显示）")
+	aliases := flag.Bool("aliases", false, "frontmatter 中输出 aliases 列表（推文 ID 与 fixupx.com 短链）")
+	appendRaw := flag.Bool("append-raw", false, "在末尾附加折叠的原始 API JSON（便于排查渲染问题）")
+	normalize := flag.Bool("normalize", false, "去除零宽字符并将不换行空格转为普通空格")
+	markSensitive := flag.Bool("mark-sensitive", false, "将敏感内容的图片/视频折叠在 <details> 中")
+	cleanLinksFlag := flag.Bool("clean-links", false, "去除链接中的跟踪参数（见 -clean-params）")
+	cleanParams := flag.String("clean-params", defaultTrackingParams, "配合 -clean-links，要去除的查询参数，逗号分隔，支持 * 通配")
+	callouts := flag.Bool("callouts", false, "将以 Note:、Warning:、Tip: 等开头的引用块转为 GitHub/Obsidian callout（> [!NOTE]）")
+	calloutTypes := flag.String("callout-types", defaultCalloutTypes, "配合 -callouts，前缀到 callout 类型的映射，格式 前缀=类型，逗号分隔")
+	autolink := flag.Bool("autolink", false, "将正文中的裸 URL 包裹为 <...> 自动链接")
+	dedupeMedia := flag.Bool("dedupe-media", false, "线程模式下省略与前文重复的图片/视频")
+	noMedia := flag.Bool("no-media", false, "正文中不输出图片和视频（纯文本归档）")
+	readingTime := flag.Bool("reading-time", false, "文章 frontmatter 中输出预计阅读时间 reading_time（分钟）")
+	wpm := flag.Int("wpm", defaultWPM, "配合 -reading-time，每分钟阅读词数（中日韩按每 2 字计 1 词）")
+	flattenQuote := flag.Bool("flatten-quote", false, "将引用推文渲染为行内括注 (quoting @user: \"...\")，而不是引用块")
+	quoteLength := flag.Int("quote-length", 200, "配合 -flatten-quote，引用文字的最大字符数，超出以 … 截断")
+	preserveColor := flag.Bool("preserve-color", false, "保留文章中的彩色文字，输出为 <span style=\"color:...\">")
+	figure := flag.Bool("figure", false, "图片输出为 HTML <figure>，以 alt 文字作为 <figcaption>")
+	pollStyle := flag.String("poll-style", PollStyleBar, "投票样式：bar（默认，进度条）或 table（Markdown 表格，领先选项加粗）")
+	titleFlag := flag.String("title", "", "覆盖文章标题（frontmatter 与一级标题）；推文与线程则在 frontmatter 中添加 title，也用于 -o-dir 文件名")
+	descriptionLength := flag.Int("description-length", 160, "文章 frontmatter 中 description（取自预览文字）的最大字符数")
+	shortURL := flag.Bool("short-url", false, "frontmatter 中输出 short_url（fixupx.com 链接，便于生成富预览）")
+	paragraphs := flag.String("paragraphs", "", "推文正文中的单个换行：breaks（行尾硬换行）或 blank（空行分段），默认原样输出")
+	footnotes := flag.Bool("footnotes", false, "文章末尾为链接列表时，将正文中的引用（[1] 或指向同一链接）转为 Markdown 脚注")
+	linkEntitiesFlag := flag.Bool("link-entities", false, "将正文与文章中的 @提及 和 #话题 转为指向 x.com 的链接")
+	frontmatterStyle := flag.String("frontmatter", FrontmatterBlock, "frontmatter 样式：block（默认，多行 YAML）或 compact（单行 YAML flow mapping）")
+	frontmatterSort := flag.Bool("frontmatter-sort", false, "frontmatter 字段按键名字母顺序输出")
+	bodyOnly := flag.Bool("body-only", false, "只输出正文：不含 frontmatter、文章标题与封面，推文只含正文与媒体")
+	noStats := flag.Bool("no-stats", false, "frontmatter 中不输出点赞、转发、回复、浏览、收藏数")
+	timeout := flag.Duration("timeout", httpTimeout, "API 请求超时")
+	maxFileMB := flag.Int64("max-file-size", defaultMaxFileSize>>20, "单个图片/媒体文件大小上限（MB，0 表示不限）")
+	dlTimeout := flag.Duration("download-timeout", httpTimeout, "图片/媒体下载超时")
+	reqUserAgent := flag.String("user-agent", userAgent, "API 请求与图片下载使用的 User-Agent")
+	var headers headerFlag
+	flag.Var(&headers, "header", "FxTwitter API 请求附加的 HTTP 头 \"Name: value\"（可重复）")
+	sendMediaHeaders := flag.Bool("media-headers", false, "图片下载时也发送 -header 指定的 HTTP 头")
+	rps := flag.Float64("rps", defaultRPS, "每秒最多 API 请求数（0 表示不限速）")
+	colorMode := flag.String("color", colorAuto, "stderr 警告/错误着色：auto（默认，终端且未设置 NO_COLOR 时）、always 或 never")
+	jsonOut := flag.Bool("json", false, "以 JSON 输出：成功时为 {\"contents\": [...], \"markdown\": ...}，失败时向 stdout 输出 {\"error\": {...}, \"code\": 退出码}")
+	showProgress := flag.Bool("progress", false, "在 stderr 显示获取进度（URL 序号与线程已获取条数；stderr 不是终端时自动关闭）")
+	stripSelfMentions := flag.Bool("strip-self-mentions", false, "线程模式下去掉后续推文开头对作者自己的 @ 提及")
+	var meta metaFlag
+	flag.Var(&meta, "meta", "追加 frontmatter 字段 key=value（可重复，可覆盖内置字段）")
+	source := flag.String("source", SourceFxTwitter, "内容来源：fxtwitter（默认，X/Twitter）、bluesky（bsky.app 帖子）或 mastodon（https://实例/@用户/ID 嘟文）")
+	translate := flag.String("translate", "", "将单条推文翻译为指定语言（如 zh、en），无法翻译时使用原文")
+	keepOriginal := flag.Bool("keep-original", false, "配合 -translate，在译文下方以引用块保留原文")
+	since := flag.String("since", "", "线程模式下只输出该日期及之后的推文（YYYY-MM-DD 或 RFC 3339 时间）")
+	until := flag.String("until", "", "线程模式下只输出该日期及之前的推文（YYYY-MM-DD 当天包含在内，或 RFC 3339 时间）")
+	renumber := flag.Bool("renumber", false, "线程模式下去掉作者手写的 \"1/\" 编号，统一输出 \"## N.\" 小标题")
 
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "x2md — 将 X (Twitter) 内容提取为 Markdown\n\n")
-		fmt.Fprintf(os.Stderr, "用法:\n  x2md <url> [flags]\n\n")
+		fmt.Fprintf(os.Stderr, "用法:\n  x2md [flags] <url> [<url>...]\n  x2md -version\n\n")
 		fmt.Fprintf(os.Stderr, "Flags:\n")
 		flag.PrintDefaults()
 		fmt.Fprintf(os.Stderr, "\n示例:\n")
@@ -27,142 +104,791 @@ func main() {
 		fmt.Fprintf(os.Stderr, "  x2md -thread https://x.com/user/status/123456\n")
 		fmt.Fprintf(os.Stderr, "  x2md -o output.md https://x.com/user/status/123456\n")
 		fmt.Fprintf(os.Stderr, "  x2md https://x.com/user/article/123456\n")
+		fmt.Fprintf(os.Stderr, "  x2md -input saved.json\n")
+		fmt.Fprintf(os.Stderr, "  x2md -combine -o all.md <url1> <url2>\n")
 	}
 
 	flag.Parse()
+	jsonErrors = *jsonOut
 
-	if flag.NArg() < 1 {
-		fmt.Fprintln(os.Stderr, "错误: 请提供 X (Twitter) URL")
-		flag.Usage()
-		os.Exit(1)
+	if *showVersion {
+		fmt.Println(versionString())
+		return
 	}
 
-	rawURL := flag.Arg(0)
+	// Config file values act as defaults; command-line flags take precedence.
+	if !*noConfig {
+		path := *configPath
+		if path == "" {
+			path = defaultConfigPath()
+		}
+		values, err := loadConfig(path)
+		if err != nil && (*configPath != "" || !os.IsNotExist(err)) {
+			fatalf(1, "读取配置文件失败: %v", err)
+		}
+		if err := applyConfig(flag.CommandLine, values); err != nil {
+			fatalf(1, "配置文件无效: %v", err)
+		}
+	}
+	jsonErrors = *jsonOut
+
+	if *colorMode != colorAuto && *colorMode != colorAlways && *colorMode != colorNever {
+		fatalf(1, "不支持的 -color: %s", *colorMode)
+	}
+	stderrColor = useColor(*colorMode, os.Stderr)
+
+	if *inputFile != "" {
+		if *thread {
+			fatalf(1, "-input 不支持 -thread")
+		}
+	} else {
+		if flag.NArg() < 1 {
+			flag.Usage()
+			fatalf(1, "请提供 X (Twitter) URL")
+		}
+		if flag.NArg() > 1 && !*combine {
+			fatalf(1, "多个 URL 需配合 -combine 使用")
+		}
+	}
+
+	if *includeParent && *thread {
+		fatalf(1, "-include-parent 不支持 -thread（线程模式已包含上文）")
+	}
+
+	if *source != SourceFxTwitter && *source != SourceBluesky && *source != SourceMastodon {
+		fatalf(1, "不支持的 -source: %s", *source)
+	}
+	if *source != SourceFxTwitter && (*translate != "" || *inputFile != "") {
+		fatalf(1, "-source %s 不支持 -translate 与 -input", *source)
+	}
+
+	if *translate != "" && *thread {
+		fatalf(1, "-translate 不支持 -thread")
+	}
+
+	if *threadStyle != ThreadStyleSeparated && *threadStyle != ThreadStyleContinuous {
+		fatalf(1, "不支持的 -thread-style: %s", *threadStyle)
+	}
+
+	if *threadOrder != ThreadOrderOldest && *threadOrder != ThreadOrderNewest {
+		fatalf(1, "不支持的 -thread-order: %s", *threadOrder)
+	}
+
+	if *pollStyle != PollStyleBar && *pollStyle != PollStyleTable {
+		fatalf(1, "不支持的 -poll-style: %s", *pollStyle)
+	}
+
+	if *paragraphs != "" && *paragraphs != ParagraphsBreaks && *paragraphs != ParagraphsBlank {
+		fatalf(1, "不支持的 -paragraphs: %s", *paragraphs)
+	}
+
+	if *frontmatterStyle != FrontmatterBlock && *frontmatterStyle != FrontmatterCompact {
+		fatalf(1, "不支持的 -frontmatter: %s", *frontmatterStyle)
+	}
 
-	info, err := ParseURL(rawURL)
+	if *imageLinks != imageLinksMarkdown && *imageLinks != imageLinksWiki {
+		fatalf(1, "不支持的 -image-links: %s", *imageLinks)
+	}
+	if *imageNaming != imageNamingIndex && *imageNaming != imageNamingOriginal {
+		fatalf(1, "不支持的 -image-naming: %s", *imageNaming)
+	}
+
+	if *quoteLength <= 0 {
+		fatalf(1, "-quote-length 必须大于 0")
+	}
+	if *descriptionLength <= 0 {
+		fatalf(1, "-description-length 必须大于 0")
+	}
+	if *maxDepth <= 0 {
+		fatalf(1, "-max-depth 必须大于 0")
+	}
+	if *wpm <= 0 {
+		fatalf(1, "-wpm 必须大于 0")
+	}
+	sinceTime, err := parseDateBound(*since, false)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
-		os.Exit(1)
+		fatalf(1, "-since 无效: %v", err)
+	}
+	untilTime, err := parseDateBound(*until, true)
+	if err != nil {
+		fatalf(1, "-until 无效: %v", err)
+	}
+	if !sinceTime.IsZero() && !untilTime.IsZero() && !sinceTime.Before(untilTime) {
+		fatalf(1, "-since 必须早于 -until")
+	}
+	if (*since != "" || *until != "") && !*thread {
+		fatalf(1, "-since 与 -until 需配合 -thread 使用")
 	}
 
-	var markdown string
+	if *maxFileMB < 0 {
+		fatalf(1, "-max-file-size 不能为负数")
+	}
 
-	switch info.Type {
-	case URLTypeArticle:
-		tweet, err := FetchArticle(info.ScreenName, info.ID)
+	if *maxImages < 0 {
+		fatalf(1, "-max-images 不能为负数")
+	}
+
+	if *outputFile != "" && *outputDir != "" {
+		fatalf(1, "-o 与 -o-dir 不能同时使用")
+	}
+
+	if *appendOut && (*outputFile == "" || *outputFile == stdoutPath) {
+		fatalf(1, "-append 需配合 -o 使用")
+	}
+	if *appendOut && *jsonOut {
+		fatalf(1, "-append 不支持 -json")
+	}
+
+	if *format != formatMarkdown && *format != formatBundle && *format != formatOrg && *format != formatTxt {
+		fatalf(1, "不支持的 -format: %s", *format)
+	}
+	if *format == formatOrg && *appendOut {
+		fatalf(1, "-append 不支持 -format org")
+	}
+
+	apiLimiter = newRateLimiter(*rps)
+	if *showProgress {
+		fetchProgress = newProgress(os.Stderr)
+	}
+	apiTimeout = *timeout
+	downloadTimeout = *dlTimeout
+	maxFileSize = *maxFileMB << 20
+	requestUserAgent = *reqUserAgent
+	requestHeaders = http.Header(headers)
+	mediaHeaders = *sendMediaHeaders
+
+	opts := RenderOptions{
+		Renumber:          *renumber,
+		ThreadStyle:       *threadStyle,
+		ThreadOrder:       *threadOrder,
+		ThreadPermalinks:  *threadPermalinks,
+		NoStats:           *noStats,
+		Autolink:          *autolink,
+		MarkSensitive:     *markSensitive,
+		AppendRaw:         *appendRaw,
+		Normalize:         *normalize,
+		Aliases:           *aliases,
+		StripSelfMentions: *stripSelfMentions,
+		KeepOriginal:      *keepOriginal,
+		NoMedia:           *noMedia,
+		DedupeMedia:       *dedupeMedia,
+		Meta:              meta,
+		PreserveColor:     *preserveColor,
+		Title:             *titleFlag,
+		ShortURL:          *shortURL,
+		LinkEntities:      *linkEntitiesFlag,
+		Frontmatter:       *frontmatterStyle,
+		DescriptionLength: *descriptionLength,
+		Figure:            *figure,
+		SortFrontmatter:   *frontmatterSort,
+		PollStyle:         *pollStyle,
+		Footnotes:         *footnotes,
+		Paragraphs:        *paragraphs,
+		BodyOnly:          *bodyOnly,
+		Since:             sinceTime,
+		Until:             untilTime,
+	}
+	if *readingTime {
+		opts.ReadingWPM = *wpm
+	}
+	if *flattenQuote {
+		opts.FlattenQuote = *quoteLength
+	}
+	if *cleanLinksFlag {
+		opts.CleanParams = parseParamPatterns(*cleanParams)
+	}
+	if *callouts {
+		types, err := parseCalloutTypes(*calloutTypes)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "错误: 获取文章失败: %v\n", err)
-			os.Exit(1)
+			fatalf(1, "无效的 -callout-types: %v", err)
 		}
-		markdown = RenderArticle(tweet, info)
+		opts.Callouts = types
+	}
 
-	case URLTypeTweet:
-		if *thread {
-			tweets, err := FetchThread(info.ScreenName, info.ID)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "错误: 获取线程失败: %v\n", err)
-				os.Exit(1)
+	fo := fetchOptions{
+		Thread:        *thread,
+		MaxDepth:      *maxDepth,
+		ExpandQuotes:  *expandQuotes,
+		Translate:     *translate,
+		IncludeParent: *includeParent,
+		Source:        *source,
+	}
+
+	var tmpl *template.Template
+	if *templatePath != "" {
+		var err error
+		tmpl, err = LoadTemplate(*templatePath)
+		if err != nil {
+			fatalf(1, "读取模板失败: %v", err)
+		}
+	}
+
+	var contents []*Content
+	// interrupted records a Ctrl-C during fetching; whatever was fetched is
+	// still written before exiting non-zero.
+	interrupted := false
+	if *inputFile != "" {
+		tweet, err := LoadTweetFile(*inputFile)
+		if err != nil {
+			fatalf(1, "读取输入文件失败: %v", err)
+		}
+		contents = append(contents, newTweetContent(tweet, tweetURLInfo(tweet)))
+	} else {
+		// Ctrl-C cancels in-flight requests so long thread fetches stop promptly.
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		for i, rawURL := range flag.Args() {
+			fetchProgress.StartItem(i+1, flag.NArg())
+			c, err := FetchContent(ctx, rawURL, fo)
+			fetchProgress.Clear()
+			if ctx.Err() != nil {
+				interrupted = true
+				if c != nil {
+					contents = append(contents, c)
+					if err != nil {
+						warnf("已中断，仅输出已获取的 %d 条推文", len(c.Tweets))
+					}
+				}
+				break
 			}
-			markdown = RenderThread(tweets)
-		} else {
-			tweet, err := FetchTweet(info.ScreenName, info.ID)
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "错误: 获取推文失败: %v\n", err)
-				os.Exit(1)
+				if !*combine {
+					info := errorInfo{Message: err.Error(), URL: rawURL}
+					var apiErr *APIError
+					if errors.As(err, &apiErr) {
+						info.APICode = apiErr.Code
+					}
+					exitWithError(1, info)
+				}
+				warnf("跳过 %s: %v", rawURL, err)
+				continue
 			}
-			// Auto-detect: if tweet contains an article, render as article
-			if tweet.Article != nil && tweet.Article.Content != nil {
-				markdown = RenderArticle(tweet, info)
-			} else {
-				markdown = RenderTweet(tweet)
+			if c.Type != ContentThread && (*since != "" || *until != "") {
+				warnf("%s 不是线程，忽略 -since/-until", rawURL)
+			} else if c.Type == ContentThread && len(tweetsBetween(c.Tweets, opts.Since, opts.Until)) == 0 {
+				warnf("%s 中没有 -since/-until 范围内的推文", rawURL)
 			}
+			contents = append(contents, c)
+		}
+		// A second Ctrl-C while writing output terminates immediately.
+		stop()
+		if interrupted && len(contents) == 0 {
+			fatalf(130, "已中断")
+		}
+		if len(contents) == 0 {
+			fatalf(1, "没有成功获取任何内容")
+		}
+	}
+
+	if opts.BodyOnly {
+		for _, c := range contents {
+			if c.Type == ContentArticle && articleUnavailable(c.Tweet.Article) {
+				warnf("文章 %s 的正文不可用（API 未返回内容与预览），-body-only 仅输出占位文字", c.Info.ID)
+			}
+		}
+	}
+
+	// primary is the tweet used to name the output file with -o-dir.
+	primary := contents[0].Tweet
+
+	outputPath := *outputFile
+	if outputPath == stdoutPath {
+		outputPath = ""
+	}
+	if outputPath != "" {
+		outputPath = expandOutputPath(outputPath, contents[0], *titleFlag)
+	}
+	if *outputDir != "" {
+		if err := os.MkdirAll(*outputDir, 0755); err != nil {
+			fatalf(1, "无法创建输出目录 %s: %v", *outputDir, err)
+		}
+		name := autoFilename(primary, *titleFlag)
+		switch *format {
+		case formatOrg:
+			name = strings.TrimSuffix(name, ".md") + ".org"
+		case formatTxt:
+			name = strings.TrimSuffix(name, ".md") + ".txt"
+		}
+		outputPath = uniquePath(*outputDir, name)
+	}
+
+	// A single document that needs no post-processing is streamed to the
+	// output instead of being built in memory first.
+	if len(contents) == 1 && tmpl == nil && !*appendOut && !*combine && !*images && !*jsonOut && *format == formatMarkdown {
+		writeOutput(outputPath, func(w io.Writer) error {
+			return contents[0].Write(w, opts)
+		})
+		exitIfInterrupted(interrupted)
+		return
+	}
+
+	var docs []string
+	for _, c := range contents {
+		if tmpl == nil {
+			docs = append(docs, c.Render(opts))
+			continue
+		}
+		md, err := RenderTemplate(tmpl, c, opts)
+		if err != nil {
+			fatalf(1, "模板渲染失败: %v", err)
 		}
+		docs = append(docs, md)
 	}
 
-	// Download images if requested
-	if *images && markdown != "" {
+	markdown := docs[0]
+	if *combine {
+		markdown = lintDocument(CombineDocuments(docs), opts.Paragraphs == ParagraphsBreaks)
+	}
+
+	// Download images if requested; a bundle embeds them instead
+	if *format == formatBundle && markdown != "" {
+		markdown = embedImages(markdown)
+	} else if *images && markdown != "" {
 		imgDir := "images"
-		if *outputFile != "" {
-			imgDir = strings.TrimSuffix(*outputFile, filepath.Ext(*outputFile)) + "_images"
+		if *imageDir != "" {
+			imgDir = *imageDir
+		} else if outputPath != "" {
+			imgDir = strings.TrimSuffix(outputPath, filepath.Ext(outputPath)) + "_images"
 		}
-		markdown = downloadAndReplaceImages(markdown, imgDir)
+		if outputPath == "" {
+			warnf("Markdown 输出到 stdout，图片仍保存到 %s", imgDir)
+		}
+		// Appending must not overwrite images saved by earlier runs.
+		markdown = downloadAndReplaceImages(markdown, imgDir, *imageBase, *imageLinks, *imageNaming, *maxImages, *appendOut)
 	}
 
-	// Output
-	if *outputFile != "" {
-		if err := os.WriteFile(*outputFile, []byte(markdown), 0644); err != nil {
-			fmt.Fprintf(os.Stderr, "错误: 写入文件失败: %v\n", err)
-			os.Exit(1)
+	switch *format {
+	case formatOrg:
+		markdown = MarkdownToOrg(markdown)
+	case formatTxt:
+		markdown = MarkdownToText(markdown)
+	}
+
+	if *appendOut {
+		existing, err := os.ReadFile(outputPath)
+		if err != nil && !os.IsNotExist(err) {
+			fatalf(1, "读取文件失败: %v", err)
 		}
-		fmt.Fprintf(os.Stderr, "已保存到 %s\n", *outputFile)
-	} else {
-		fmt.Print(markdown)
+		markdown = appendDocument(string(existing), markdown)
 	}
+	if *jsonOut {
+		markdown = jsonDocument(contents, markdown)
+	}
+
+	writeOutput(outputPath, func(w io.Writer) error {
+		_, err := io.WriteString(w, markdown)
+		return err
+	})
+	exitIfInterrupted(interrupted)
 }
 
-var mdImageRe = regexp.MustCompile(`!\[([^\]]*)\]\((https?://[^)]+)\)`)
+// jsonDocument wraps the rendered Markdown for -json output, along with what
+// each piece of content is and where it came from.
+func jsonDocument(contents []*Content, markdown string) string {
+	type item struct {
+		Type string `json:"type"`
+		ID   string `json:"id"`
+		URL  string `json:"url"`
+	}
+	doc := struct {
+		Contents []item `json:"contents"`
+		Markdown string `json:"markdown"`
+	}{Contents: []item{}, Markdown: markdown}
+	for _, c := range contents {
+		doc.Contents = append(doc.Contents, item{Type: c.Type, ID: c.Info.ID, URL: c.Info.OriginalURL})
+	}
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		fatalf(1, "生成 JSON 失败: %v", err)
+	}
+	return string(data) + "\n"
+}
 
-// downloadAndReplaceImages downloads images found in Markdown and replaces URLs with local paths.
-func downloadAndReplaceImages(markdown, imgDir string) string {
-	matches := mdImageRe.FindAllStringSubmatch(markdown, -1)
+// exitIfInterrupted exits with the conventional SIGINT status once partial
+// output has been written.
+func exitIfInterrupted(interrupted bool) {
+	if interrupted {
+		os.Exit(130)
+	}
+}
+
+// writeOutput runs write against the output file, or stdout when path is empty.
+func writeOutput(path string, write func(w io.Writer) error) {
+	if path == "" {
+		if err := write(os.Stdout); err != nil {
+			fatalf(1, "输出失败: %v", err)
+		}
+		return
+	}
+	if err := writeToFile(path, write); err != nil {
+		fatalf(1, "写入文件失败: %v", err)
+	}
+	fmt.Fprintf(os.Stderr, "已保存到 %s\n", path)
+}
+
+// metaFlag collects repeated -meta key=value pairs in command-line order.
+type metaFlag []MetaField
+
+var metaKeyRe = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+func (m *metaFlag) String() string {
+	if m == nil {
+		return ""
+	}
+	pairs := make([]string, len(*m))
+	for i, f := range *m {
+		pairs[i] = f.Key + "=" + f.Value
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (m *metaFlag) Set(s string) error {
+	key, value, ok := strings.Cut(s, "=")
+	key = strings.TrimSpace(key)
+	if !ok || key == "" {
+		return fmt.Errorf("expected key=value, got %q", s)
+	}
+	if !metaKeyRe.MatchString(key) {
+		return fmt.Errorf("invalid key %q: use letters, digits, '_' or '-'", key)
+	}
+	*m = append(*m, MetaField{Key: key, Value: value})
+	return nil
+}
+
+// headerFlag collects repeated -header "Name: value" pairs.
+type headerFlag http.Header
+
+func (h *headerFlag) String() string {
+	if h == nil {
+		return ""
+	}
+	var pairs []string
+	for name, values := range *h {
+		for _, v := range values {
+			pairs = append(pairs, name+": "+v)
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ", ")
+}
+
+func (h *headerFlag) Set(s string) error {
+	name, value, ok := strings.Cut(s, ":")
+	name = strings.TrimSpace(name)
+	if !ok || name == "" || strings.ContainsAny(name, " \t") {
+		return fmt.Errorf("expected \"Name: value\", got %q", s)
+	}
+	if *h == nil {
+		*h = make(headerFlag)
+	}
+	http.Header(*h).Add(name, strings.TrimSpace(value))
+	return nil
+}
+
+// stdoutPath is the -o value that explicitly selects standard output.
+const stdoutPath = "-"
+
+// imageRe matches Markdown images and the <img> tags of -figure output.
+var imageRe = regexp.MustCompile(`!\[([^\]]*)\]\((https?://[^)]+)\)|<img src="(https?://[^"]+)" alt="([^"]*)">`)
+
+// imageMatch is an image found in rendered output.
+type imageMatch struct {
+	full string // the whole Markdown image or <img> tag
+	alt  string
+	url  string
+	html bool // an <img> tag, whose attributes are HTML-escaped
+}
+
+// findImages returns the images in rendered output, in document order.
+func findImages(doc string) []imageMatch {
+	var images []imageMatch
+	for _, m := range imageRe.FindAllStringSubmatch(doc, -1) {
+		if m[3] != "" {
+			images = append(images, imageMatch{full: m[0], alt: m[4], url: html.UnescapeString(m[3]), html: true})
+		} else {
+			images = append(images, imageMatch{full: m[0], alt: m[1], url: m[2]})
+		}
+	}
+	return images
+}
+
+// withURL returns the image reference pointing at url instead.
+func (m imageMatch) withURL(url string) string {
+	if m.html {
+		return fmt.Sprintf(`<img src="%s" alt="%s">`, html.EscapeString(url), m.alt)
+	}
+	return fmt.Sprintf("![%s](%s)", m.alt, url)
+}
+
+// Output formats accepted by -format.
+const (
+	formatMarkdown = "markdown"
+	formatBundle   = "bundle"
+	formatOrg      = "org"
+	formatTxt      = "txt"
+)
+
+// bundleWarnSize is the embedded image size above which a bundle triggers a warning.
+const bundleWarnSize = 10 << 20
+
+// embedImages downloads images found in Markdown and inlines them as base64
+// data URIs, producing a single self-contained document.
+func embedImages(markdown string) string {
+	matches := findImages(markdown)
 	if len(matches) == 0 {
 		return markdown
 	}
 
-	if err := os.MkdirAll(imgDir, 0755); err != nil {
-		fmt.Fprintf(os.Stderr, "警告: 无法创建图片目录 %s: %v\n", imgDir, err)
+	tmpDir, err := os.MkdirTemp("", "x2md-bundle-")
+	if err != nil {
+		warnf("无法创建临时目录: %v", err)
 		return markdown
 	}
+	defer os.RemoveAll(tmpDir)
 
+	total := 0
 	for i, match := range matches {
-		fullMatch := match[0]
-		alt := match[1]
-		imgURL := match[2]
+		imgURL := match.url
 
-		ext := filepath.Ext(imgURL)
-		if ext == "" || len(ext) > 5 {
-			ext = ".jpg"
+		tmpPath := filepath.Join(tmpDir, fmt.Sprintf("img_%d", i+1))
+		if _, _, err := downloadFile(imgURL, tmpPath); err != nil {
+			warnf("下载图片失败 %s: %v", imgURL, err)
+			continue
 		}
-		// Clean extension (remove query params)
-		if idx := strings.Index(ext, "?"); idx != -1 {
-			ext = ext[:idx]
+		data, err := os.ReadFile(tmpPath)
+		if err != nil {
+			warnf("读取图片失败 %s: %v", imgURL, err)
+			continue
 		}
+		total += len(data)
+
+		dataURI := "data:" + http.DetectContentType(data) + ";base64," + base64.StdEncoding.EncodeToString(data)
+		markdown = strings.Replace(markdown, match.full, match.withURL(dataURI), 1)
+	}
+
+	if total > bundleWarnSize {
+		warnf("内嵌图片共 %.1f MB，生成的文件较大", float64(total)/(1<<20))
+	}
+
+	return markdown
+}
+
+// Local image reference styles accepted by -image-links.
+const (
+	imageLinksMarkdown = "markdown"
+	imageLinksWiki     = "wiki"
+)
+
+// Downloaded image naming schemes accepted by -image-naming.
+const (
+	imageNamingIndex    = "index"
+	imageNamingOriginal = "original"
+)
+
+// downloadAndReplaceImages downloads images found in Markdown and replaces URLs with local paths.
+// With linkStyle imageLinksWiki the references become Obsidian embeds (![[path]]).
+// With naming imageNamingOriginal files keep the server's or URL's file name
+// (see originalFilename) instead of being numbered img_1, img_2, ...
+// With maxImages > 0, images past the first maxImages keep their remote URLs.
+// Original names never overwrite existing files, so images sharing a name in
+// one run all survive. Index names are rewritten in place on a rerun unless
+// keepExisting is set, as when appending to earlier output.
+func downloadAndReplaceImages(markdown, imgDir, imageBase, linkStyle, naming string, maxImages int, keepExisting bool) string {
+	matches := findImages(markdown)
+	if len(matches) == 0 {
+		return markdown
+	}
+
+	if err := os.MkdirAll(imgDir, 0755); err != nil {
+		warnf("无法创建图片目录 %s: %v", imgDir, err)
+		return markdown
+	}
 
-		filename := fmt.Sprintf("img_%d%s", i+1, ext)
-		localPath := filepath.Join(imgDir, filename)
+	if maxImages > 0 && len(matches) > maxImages {
+		fmt.Fprintf(os.Stderr, "已达 -max-images 上限，跳过 %d 张图片（保留远程链接）\n", len(matches)-maxImages)
+		matches = matches[:maxImages]
+	}
+
+	downloaded := make(map[string]manifestEntry)
+	for i, match := range matches {
+		imgURL := match.url
+
+		dest := func(h http.Header) string {
+			filename := originalFilename(imgURL, h)
+			if naming == imageNamingOriginal {
+				return uniquePath(imgDir, filename)
+			}
+			filename = fmt.Sprintf("img_%d%s", i+1, filepath.Ext(filename))
+			if keepExisting {
+				return uniquePath(imgDir, filename)
+			}
+			return filepath.Join(imgDir, filename)
+		}
 
-		if err := downloadFile(imgURL, localPath); err != nil {
-			fmt.Fprintf(os.Stderr, "警告: 下载图片失败 %s: %v\n", imgURL, err)
+		localPath, size, sum, err := downloadMedia(imgURL, dest)
+		if err != nil {
+			warnf("下载图片失败 %s: %v", imgURL, err)
 			continue
 		}
+		width, height := imageSize(localPath)
+		alt := match.alt
+		if match.html {
+			alt = html.UnescapeString(alt)
+		}
+		downloaded[imgURL] = manifestEntry{
+			Path: filepath.Base(localPath), SHA256: sum, Size: size,
+			Width: width, Height: height, Alt: alt,
+		}
 
-		newRef := fmt.Sprintf("![%s](%s)", alt, localPath)
-		markdown = strings.Replace(markdown, fullMatch, newRef, 1)
+		ref := imageRef(localPath, imageBase)
+		newRef := match.withURL(ref)
+		if linkStyle == imageLinksWiki && !match.html {
+			newRef = fmt.Sprintf("![[%s]]", ref)
+		}
+		markdown = strings.Replace(markdown, match.full, newRef, 1)
 		fmt.Fprintf(os.Stderr, "已下载: %s\n", localPath)
 	}
 
+	if len(downloaded) > 0 {
+		manifest, err := updateManifest(imgDir, downloaded)
+		if err != nil {
+			warnf("写入 %s 失败: %v", manifestName, err)
+		} else if err := writeMediaIndex(imgDir, manifest); err != nil {
+			warnf("写入 %s 失败: %v", indexName, err)
+		}
+	}
+
 	return markdown
 }
 
-func downloadFile(url, destPath string) error {
-	client := &http.Client{Timeout: 30 * time.Second}
+// imageRef returns the link to a downloaded image: localPath relative to base
+// when base is set (e.g. "../../static/images/img_1.jpg" for a base of
+// content/posts), otherwise localPath as is.
+func imageRef(localPath, base string) string {
+	if base == "" {
+		return localPath
+	}
+	rel, err := relativePath(base, localPath)
+	if err != nil {
+		warnf("无法计算 %s 相对于 %s 的路径: %v", localPath, base, err)
+		return localPath
+	}
+	return filepath.ToSlash(rel)
+}
+
+// relativePath is filepath.Rel for paths that may mix absolute and relative forms.
+func relativePath(base, target string) (string, error) {
+	absBase, err := filepath.Abs(base)
+	if err != nil {
+		return "", err
+	}
+	absTarget, err := filepath.Abs(target)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Rel(absBase, absTarget)
+}
+
+// writeToFile atomically replaces path with what write produces, creating
+// missing parent directories.
+func writeToFile(path string, write func(w io.Writer) error) error {
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("creating directory %s: %w", dir, err)
+		}
+	}
+	return atomicWrite(path, write)
+}
+
+// atomicWrite writes to a temporary file next to path and renames it into
+// place once write succeeds, so an interrupted run never leaves a truncated
+// file behind. A replaced file keeps its permissions; a new one gets 0644.
+func atomicWrite(path string, write func(w io.Writer) error) (err error) {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
 
-	resp, err := client.Get(url)
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
 	if err != nil {
 		return err
 	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	if err = write(tmp); err != nil {
+		return err
+	}
+	if err = tmp.Chmod(mode); err != nil {
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// defaultMaxFileSize is the -max-file-size default, in bytes.
+const defaultMaxFileSize = 50 << 20
+
+// maxFileSize is the largest download accepted, in bytes, settable via
+// -max-file-size; 0 means no limit.
+var maxFileSize int64 = defaultMaxFileSize
+
+// downloadFile saves url to destPath and returns the file's size and SHA-256.
+// Responses that are not images or videos, or are larger than maxFileSize,
+// are rejected without leaving a file behind.
+func downloadFile(url, destPath string) (int64, string, error) {
+	_, size, sum, err := downloadMedia(url, func(http.Header) string { return destPath })
+	return size, sum, err
+}
+
+// downloadMedia is downloadFile with the destination chosen by dest from the
+// response headers once the response is accepted. It also returns the path
+// written.
+func downloadMedia(url string, dest func(h http.Header) string) (string, int64, string, error) {
+	client := newHTTPClient(downloadTimeout)
+
+	req, err := newRequest(context.Background(), url, true)
+	if err != nil {
+		return "", 0, "", err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", 0, "", err
+	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("HTTP %d", resp.StatusCode)
+		return "", 0, "", fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "image/") && !strings.HasPrefix(ct, "video/") {
+		return "", 0, "", fmt.Errorf("unexpected content type %q", ct)
+	}
+	if maxFileSize > 0 && resp.ContentLength > maxFileSize {
+		return "", 0, "", fmt.Errorf("file is %d bytes, over the %d byte limit", resp.ContentLength, maxFileSize)
 	}
 
-	out, err := os.Create(destPath)
-	if err != nil {
+	body := io.Reader(resp.Body)
+	if maxFileSize > 0 {
+		// One byte past the limit tells an oversized body from one that fits.
+		body = io.LimitReader(resp.Body, maxFileSize+1)
+	}
+	hash := sha256.New()
+	var size int64
+	destPath := dest(resp.Header)
+	err = atomicWrite(destPath, func(w io.Writer) error {
+		size, err = io.Copy(io.MultiWriter(w, hash), body)
+		if err == nil && maxFileSize > 0 && size > maxFileSize {
+			err = fmt.Errorf("file is over the %d byte limit", maxFileSize)
+		}
 		return err
+	})
+	if err != nil {
+		return "", 0, "", err
 	}
-	defer out.Close()
-
-	_, err = io.Copy(out, resp.Body)
-	return err
+	return destPath, size, hex.EncodeToString(hash.Sum(nil)), nil
 }
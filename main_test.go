@@ -0,0 +1,550 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// runMainEnv, when set, makes the test binary run main with its arguments
+// instead of the tests, so a test can run x2md as a subprocess.
+const runMainEnv = "X2MD_TEST_RUN_MAIN"
+
+// fxTwitterBaseEnv, when set, points a subprocess's FxTwitter client at a
+// test server.
+const fxTwitterBaseEnv = "X2MD_TEST_FXTWITTER_BASE"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(runMainEnv) == "1" {
+		if base := os.Getenv(fxTwitterBaseEnv); base != "" {
+			fxTwitterBase = base
+		}
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runX2MD runs x2md with args in a subprocess, returning its stdout, stderr
+// and exit code.
+func runX2MD(t *testing.T, args ...string) (stdout, stderr string, code int) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Env = append(os.Environ(), runMainEnv+"=1", "HOME="+t.TempDir())
+	var out, errOut bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &out, &errOut
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		t.Fatal(err)
+	}
+	return out.String(), errOut.String(), cmd.ProcessState.ExitCode()
+}
+
+func TestVersionFlag(t *testing.T) {
+	stdout, stderr, code := runX2MD(t, "-version")
+	if code != 0 {
+		t.Fatalf("x2md -version exited %d: %s", code, stderr)
+	}
+	if !regexp.MustCompile(`^x2md \d+\.\d+\.\d+(-[0-9A-Za-z.-]+)? \(commit \S+, built \S+\)\n$`).MatchString(stdout) {
+		t.Errorf("x2md -version printed %q, want a semver version line", stdout)
+	}
+}
+
+// testPNG returns a w×h PNG image.
+func testPNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, w, h))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// serveBytes starts a server answering every request with body as contentType.
+func serveBytes(t *testing.T, contentType string, body []byte) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		w.Write(body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestEmbedImages(t *testing.T) {
+	img := testPNG(t, 1, 1)
+	srv := serveBytes(t, "image/png", img)
+
+	md := "Text\n\n![a cat](" + srv.URL + "/cat.png)\n"
+	got := embedImages(md)
+	want := "Text\n\n![a cat](data:image/png;base64," + base64.StdEncoding.EncodeToString(img) + ")\n"
+	if got != want {
+		t.Errorf("embedImages() =\n%s\nwant\n%s", got, want)
+	}
+	if strings.Contains(got, srv.URL) {
+		t.Errorf("bundle still references the server:\n%s", got)
+	}
+}
+
+func TestDownloadAndReplaceImagesWikiLinks(t *testing.T) {
+	srv := serveBytes(t, "image/png", testPNG(t, 1, 1))
+	vault := t.TempDir()
+	imgDir := filepath.Join(vault, "attachments")
+
+	md := "![one](" + srv.URL + "/a.png)\n\n![two](" + srv.URL + "/b.png)\n"
+	got := downloadAndReplaceImages(md, imgDir, vault, imageLinksWiki, imageNamingIndex, 0, false)
+	if want := "![[attachments/img_1.png]]\n\n![[attachments/img_2.png]]\n"; got != want {
+		t.Errorf("wiki links =\n%s\nwant\n%s", got, want)
+	}
+	if _, err := os.Stat(filepath.Join(imgDir, "img_2.png")); err != nil {
+		t.Errorf("image not saved in the attachment folder: %v", err)
+	}
+
+	got = downloadAndReplaceImages("![one]("+srv.URL+"/a.png)\n", imgDir, vault, imageLinksMarkdown, imageNamingIndex, 0, true)
+	if want := "![one](attachments/img_1-2.png)\n"; got != want {
+		t.Errorf("markdown links = %q, want %q", got, want)
+	}
+}
+
+func TestInputFlag(t *testing.T) {
+	stdout, stderr, code := runX2MD(t, "-input", filepath.Join("testdata", "tweet.json"))
+	if code != 0 {
+		t.Fatalf("x2md -input exited %d: %s", code, stderr)
+	}
+	want, err := os.ReadFile(filepath.Join("testdata", "tweet.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stdout != string(want) {
+		t.Errorf("x2md -input printed\n%s\nwant\n%s", stdout, want)
+	}
+}
+
+func TestCombineSingleInput(t *testing.T) {
+	stdout, stderr, code := runX2MD(t, "-combine", "-input", filepath.Join("testdata", "tweet.json"))
+	if code != 0 {
+		t.Fatalf("x2md -combine exited %d: %s", code, stderr)
+	}
+	if !strings.HasPrefix(stdout, "## Alice\n\n- type: tweet\n") {
+		t.Errorf("x2md -combine with one input did not demote the frontmatter:\n%s", stdout)
+	}
+}
+
+func TestDownloadAndReplaceImagesMaxImages(t *testing.T) {
+	srv := serveBytes(t, "image/png", testPNG(t, 1, 1))
+	dir := t.TempDir()
+
+	var md strings.Builder
+	for i := 1; i <= 5; i++ {
+		fmt.Fprintf(&md, "![%d](%s/%d.png)\n\n", i, srv.URL, i)
+	}
+	got := downloadAndReplaceImages(md.String(), dir, dir, imageLinksMarkdown, imageNamingOriginal, 2, false)
+
+	want := fmt.Sprintf("![1](1.png)\n\n![2](2.png)\n\n![3](%[1]s/3.png)\n\n![4](%[1]s/4.png)\n\n![5](%[1]s/5.png)\n\n", srv.URL)
+	if got != want {
+		t.Errorf("downloadAndReplaceImages() =\n%s\nwant\n%s", got, want)
+	}
+	for _, name := range []string{"3.png", "4.png", "5.png"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			t.Errorf("%s downloaded past -max-images", name)
+		}
+	}
+}
+
+func TestWriteToFileNested(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a", "b", "c", "out.md")
+	err := writeToFile(path, func(w io.Writer) error {
+		_, err := io.WriteString(w, "hello\n")
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := os.ReadFile(path); err != nil || string(got) != "hello\n" {
+		t.Errorf("ReadFile() = %q, %v", got, err)
+	}
+
+	// A file where a parent directory should be is reported.
+	blocker := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(blocker, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	err = writeToFile(filepath.Join(blocker, "sub", "out.md"), func(io.Writer) error { return nil })
+	if err == nil || !strings.Contains(err.Error(), "creating directory") {
+		t.Errorf("writeToFile() under a file = %v, want a directory error", err)
+	}
+}
+
+// dirNames lists the names in dir.
+func dirNames(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return names
+}
+
+func TestAtomicWrite(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "out.md")
+	writeString := func(s string) func(io.Writer) error {
+		return func(w io.Writer) error {
+			_, err := io.WriteString(w, s)
+			return err
+		}
+	}
+
+	if err := atomicWrite(path, writeString("first\n")); err != nil {
+		t.Fatal(err)
+	}
+	if names := dirNames(t, dir); len(names) != 1 || names[0] != "out.md" {
+		t.Errorf("directory holds %v after a write, want only out.md", names)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0644 {
+		t.Errorf("new file mode = %v, %v; want 0644", info.Mode().Perm(), err)
+	}
+
+	// Replacing a file keeps its permissions.
+	if err := os.Chmod(path, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := atomicWrite(path, writeString("second\n")); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("replaced file mode = %v, %v; want 0600", info.Mode().Perm(), err)
+	}
+
+	// A failed write leaves the old file and no temp file.
+	err := atomicWrite(path, func(w io.Writer) error {
+		io.WriteString(w, "partial")
+		return errors.New("disk full")
+	})
+	if err == nil {
+		t.Fatal("atomicWrite() ignored the write error")
+	}
+	if got, _ := os.ReadFile(path); string(got) != "second\n" {
+		t.Errorf("failed write changed the file to %q", got)
+	}
+	if names := dirNames(t, dir); len(names) != 1 {
+		t.Errorf("directory holds %v after a failed write, want only out.md", names)
+	}
+}
+
+func TestAppendFlag(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "tweets.md")
+	for _, fixture := range []string{"tweet.json", "article.json"} {
+		if _, stderr, code := runX2MD(t, "-append", "-o", out, "-input", filepath.Join("testdata", fixture)); code != 0 {
+			t.Fatalf("x2md -append exited %d: %s", code, stderr)
+		}
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(data)
+	if !strings.HasPrefix(got, "---\ntype: tweet\n") {
+		t.Errorf("first tweet's frontmatter not kept:\n%s", got)
+	}
+	if strings.Count(got, "\n---\n") != 2 {
+		t.Errorf("want one frontmatter block and one rule:\n%s", got)
+	}
+	first, second, ok := strings.Cut(docBody(got), "\n---\n")
+	if !ok || !strings.Contains(first, "Shipping x2md today.") || !strings.Contains(second, "## Notes on Parsing\n\n- type: article\n") {
+		t.Errorf("appended file:\n%s", got)
+	}
+}
+
+// writeTweetFile saves tweet as an API response for -input.
+func writeTweetFile(t *testing.T, tweet *Tweet) string {
+	t.Helper()
+	data, err := json.Marshal(APIResponse{Code: 200, Message: "OK", Tweet: tweet})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "tweet.json")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestOutputDashIsStdout(t *testing.T) {
+	stdout, stderr, code := runX2MD(t, "-input", filepath.Join("testdata", "tweet.json"), "-o", "-")
+	if code != 0 {
+		t.Fatalf("x2md -o - exited %d: %s", code, stderr)
+	}
+	want, err := os.ReadFile(filepath.Join("testdata", "tweet.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stdout != string(want) {
+		t.Errorf("x2md -o - printed\n%s\nwant\n%s", stdout, want)
+	}
+	if strings.Contains(stderr, "已保存到") {
+		t.Errorf("stdout output reported as saved: %s", stderr)
+	}
+	if _, err := os.Stat("-"); !os.IsNotExist(err) {
+		os.Remove("-")
+		t.Errorf("-o - created a file named \"-\"")
+	}
+
+	// Images still go to the filesystem, with a warning saying so.
+	srv := serveBytes(t, "image/png", testPNG(t, 1, 1))
+	tweet := testThread("Look")[0]
+	tweet.Media = &Media{Photos: []Photo{{URL: srv.URL + "/look.png"}}}
+	imgDir := filepath.Join(t.TempDir(), "img")
+	stdout, stderr, code = runX2MD(t, "-input", writeTweetFile(t, tweet), "-o", "-", "-images", "-image-dir", imgDir)
+	if code != 0 {
+		t.Fatalf("x2md -o - -images exited %d: %s", code, stderr)
+	}
+	if !strings.Contains(stdout, "![image]("+filepath.Join(imgDir, "img_1.png")+")") {
+		t.Errorf("image not replaced in stdout output:\n%s", stdout)
+	}
+	if !strings.Contains(stderr, "Markdown 输出到 stdout，图片仍保存到 "+imgDir) {
+		t.Errorf("no warning that images are saved to disk: %s", stderr)
+	}
+}
+
+func TestImageRef(t *testing.T) {
+	tests := []struct {
+		localPath string
+		base      string
+		want      string
+	}{
+		{"static/images/img_1.jpg", "content/posts", "../../static/images/img_1.jpg"},
+		{"static/images/img_1.jpg", "", "static/images/img_1.jpg"},
+		{"post_images/img_1.jpg", ".", "post_images/img_1.jpg"},
+		{"content/posts/img/a.png", "content/posts", "img/a.png"},
+	}
+	for _, tt := range tests {
+		if got := imageRef(filepath.FromSlash(tt.localPath), filepath.FromSlash(tt.base)); got != tt.want {
+			t.Errorf("imageRef(%q, %q) = %q, want %q", tt.localPath, tt.base, got, tt.want)
+		}
+	}
+
+	// Absolute and relative forms mix.
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	abs := filepath.Join(wd, "static", "images", "img_1.jpg")
+	if got := imageRef(abs, filepath.Join("content", "posts")); got != "../../static/images/img_1.jpg" {
+		t.Errorf("imageRef(absolute path) = %q", got)
+	}
+}
+
+func TestImageBaseFlag(t *testing.T) {
+	srv := serveBytes(t, "image/png", testPNG(t, 1, 1))
+	tweet := testThread("Look")[0]
+	tweet.Media = &Media{Photos: []Photo{{URL: srv.URL + "/look.png"}}}
+	site := t.TempDir()
+	out := filepath.Join(site, "content", "posts", "look.md")
+	imgDir := filepath.Join(site, "static", "images")
+
+	_, stderr, code := runX2MD(t, "-input", writeTweetFile(t, tweet), "-o", out,
+		"-images", "-image-dir", imgDir, "-image-base", filepath.Dir(out))
+	if code != 0 {
+		t.Fatalf("x2md -image-base exited %d: %s", code, stderr)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "![image](../../static/images/img_1.png)") {
+		t.Errorf("image not referenced relative to the post:\n%s", data)
+	}
+}
+
+func TestEmbedImagesFigure(t *testing.T) {
+	img := testPNG(t, 1, 1)
+	srv := serveBytes(t, "image/png", img)
+
+	md := figureHTML(srv.URL+"/cat.png?a=1&b=2", "a cat", "a cat") + "\n"
+	got := embedImages(md)
+	want := figureHTML("data:image/png;base64,"+base64.StdEncoding.EncodeToString(img), "a cat", "a cat") + "\n"
+	if got != want {
+		t.Errorf("embedImages() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestDownloadFileRejects(t *testing.T) {
+	limit := maxFileSize
+	maxFileSize = 1024
+	t.Cleanup(func() { maxFileSize = limit })
+
+	big := bytes.Repeat([]byte{0}, 2048)
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		wantErr string
+	}{
+		{"oversized", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "image/png")
+			w.Write(big)
+		}, "over the 1024 byte limit"},
+		{"oversized without length", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "image/png")
+			w.Write(big[:512])
+			w.(http.Flusher).Flush() // forces a chunked response
+			w.Write(big[512:])
+		}, "over the 1024 byte limit"},
+		{"wrong content type", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte("<html>login</html>"))
+		}, `unexpected content type "text/html; charset=utf-8"`},
+		{"missing content type", func(w http.ResponseWriter, r *http.Request) {
+			w.Header()["Content-Type"] = nil
+			w.Write([]byte("data"))
+		}, `unexpected content type ""`},
+	}
+	for _, tt := range tests {
+		srv := httptest.NewServer(tt.handler)
+		dir := t.TempDir()
+		_, _, err := downloadFile(srv.URL+"/img.png", filepath.Join(dir, "img.png"))
+		srv.Close()
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: error = %v, want %q", tt.name, err, tt.wantErr)
+		}
+		if names := dirNames(t, dir); len(names) != 0 {
+			t.Errorf("%s: left files behind: %v", tt.name, names)
+		}
+	}
+
+	// A rejected image keeps its remote URL in the Markdown.
+	srv := serveBytes(t, "text/html", []byte("<html></html>"))
+	dir := t.TempDir()
+	md := "![page](" + srv.URL + "/page.png)\n"
+	if got := downloadAndReplaceImages(md, dir, "", imageLinksMarkdown, imageNamingIndex, 0, false); got != md {
+		t.Errorf("rejected image rewritten:\n%s", got)
+	}
+
+	// Within the limit the download succeeds.
+	img := testPNG(t, 1, 1)
+	srv = serveBytes(t, "image/png", img)
+	if size, _, err := downloadFile(srv.URL+"/ok.png", filepath.Join(t.TempDir(), "ok.png")); err != nil || size != int64(len(img)) {
+		t.Errorf("downloadFile() = %d, %v; want %d bytes", size, err, len(img))
+	}
+}
+
+func TestBodyOnlyUnavailableArticle(t *testing.T) {
+	tweet := testThread("")[0]
+	tweet.Article = &Article{ID: "99", Title: "Title"}
+	stdout, stderr, code := runX2MD(t, "-input", writeTweetFile(t, tweet), "-body-only")
+	if code != 0 {
+		t.Fatalf("x2md exited %d: %s", code, stderr)
+	}
+	if strings.TrimSpace(stdout) == "" || !strings.Contains(stdout, "[article content unavailable]") {
+		t.Errorf("stdout = %q, want a placeholder", stdout)
+	}
+	if !strings.Contains(stderr, "正文不可用") {
+		t.Errorf("no warning on stderr: %q", stderr)
+	}
+}
+
+func TestJSONErrorNotFound(t *testing.T) {
+	stubFxTwitter(t, map[string]*Tweet{})
+	t.Setenv(fxTwitterBaseEnv, fxTwitterBase)
+
+	stdout, stderr, code := runX2MD(t, "-json", "https://x.com/alice/status/404")
+	if code != 1 {
+		t.Errorf("exit code = %d, want 1", code)
+	}
+	if stderr != "" {
+		t.Errorf("stderr = %q, want nothing", stderr)
+	}
+	var got struct {
+		Error errorInfo `json:"error"`
+		Code  int       `json:"code"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &got); err != nil {
+		t.Fatalf("stdout is not JSON: %v\n%s", err, stdout)
+	}
+	if got.Code != 1 || got.Error.APICode != 404 || got.Error.URL != "https://x.com/alice/status/404" ||
+		!strings.Contains(got.Error.Message, "NOT_FOUND") {
+		t.Errorf("JSON error = %+v", got)
+	}
+
+	// Without -json the error is prose on stderr.
+	stdout, stderr, code = runX2MD(t, "https://x.com/alice/status/404")
+	if code != 1 || stdout != "" || !strings.Contains(stderr, "错误:") {
+		t.Errorf("exit %d, stdout %q, stderr %q", code, stdout, stderr)
+	}
+}
+
+func TestDownloadImagesContentDisposition(t *testing.T) {
+	img := testPNG(t, 1, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+r.URL.Query().Get("name")+`"`)
+		w.Write(img)
+	}))
+	t.Cleanup(srv.Close)
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "photo.png"), []byte("keep me"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var md strings.Builder
+	for _, name := range []string{"photo.png", "photo.png", "manifest.json", "_media.md", "../../notes.md", "chart"} {
+		fmt.Fprintf(&md, "![%s](%s/dl?name=%s)\n", name, srv.URL, name)
+	}
+	got := downloadAndReplaceImages(md.String(), dir, dir, imageLinksMarkdown, imageNamingOriginal, 0, false)
+
+	want := "![photo.png](photo-2.png)\n" +
+		"![photo.png](photo-3.png)\n" +
+		"![manifest.json](image.png)\n" +
+		"![_media.md](image-2.png)\n" +
+		"![../../notes.md](notes.md.png)\n" +
+		"![chart](chart.png)\n"
+	if got != want {
+		t.Errorf("downloadAndReplaceImages() =\n%s\nwant\n%s", got, want)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "photo.png")); string(data) != "keep me" {
+		t.Errorf("existing photo.png overwritten")
+	}
+	// The manifest, keyed by URL, and the index are still the tool's own files.
+	if len(readManifest(t, dir)) != 5 {
+		t.Errorf("manifest lost entries")
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, indexName)); err != nil || !strings.HasPrefix(string(data), "# 媒体索引") {
+		t.Errorf("%s clobbered: %v", indexName, err)
+	}
+	for _, name := range dirNames(t, dir) {
+		if name != manifestName && name != indexName && !isMediaExtension(filepath.Ext(name)) {
+			t.Errorf("saved %s without a media extension", name)
+		}
+	}
+
+	// Index naming rewrites its own files on a rerun, except when appending.
+	for _, keep := range []bool{false, false, true} {
+		got = downloadAndReplaceImages("![a]("+srv.URL+"/dl?name=x.png)\n", dir, dir, imageLinksMarkdown, imageNamingIndex, 0, keep)
+		want := "![a](img_1.png)\n"
+		if keep {
+			want = "![a](img_1-2.png)\n"
+		}
+		if got != want {
+			t.Errorf("index naming (keepExisting %v) = %q, want %q", keep, got, want)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	_ "image/gif" // register decoders for imageSize
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Files written into the image directory by -images: manifest.json for tools
+// and _media.md for people. The index is not named index.md, which static site
+// generators such as Hugo treat as a page bundle.
+const (
+	manifestName = "manifest.json"
+	indexName    = "_media.md"
+)
+
+// manifestEntry records a downloaded asset. Path is relative to the manifest.
+// Width and Height are zero when the format cannot be decoded.
+type manifestEntry struct {
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+	Size   int64  `json:"size"`
+	Width  int    `json:"width,omitempty"`
+	Height int    `json:"height,omitempty"`
+	Alt    string `json:"alt,omitempty"`
+}
+
+// updateManifest merges entries, keyed by original URL, into dir/manifest.json.
+// Entries from earlier runs are kept unless the same URL was downloaded again.
+// The merged manifest is returned.
+func updateManifest(dir string, entries map[string]manifestEntry) (map[string]manifestEntry, error) {
+	path := filepath.Join(dir, manifestName)
+	manifest := make(map[string]manifestEntry)
+	if data, err := os.ReadFile(path); err == nil {
+		// A corrupt manifest is replaced rather than blocking the download.
+		_ = json.Unmarshal(data, &manifest)
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	for url, e := range entries {
+		manifest[url] = e
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	err = atomicWrite(path, func(w io.Writer) error {
+		_, err := w.Write(append(data, '\n'))
+		return err
+	})
+	return manifest, err
+}
+
+// writeMediaIndex writes dir/_media.md, a table of every asset in manifest with
+// a link, its dimensions and alt text, ordered by file name.
+func writeMediaIndex(dir string, manifest map[string]manifestEntry) error {
+	entries := make([]manifestEntry, 0, len(manifest))
+	for _, e := range manifest {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return naturalLess(entries[i].Path, entries[j].Path) })
+
+	var sb strings.Builder
+	sb.WriteString("# 媒体索引\n\n")
+	sb.WriteString("| 文件 | 尺寸 | 替代文本 |\n")
+	sb.WriteString("| --- | --- | --- |\n")
+	for _, e := range entries {
+		size := "-"
+		if e.Width > 0 && e.Height > 0 {
+			size = fmt.Sprintf("%d×%d", e.Width, e.Height)
+		}
+		fmt.Fprintf(&sb, "| [%s](%s) | %s | %s |\n", e.Path, e.Path, size, tableCell(e.Alt))
+	}
+	return atomicWrite(filepath.Join(dir, indexName), func(w io.Writer) error {
+		_, err := io.WriteString(w, sb.String())
+		return err
+	})
+}
+
+// tableCell makes s safe inside a Markdown table cell.
+func tableCell(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// imageSize returns the pixel dimensions of the image at path, or zeros when
+// it cannot be read or its format is not one Go decodes (e.g. WebP).
+func imageSize(path string) (width, height int) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0
+	}
+	defer f.Close()
+	cfg, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return 0, 0
+	}
+	return cfg.Width, cfg.Height
+}
+
+// naturalLess orders names with embedded numbers numerically, so img_2.jpg
+// sorts before img_10.jpg.
+func naturalLess(a, b string) bool {
+	for a != "" && b != "" {
+		na, ra := leadingDigits(a)
+		nb, rb := leadingDigits(b)
+		switch {
+		case na != "" && nb != "":
+			if x, y := strings.TrimLeft(na, "0"), strings.TrimLeft(nb, "0"); x != y {
+				if len(x) != len(y) {
+					return len(x) < len(y)
+				}
+				return x < y
+			}
+			a, b = ra, rb
+		case a[0] != b[0]:
+			return a[0] < b[0]
+		default:
+			a, b = a[1:], b[1:]
+		}
+	}
+	return len(a) < len(b)
+}
+
+func leadingDigits(s string) (digits, rest string) {
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	return s[:i], s[i:]
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// servePNGs starts a server answering each path in images with its PNG.
+func servePNGs(t *testing.T, images map[string][]byte) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := images[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// readManifest decodes dir/manifest.json.
+func readManifest(t *testing.T, dir string) map[string]manifestEntry {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, manifestName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var manifest map[string]manifestEntry
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatalf("invalid manifest: %v\n%s", err, data)
+	}
+	return manifest
+}
+
+func TestDownloadManifest(t *testing.T) {
+	images := map[string][]byte{
+		"/wide.png": testPNG(t, 4, 2),
+		"/tall.png": testPNG(t, 1, 3),
+	}
+	srv := servePNGs(t, images)
+	dir := t.TempDir()
+
+	md := "![A wide one](" + srv.URL + "/wide.png)\n\n![](" + srv.URL + "/tall.png)\n"
+	downloadAndReplaceImages(md, dir, "", imageLinksMarkdown, imageNamingIndex, 0, false)
+
+	sum := func(b []byte) string {
+		h := sha256.Sum256(b)
+		return hex.EncodeToString(h[:])
+	}
+	want := map[string]manifestEntry{
+		srv.URL + "/wide.png": {
+			Path: "img_1.png", SHA256: sum(images["/wide.png"]), Size: int64(len(images["/wide.png"])),
+			Width: 4, Height: 2, Alt: "A wide one",
+		},
+		srv.URL + "/tall.png": {
+			Path: "img_2.png", SHA256: sum(images["/tall.png"]), Size: int64(len(images["/tall.png"])),
+			Width: 1, Height: 3,
+		},
+	}
+	got := readManifest(t, dir)
+	if len(got) != len(want) {
+		t.Fatalf("manifest has %d entries, want %d: %v", len(got), len(want), got)
+	}
+	for url, w := range want {
+		if got[url] != w {
+			t.Errorf("manifest[%s] = %+v, want %+v", url, got[url], w)
+		}
+	}
+
+	// A later run merges its downloads into the existing manifest.
+	downloadAndReplaceImages("![again]("+srv.URL+"/tall.png)\n", dir, "", imageLinksMarkdown, imageNamingIndex, 0, true)
+	got = readManifest(t, dir)
+	if len(got) != 2 || got[srv.URL+"/wide.png"] != want[srv.URL+"/wide.png"] {
+		t.Errorf("earlier entry lost: %v", got)
+	}
+	if e := got[srv.URL+"/tall.png"]; e.Path != "img_1-2.png" || e.Alt != "again" {
+		t.Errorf("re-downloaded entry = %+v, want img_1-2.png with the new alt", e)
+	}
+}
+
+func TestMediaIndex(t *testing.T) {
+	srv := servePNGs(t, map[string][]byte{
+		"/wide.png": testPNG(t, 4, 2),
+		"/tall.png": testPNG(t, 1, 3),
+	})
+	dir := t.TempDir()
+
+	md := "![A wide | one](" + srv.URL + "/wide.png)\n\n![](" + srv.URL + "/tall.png)\n"
+	downloadAndReplaceImages(md, dir, "", imageLinksMarkdown, imageNamingIndex, 0, false)
+
+	data, err := os.ReadFile(filepath.Join(dir, "_media.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "# 媒体索引\n\n" +
+		"| 文件 | 尺寸 | 替代文本 |\n" +
+		"| --- | --- | --- |\n" +
+		"| [img_1.png](img_1.png) | 4×2 | A wide \\| one |\n" +
+		"| [img_2.png](img_2.png) | 1×3 |  |\n"
+	if string(data) != want {
+		t.Errorf("_media.md =\n%s\nwant\n%s", data, want)
+	}
+	// index.md would turn the image directory into a Hugo page bundle.
+	if _, err := os.Stat(filepath.Join(dir, "index.md")); !os.IsNotExist(err) {
+		t.Errorf("index.md written: %v", err)
+	}
+}
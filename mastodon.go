@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// mastodonFetcher fetches statuses from a Mastodon instance's public API,
+// mapping them onto the Tweet model. Status IDs are local to the instance,
+// so the screen name passed to FetchTweet is not needed.
+type mastodonFetcher struct {
+	instance string // host, e.g. "mastodon.social"
+}
+
+// FetchTweet fetches a public status by its instance-local ID.
+func (f mastodonFetcher) FetchTweet(ctx context.Context, screenName, id string) (*Tweet, error) {
+	url := fmt.Sprintf("https://%s/api/v1/statuses/%s", f.instance, id)
+	body, status, err := apiGet(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(body, &apiErr) == nil && apiErr.Error != "" {
+			return nil, &APIError{Code: status, msg: fmt.Sprintf("Mastodon API error (status %d): %s", status, apiErr.Error)}
+		}
+		return nil, statusError("Mastodon API", status, body)
+	}
+	return parseMastodonStatus(body, f.instance)
+}
+
+// mastoStatus is a Mastodon Status entity.
+type mastoStatus struct {
+	ID                 string            `json:"id"`
+	URL                string            `json:"url"`
+	URI                string            `json:"uri"`
+	CreatedAt          string            `json:"created_at"`
+	Content            string            `json:"content"`
+	SpoilerText        string            `json:"spoiler_text"`
+	Sensitive          bool              `json:"sensitive"`
+	Language           string            `json:"language"`
+	InReplyToID        string            `json:"in_reply_to_id"`
+	InReplyToAccountID string            `json:"in_reply_to_account_id"`
+	RepliesCount       int               `json:"replies_count"`
+	ReblogsCount       int               `json:"reblogs_count"`
+	FavouritesCount    int               `json:"favourites_count"`
+	Account            mastoAccount      `json:"account"`
+	MediaAttachments   []mastoAttachment `json:"media_attachments"`
+	Mentions           []mastoMention    `json:"mentions"`
+	Poll               *mastoPoll        `json:"poll"`
+	Reblog             *mastoStatus      `json:"reblog"`
+	// Quote is set on servers that support quote posts (Mastodon 4.4+).
+	Quote *struct {
+		QuotedStatus *mastoStatus `json:"quoted_status"`
+	} `json:"quote"`
+}
+
+type mastoAccount struct {
+	ID             string `json:"id"`
+	Acct           string `json:"acct"`
+	DisplayName    string `json:"display_name"`
+	Avatar         string `json:"avatar"`
+	FollowersCount int    `json:"followers_count"`
+	FollowingCount int    `json:"following_count"`
+}
+
+type mastoMention struct {
+	ID   string `json:"id"`
+	Acct string `json:"acct"`
+}
+
+type mastoAttachment struct {
+	Type        string `json:"type"` // image, gifv, video or audio
+	URL         string `json:"url"`
+	PreviewURL  string `json:"preview_url"`
+	Description string `json:"description"`
+	Meta        struct {
+		Original struct {
+			Width    int     `json:"width"`
+			Height   int     `json:"height"`
+			Duration float64 `json:"duration"`
+		} `json:"original"`
+	} `json:"meta"`
+}
+
+type mastoPoll struct {
+	ExpiresAt  string `json:"expires_at"`
+	Expired    bool   `json:"expired"`
+	VotesCount int    `json:"votes_count"`
+	Options    []struct {
+		Title      string `json:"title"`
+		VotesCount int    `json:"votes_count"`
+	} `json:"options"`
+}
+
+// parseMastodonStatus decodes a status served by instance into a tweet.
+func parseMastodonStatus(body []byte, instance string) (*Tweet, error) {
+	var status mastoStatus
+	if err := json.Unmarshal(body, &status); err != nil {
+		return nil, fmt.Errorf("parsing JSON response: %w", err)
+	}
+	if status.ID == "" {
+		return nil, fmt.Errorf("no status data in response")
+	}
+	tweet := status.toTweet(instance)
+	tweet.Raw = body
+	return tweet, nil
+}
+
+// toTweet maps a status onto the Tweet model. Accounts are named by their
+// full user@domain handle, qualified with instance for local accounts.
+func (s *mastoStatus) toTweet(instance string) *Tweet {
+	text := mastodonText(s.Content)
+	if s.SpoilerText != "" {
+		// The content warning precedes the text it hides.
+		text = strings.TrimSpace("**" + s.SpoilerText + "**\n\n" + text)
+	}
+	url := s.URL
+	if url == "" {
+		url = s.URI
+	}
+
+	tweet := &Tweet{
+		ID:                s.ID,
+		URL:               url,
+		Origin:            SourceMastodon,
+		Text:              text,
+		CreatedAt:         s.CreatedAt,
+		Likes:             Count(s.FavouritesCount),
+		Retweets:          Count(s.ReblogsCount),
+		Replies:           Count(s.RepliesCount),
+		Lang:              s.Language,
+		PossiblySensitive: s.Sensitive,
+		ReplyingToStatus:  s.InReplyToID,
+		Author: &Author{
+			ID:         s.Account.ID,
+			Name:       s.Account.DisplayName,
+			ScreenName: mastodonAcct(s.Account.Acct, instance),
+			AvatarURL:  s.Account.Avatar,
+			Followers:  s.Account.FollowersCount,
+			Following:  s.Account.FollowingCount,
+		},
+	}
+
+	if s.InReplyToAccountID != "" {
+		if s.InReplyToAccountID == s.Account.ID {
+			tweet.ReplyingTo = tweet.Author.ScreenName
+		}
+		for _, m := range s.Mentions {
+			if m.ID == s.InReplyToAccountID {
+				tweet.ReplyingTo = mastodonAcct(m.Acct, instance)
+			}
+		}
+	}
+
+	for _, a := range s.MediaAttachments {
+		if tweet.Media == nil {
+			tweet.Media = &Media{}
+		}
+		orig := a.Meta.Original
+		if a.Type == "image" {
+			photo := Photo{URL: a.URL, Width: orig.Width, Height: orig.Height, AltText: a.Description}
+			tweet.Media.Photos = append(tweet.Media.Photos, photo)
+			tweet.Media.All = append(tweet.Media.All, MediaItem{
+				Type: "photo", URL: a.URL, Width: orig.Width, Height: orig.Height, AltText: a.Description,
+			})
+			continue
+		}
+		video := Video{URL: a.URL, ThumbnailURL: a.PreviewURL, Width: orig.Width, Height: orig.Height, Duration: orig.Duration}
+		tweet.Media.Videos = append(tweet.Media.Videos, video)
+		tweet.Media.All = append(tweet.Media.All, MediaItem{
+			Type: "video", URL: a.URL, Width: orig.Width, Height: orig.Height, ThumbnailURL: a.PreviewURL, AltText: a.Description,
+		})
+	}
+
+	if p := s.Poll; p != nil {
+		poll := &Poll{TotalVotes: p.VotesCount, EndsAt: p.ExpiresAt, Ended: p.Expired}
+		for _, o := range p.Options {
+			choice := PollChoice{Label: o.Title, Count: o.VotesCount}
+			if p.VotesCount > 0 {
+				choice.Percentage = math.Round(float64(o.VotesCount)*1000/float64(p.VotesCount)) / 10
+			}
+			poll.Choices = append(poll.Choices, choice)
+		}
+		tweet.Poll = poll
+	}
+
+	if s.Reblog != nil {
+		tweet.RetweetedStatus = s.Reblog.toTweet(instance)
+	}
+	if s.Quote != nil && s.Quote.QuotedStatus != nil {
+		tweet.Quote = s.Quote.QuotedStatus.toTweet(instance)
+	}
+	return tweet
+}
+
+// mastodonAcct qualifies a local account's acct ("alice") with the instance
+// domain; remote accounts already read "alice@example.org".
+func mastodonAcct(acct, instance string) string {
+	if acct == "" || strings.Contains(acct, "@") {
+		return acct
+	}
+	return acct + "@" + instance
+}
+
+// selfLinkRe matches a Markdown link whose text is a URL.
+var selfLinkRe = regexp.MustCompile(`\[(https?://[^\]\s]+)\]\((https?://[^)\s]+)\)`)
+
+// mastodonText converts a status's HTML content to Markdown. Links Mastodon
+// displays as their own URL become bare URLs, as in tweet text.
+func mastodonText(content string) string {
+	md := HTMLToMarkdown(content)
+	return selfLinkRe.ReplaceAllStringFunc(md, func(m string) string {
+		sub := selfLinkRe.FindStringSubmatch(m)
+		if sub[1] != sub[2] {
+			return m
+		}
+		return sub[2]
+	})
+}
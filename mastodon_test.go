@@ -0,0 +1,100 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestParseMastodonURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		want URLInfo
+	}{
+		{
+			"https://mastodon.social/@alice/112233445566778899",
+			URLInfo{
+				Type: URLTypeTweet, Source: SourceMastodon, Host: "mastodon.social",
+				ScreenName: "alice", ID: "112233445566778899",
+				OriginalURL: "https://mastodon.social/@alice/112233445566778899",
+			},
+		},
+		{
+			"hachyderm.io/@bob@example.org/123?utm_source=share",
+			URLInfo{
+				Type: URLTypeTweet, Source: SourceMastodon, Host: "hachyderm.io",
+				ScreenName: "bob@example.org", ID: "123",
+				OriginalURL: "https://hachyderm.io/@bob@example.org/123",
+			},
+		},
+	}
+	for _, tt := range tests {
+		info, err := ParseURL(tt.url)
+		if err != nil {
+			t.Errorf("ParseURL(%q): %v", tt.url, err)
+			continue
+		}
+		if info != tt.want {
+			t.Errorf("ParseURL(%q) = %+v, want %+v", tt.url, info, tt.want)
+		}
+	}
+}
+
+func TestParseMastodonStatus(t *testing.T) {
+	body, err := os.ReadFile("testdata/mastodon_status.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tweet, err := parseMastodonStatus(body, "mastodon.social")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tweet.ID != "112233445566778899" || tweet.URL != "https://mastodon.social/@alice/112233445566778899" || tweet.Origin != SourceMastodon {
+		t.Errorf("ID, URL or origin wrong: %q %q %q", tweet.ID, tweet.URL, tweet.Origin)
+	}
+	if tweet.Likes != 12 || tweet.Retweets != 5 || tweet.Replies != 3 || tweet.Lang != "en" {
+		t.Errorf("counts or lang wrong: %+v", tweet)
+	}
+	if a := tweet.Author; a == nil || a.ScreenName != "alice@mastodon.social" || a.Name != "Alice" || a.Followers != 100 {
+		t.Errorf("Author = %+v", a)
+	}
+	// A reply to the same account continues a self-thread.
+	if tweet.ReplyingToStatus != "112233445566778800" || !repliesToSelf(tweet) {
+		t.Errorf("reply = %q to %q, want a self-reply", tweet.ReplyingToStatus, tweet.ReplyingTo)
+	}
+	if want := "Part two of my notes on https://go.dev/doc/\n\nSee the [spec](https://example.com/spec) & more."; tweet.Text != want {
+		t.Errorf("Text = %q, want %q", tweet.Text, want)
+	}
+
+	m := tweet.Media
+	if m == nil || len(m.Photos) != 1 || len(m.Videos) != 1 || len(m.All) != 2 {
+		t.Fatalf("Media = %+v", m)
+	}
+	if p := m.Photos[0]; p.URL != "https://files.mastodon.social/media/photo.png" || p.Width != 800 || p.AltText != "A diagram" {
+		t.Errorf("photo = %+v", p)
+	}
+	if v := m.Videos[0]; v.ThumbnailURL != "https://files.mastodon.social/media/clip.png" || v.Duration != 4.5 {
+		t.Errorf("video = %+v", v)
+	}
+	if p := tweet.Poll; p == nil || p.TotalVotes != 3 || len(p.Choices) != 2 || p.Choices[0].Percentage != 66.7 {
+		t.Errorf("Poll = %+v", p)
+	}
+
+	// The mapped status renders like a tweet.
+	got := RenderTweet(tweet, RenderOptions{})
+	for _, want := range []string{
+		"author: \"@alice@mastodon.social\"\n",
+		"date: \"2024-01-15T12:30:00Z\"\n",
+		"See the [spec](https://example.com/spec) & more.",
+		"![A diagram](https://files.mastodon.social/media/photo.png)",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("rendered status missing %q:\n%s", want, got)
+		}
+	}
+
+	if _, err := parseMastodonStatus([]byte(`{"error":"Record not found"}`), "mastodon.social"); err == nil {
+		t.Error("parsed a response without a status")
+	}
+}
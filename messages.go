@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Color modes accepted by -color.
+const (
+	colorAuto   = "auto"
+	colorAlways = "always"
+	colorNever  = "never"
+)
+
+// ANSI colors for message labels.
+const (
+	ansiRed    = "\033[31m"
+	ansiYellow = "\033[33m"
+	ansiReset  = "\033[0m"
+)
+
+// stderrColor reports whether warnf and fatalf color their labels; set from
+// -color.
+var stderrColor bool
+
+// useColor reports whether messages written to w should be colored under
+// mode. With auto, color is used only on a terminal and only when the
+// NO_COLOR environment variable is unset or empty.
+func useColor(mode string, w io.Writer) bool {
+	switch mode {
+	case colorAlways:
+		return true
+	case colorNever:
+		return false
+	}
+	return os.Getenv("NO_COLOR") == "" && isTerminal(w)
+}
+
+// warnf reports a recoverable problem on stderr.
+func warnf(format string, args ...any) {
+	fetchProgress.Clear()
+	writeMessage(os.Stderr, stderrColor, ansiYellow, "警告", fmt.Sprintf(format, args...))
+}
+
+// fatalf reports a fatal problem and exits with code.
+func fatalf(code int, format string, args ...any) {
+	exitWithError(code, errorInfo{Message: fmt.Sprintf(format, args...)})
+}
+
+// jsonErrors makes fatal errors print a JSON object on stdout instead of a
+// message on stderr; set by -json.
+var jsonErrors bool
+
+// errorInfo is the "error" object of -json error output.
+type errorInfo struct {
+	Message string `json:"message"`
+	URL     string `json:"url,omitempty"`
+	// APICode is the code the API reported, e.g. 404 for a missing tweet.
+	APICode int `json:"api_code,omitempty"`
+}
+
+// exitWithError reports info, as {"error": info, "code": code} on stdout
+// with -json and on stderr otherwise, and exits with code.
+func exitWithError(code int, info errorInfo) {
+	fetchProgress.Clear()
+	if jsonErrors {
+		data, _ := json.Marshal(struct {
+			Error errorInfo `json:"error"`
+			Code  int       `json:"code"`
+		}{info, code})
+		os.Stdout.Write(append(data, '\n'))
+	} else {
+		writeMessage(os.Stderr, stderrColor, ansiRed, "错误", info.Message)
+	}
+	os.Exit(code)
+}
+
+// writeMessage writes "label: msg" on its own line, coloring the label when
+// colored is set.
+func writeMessage(w io.Writer, colored bool, color, label, msg string) {
+	if colored {
+		label = color + label + ":" + ansiReset
+	} else {
+		label += ":"
+	}
+	fmt.Fprintln(w, label, msg)
+}
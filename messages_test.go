@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUseColor(t *testing.T) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "stderr.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	t.Setenv("NO_COLOR", "")
+	for _, w := range []io.Writer{&bytes.Buffer{}, f} {
+		if useColor(colorAuto, w) {
+			t.Errorf("auto colors non-terminal %T", w)
+		}
+		if useColor(colorNever, w) {
+			t.Errorf("never colors %T", w)
+		}
+		if !useColor(colorAlways, w) {
+			t.Errorf("always does not color %T", w)
+		}
+	}
+
+	var buf bytes.Buffer
+	writeMessage(&buf, false, ansiYellow, "警告", "plain")
+	if got := buf.String(); got != "警告: plain\n" {
+		t.Errorf("uncolored message = %q", got)
+	}
+	buf.Reset()
+	writeMessage(&buf, true, ansiRed, "错误", "colored")
+	if got := buf.String(); got != ansiRed+"错误:"+ansiReset+" colored\n" {
+		t.Errorf("colored message = %q", got)
+	}
+}
+
+func TestColorFlag(t *testing.T) {
+	// Test stderr is a pipe, so auto behaves like never.
+	for _, mode := range []string{colorNever, colorAuto} {
+		_, stderr, code := runX2MD(t, "-color", mode, "-max-depth", "0", "https://x.com/alice/status/1")
+		if code == 0 || !strings.Contains(stderr, "错误:") {
+			t.Fatalf("-color %s: exit %d, stderr %q", mode, code, stderr)
+		}
+		if strings.Contains(stderr, "\033[") {
+			t.Errorf("-color %s wrote escape codes: %q", mode, stderr)
+		}
+	}
+
+	_, stderr, _ := runX2MD(t, "-color", colorAlways, "-max-depth", "0", "https://x.com/alice/status/1")
+	if !strings.Contains(stderr, ansiRed+"错误:"+ansiReset) {
+		t.Errorf("-color always stderr = %q, want a red label", stderr)
+	}
+
+	if _, stderr, code := runX2MD(t, "-color", "sometimes", "https://x.com/alice/status/1"); code == 0 || !strings.Contains(stderr, "不支持的 -color") {
+		t.Errorf("invalid -color: exit %d, stderr %q", code, stderr)
+	}
+}
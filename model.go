@@ -3,7 +3,9 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"math"
 	"strconv"
+	"strings"
 )
 
 // APIResponse is the top-level response from FxTwitter API.
@@ -20,11 +22,11 @@ type Tweet struct {
 	Text             string   `json:"text"`
 	CreatedAt        string   `json:"created_at"`
 	CreatedTimestamp  int64    `json:"created_timestamp"`
-	Likes            int      `json:"likes"`
-	Retweets         int      `json:"retweets"`
-	Replies          int      `json:"replies"`
-	Views            int      `json:"views"`
-	Bookmarks        int      `json:"bookmarks"`
+	Likes            Count    `json:"likes"`
+	Retweets         Count    `json:"retweets"`
+	Replies          Count    `json:"replies"`
+	Views            Count    `json:"views"`
+	Bookmarks        Count    `json:"bookmarks"`
 	Lang             string   `json:"lang"`
 	Source           string   `json:"source"`
 	Author           *Author  `json:"author"`
@@ -35,6 +37,54 @@ type Tweet struct {
 	ReplyingToStatus string   `json:"replying_to_status"`
 	Article          *Article `json:"article"`
 	ConversationID   string   `json:"conversation_id"`
+	// RetweetedStatus is the original tweet when this tweet is a plain retweet.
+	RetweetedStatus *Tweet `json:"retweeted_status"`
+	// PossiblySensitive is set when X marks the tweet's media as sensitive.
+	PossiblySensitive bool `json:"possibly_sensitive"`
+	// Edit is present when the tweet has been edited after posting.
+	Edit *EditInfo `json:"edit"`
+	// Place is the location the author tagged the tweet with, if any.
+	Place *Place `json:"place"`
+	// Translation is only present when the tweet was fetched with a target language.
+	Translation *Translation `json:"translation"`
+	// CommunityNote is the Community Notes (Birdwatch) context shown on the tweet.
+	CommunityNote *CommunityNote `json:"community_note"`
+
+	// Origin is the service a post not from X was fetched from
+	// (SourceBluesky, SourceMastodon); its URL is then the permalink.
+	Origin string `json:"-"`
+	// Raw is the API response the tweet was decoded from.
+	Raw json.RawMessage `json:"-"`
+	// Parent is the tweet this one replies to, when -include-parent is set.
+	Parent *Tweet `json:"-"`
+	// QuoteThread holds the tweets of Quote's thread leading up to Quote
+	// when -expand-quotes is set.
+	QuoteThread []*Tweet `json:"-"`
+}
+
+// EditInfo describes the edit history of an edited tweet.
+type EditInfo struct {
+	Count    int    `json:"count"`
+	EditedAt string `json:"edited_at"`
+}
+
+// CommunityNote is reader-added context attached to a tweet.
+type CommunityNote struct {
+	Text string `json:"text"`
+}
+
+// Place is a named location attached to a tweet.
+type Place struct {
+	Name     string `json:"name"`
+	FullName string `json:"full_name"`
+	Country  string `json:"country"`
+}
+
+// Translation is FxTwitter's machine translation of a tweet's text.
+type Translation struct {
+	Text       string `json:"text"`
+	SourceLang string `json:"source_lang"`
+	TargetLang string `json:"target_lang"`
 }
 
 // Author holds the tweet author's information.
@@ -61,6 +111,7 @@ type MediaItem struct {
 	Width        int    `json:"width"`
 	Height       int    `json:"height"`
 	ThumbnailURL string `json:"thumbnail_url"`
+	AltText      string `json:"altText"`
 }
 
 // Photo represents an image attached to a tweet.
@@ -119,6 +170,7 @@ type Block struct {
 	Key               string             `json:"key"`
 	Text              string             `json:"text"`
 	Type              string             `json:"type"`
+	Depth             int                `json:"depth"`
 	InlineStyleRanges []InlineStyleRange `json:"inlineStyleRanges"`
 	EntityRanges      []EntityRange      `json:"entityRanges"`
 }
@@ -159,6 +211,7 @@ type EntityData struct {
 	EntityKey  string           `json:"entityKey"`
 	MediaItems []EntityMediaRef `json:"mediaItems"`
 	URL        string           `json:"url"`
+	Caption    string           `json:"caption"`
 }
 
 // EntityMediaRef references a media item by mediaId.
@@ -181,6 +234,7 @@ type MediaInfo struct {
 	OriginalImgURL   string `json:"original_img_url"`
 	OriginalImgWidth int    `json:"original_img_width"`
 	OriginalImgHeight int   `json:"original_img_height"`
+	AltText          string `json:"alt_text"`
 }
 
 // UnmarshalJSON handles both string ("0") and number (0) JSON values.
@@ -202,6 +256,65 @@ func (f *FlexInt) UnmarshalJSON(data []byte) error {
 	return fmt.Errorf("FlexInt: cannot unmarshal %s", string(data))
 }
 
+// Count is an engagement count. FxTwitter usually sends a number but
+// sometimes a formatted string such as "1,234", "1.2M" or, when localized,
+// "1.2万".
+type Count int
+
+// countSuffixes are the abbreviations accepted by parseCount, matched
+// case-insensitively.
+var countSuffixes = []struct {
+	suffix string
+	mult   float64
+}{
+	{"K", 1e3},
+	{"M", 1e6},
+	{"B", 1e9},
+	{"万", 1e4},
+	{"亿", 1e8},
+}
+
+// UnmarshalJSON accepts numbers and formatted count strings.
+func (c *Count) UnmarshalJSON(data []byte) error {
+	var num float64
+	if err := json.Unmarshal(data, &num); err == nil {
+		*c = Count(math.Round(num))
+		return nil
+	}
+	var strVal string
+	if err := json.Unmarshal(data, &strVal); err == nil {
+		n, err := parseCount(strVal)
+		if err != nil {
+			return err
+		}
+		*c = Count(n)
+		return nil
+	}
+	return fmt.Errorf("Count: cannot unmarshal %s", string(data))
+}
+
+// parseCount parses "1234", "1,234", "1.2K", "3M", "1.5万" and similar strings.
+// An empty string is zero.
+func parseCount(s string) (int, error) {
+	num := strings.TrimSpace(strings.ReplaceAll(s, ",", ""))
+	if num == "" {
+		return 0, nil
+	}
+	mult := 1.0
+	for _, cs := range countSuffixes {
+		if strings.HasSuffix(strings.ToUpper(num), cs.suffix) {
+			num = strings.TrimSpace(num[:len(num)-len(cs.suffix)])
+			mult = cs.mult
+			break
+		}
+	}
+	f, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Count: cannot parse %q", s)
+	}
+	return int(math.Round(f * mult)), nil
+}
+
 // URLType indicates whether a URL points to a tweet or an article.
 type URLType int
 
@@ -212,7 +325,12 @@ const (
 
 // URLInfo holds parsed URL information.
 type URLInfo struct {
-	Type       URLType
+	Type URLType
+	// Source is the service the URL belongs to: SourceFxTwitter,
+	// SourceBluesky or SourceMastodon.
+	Source string
+	// Host is the Mastodon instance serving the status; empty otherwise.
+	Host       string
 	ScreenName string
 	ID         string
 	OriginalURL string
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestCountViews(t *testing.T) {
+	tests := []struct {
+		views string
+		want  Count
+	}{
+		{`1234`, 1234},
+		{`"1,234"`, 1234},
+		{`"1.2M"`, 1200000},
+	}
+	for _, tt := range tests {
+		var tweet Tweet
+		if err := json.Unmarshal([]byte(`{"id": "1", "views": `+tt.views+`}`), &tweet); err != nil {
+			t.Errorf("views %s: %v", tt.views, err)
+			continue
+		}
+		if tweet.Views != tt.want {
+			t.Errorf("views %s = %d, want %d", tt.views, tweet.Views, tt.want)
+		}
+	}
+
+}
+
+func TestFlexInt(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    FlexInt
+		wantErr bool
+	}{
+		{`3`, 3, false},
+		{`"3"`, 3, false},
+		{`"1,234"`, 0, true},
+		{`"1.2K"`, 0, true},
+		{`1.5`, 0, true},
+	}
+	for _, tt := range tests {
+		var got FlexInt
+		err := json.Unmarshal([]byte(tt.in), &got)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("unmarshal %s: err = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("unmarshal %s = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+
+	// Entity keys decode from plain numeric strings only.
+	var item EntityMapItem
+	if err := json.Unmarshal([]byte(`{"key": "3"}`), &item); err != nil || item.Key != 3 {
+		t.Errorf("entity key = %d, %v; want 3", item.Key, err)
+	}
+	if err := json.Unmarshal([]byte(`{"key": "1.2K"}`), &item); err == nil {
+		t.Errorf("entity key \"1.2K\" decoded as %d, want an error", item.Key)
+	}
+}
+
+func TestCounts(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Count
+		wantErr bool
+	}{
+		{`0`, 0, false},
+		{`42`, 42, false},
+		{`1.0`, 1, false},
+		{`"42"`, 42, false},
+		{`""`, 0, false},
+		{`"12,345,678"`, 12345678, false},
+		{`"1.2K"`, 1200, false},
+		{`"3k"`, 3000, false},
+		{`"2.5M"`, 2500000, false},
+		{`"1B"`, 1000000000, false},
+		{`" 1.5 K "`, 1500, false},
+		{`"1.5万"`, 15000, false},
+		{`"12万"`, 120000, false},
+		{`"2亿"`, 200000000, false},
+		{`"lots"`, 0, true},
+		{`"1.2X"`, 0, true},
+		{`true`, 0, true},
+	}
+	for _, tt := range tests {
+		var got Count
+		err := json.Unmarshal([]byte(tt.in), &got)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("unmarshal %s: err = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("unmarshal %s = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestCountFrontmatter(t *testing.T) {
+	var tweet Tweet
+	data := `{"id": "1", "text": "Hi", "likes": "1.5万", "retweets": "1,024", "replies": 7, "views": "2.1M", "bookmarks": "3K"}`
+	if err := json.Unmarshal([]byte(data), &tweet); err != nil {
+		t.Fatal(err)
+	}
+	got := RenderTweet(&tweet, RenderOptions{})
+	for _, want := range []string{"likes: 15000\n", "retweets: 1024\n", "replies: 7\n", "views: 2100000\n", "bookmarks: 3000\n"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("frontmatter missing %q:\n%s", want, got)
+		}
+	}
+}
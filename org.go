@@ -0,0 +1,300 @@
+package main
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// orgHeadingRe matches an ATX heading, capturing its level and text.
+	orgHeadingRe = regexp.MustCompile(`^(#{1,6}) (.*)$`)
+	// orgRuleRe matches a thematic break.
+	orgRuleRe = regexp.MustCompile(`^(?:-{3,}|\*{3,}|_{3,})$`)
+	// orgTableRuleRe matches a table's header separator row.
+	orgTableRuleRe = regexp.MustCompile(`^\|(?:\s*:?-+:?\s*\|)+$`)
+	// orgFootnoteDefRe matches a footnote definition's "[^1]: " prefix.
+	orgFootnoteDefRe = regexp.MustCompile(`^\[\^([^\]]+)\]: `)
+	// orgCalloutRe matches a callout's "[!NOTE]" marker line.
+	orgCalloutRe = regexp.MustCompile(`^\[!(\w+)\]$`)
+	// orgSummaryRe matches a <details> block's <summary> line.
+	orgSummaryRe = regexp.MustCompile(`^<summary>(.*)</summary>$`)
+	// orgImgRe matches an HTML <img> line.
+	orgImgRe = regexp.MustCompile(`^<img src="([^"]*)" alt="[^"]*">$`)
+	// orgCaptionRe matches a <figcaption> line.
+	orgCaptionRe = regexp.MustCompile(`^<figcaption>(.*)</figcaption>$`)
+	// orgCodeEscapeRe matches code lines Org would read as headings or
+	// keywords even inside a source block, including ones already escaped.
+	orgCodeEscapeRe = regexp.MustCompile(`^(\s*)(,*(?:\*|#\+))`)
+
+	orgCodeSpanRe    = regexp.MustCompile("``.+?``|`[^`]+`")
+	orgEscapeRe      = regexp.MustCompile(`\\([\\` + "`" + `*_{}\[\]()#+\-.!~|>])`)
+	orgFootnoteRefRe = regexp.MustCompile(`\[\^([^\]]+)\]`)
+	orgLinkedImageRe = regexp.MustCompile(`\[!\[[^\]]*\]\(([^)\s]+)\)\]\(([^)\s]+)\)`)
+	orgImageRe       = regexp.MustCompile(`!\[[^\]]*\]\(([^)\s]+)\)`)
+	orgLinkRe        = regexp.MustCompile(`\[([^\]]*)\]\(([^)\s]+)\)`)
+	orgAutolinkRe    = regexp.MustCompile(`<(https?://[^>\s]+)>`)
+	orgBareURLRe     = regexp.MustCompile(`https?://[^\s<>\x00]+`)
+	orgBoldRe        = regexp.MustCompile(`\*\*(\S(?:.*?\S)?)\*\*|__(\S(?:.*?\S)?)__`)
+	orgStrikeRe      = regexp.MustCompile(`~~(\S(?:.*?\S)?)~~`)
+	orgItalicRe      = regexp.MustCompile(`(^|[^\w*])\*(\S(?:[^*]*?\S)?)\*($|[^\w*])|(^|[^\w_])_(\S(?:[^_]*?\S)?)_($|[^\w_])`)
+	orgStashRe       = regexp.MustCompile("\x00([0-9]+)\x00")
+)
+
+// MarkdownToOrg converts a rendered Markdown document to Emacs Org-mode for
+// -format org. YAML frontmatter becomes "#+KEY: value" keywords (#+TITLE:,
+// #+DATE:, ...), and headings, emphasis, links, code, quotes, tables and
+// footnotes become their Org forms. Of the HTML the renderers emit, figures
+// and <details> blocks are converted; other HTML is left as is.
+func MarkdownToOrg(md string) string {
+	keywords, body := orgKeywords(md)
+	return keywords + orgBlocks(body)
+}
+
+// orgKeywords converts doc's leading frontmatter, block or compact, into Org
+// keywords, returning them and the rest of doc. List and map values are
+// joined with ", ".
+func orgKeywords(doc string) (string, string) {
+	if !strings.HasPrefix(doc, "---\n") {
+		return "", doc
+	}
+	end := strings.Index(doc[4:], "\n---\n")
+	if end == -1 {
+		return "", doc
+	}
+	header := doc[4 : 4+end]
+	body := strings.TrimLeft(doc[4+end+len("\n---\n"):], "\n")
+
+	lines := strings.Split(header, "\n")
+	if strings.HasPrefix(header, "{") && strings.HasSuffix(header, "}") {
+		lines = splitFlowMapping(header[1 : len(header)-1])
+	}
+
+	var keys []string
+	values := make(map[string][]string)
+	for _, line := range lines {
+		if item, ok := strings.CutPrefix(line, "  - "); ok && len(keys) > 0 {
+			last := keys[len(keys)-1]
+			values[last] = append(values[last], yamlUnquote(item))
+			continue
+		}
+		if entry, ok := strings.CutPrefix(line, "  "); ok && len(keys) > 0 {
+			k, v, _ := strings.Cut(entry, ": ")
+			last := keys[len(keys)-1]
+			values[last] = append(values[last], yamlUnquote(k)+": "+yamlUnquote(v))
+			continue
+		}
+		key, value, _ := strings.Cut(strings.TrimSuffix(line, ":"), ": ")
+		if key == "" {
+			continue
+		}
+		keys = append(keys, key)
+		if (strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]")) ||
+			(strings.HasPrefix(value, "{") && strings.HasSuffix(value, "}")) {
+			for _, item := range splitFlowMapping(value[1 : len(value)-1]) {
+				k, v, isMap := strings.Cut(item, ": ")
+				if isMap {
+					item = yamlUnquote(k) + ": " + yamlUnquote(v)
+				} else {
+					item = yamlUnquote(item)
+				}
+				if item != "" {
+					values[key] = append(values[key], item)
+				}
+			}
+			continue
+		}
+		if value != "" {
+			values[key] = append(values[key], yamlUnquote(value))
+		}
+	}
+
+	var sb strings.Builder
+	for _, key := range keys {
+		value := strings.Join(values[key], ", ")
+		value = strings.ReplaceAll(value, "\n", " ")
+		sb.WriteString("#+" + strings.ToUpper(key) + ": " + value + "\n")
+	}
+	sb.WriteString("\n")
+	return sb.String(), body
+}
+
+// yamlUnquote undoes yamlEscape's double quoting.
+func yamlUnquote(s string) string {
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		return unquoted
+	}
+	return s
+}
+
+// orgBlocks converts the block structure of md: code blocks, blockquotes
+// (callouts become special blocks such as #+BEGIN_NOTE), figures, <details>
+// and everything else line by line.
+func orgBlocks(md string) string {
+	lines := strings.Split(md, "\n")
+	var out []string
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case strings.HasPrefix(line, "```"):
+			out = append(out, strings.TrimSpace("#+BEGIN_SRC "+strings.TrimSpace(line[3:])))
+			for i++; i < len(lines) && !strings.HasPrefix(lines[i], "```"); i++ {
+				out = append(out, orgCodeEscapeRe.ReplaceAllString(lines[i], "$1,$2"))
+			}
+			out = append(out, "#+END_SRC")
+
+		case strings.HasPrefix(line, ">"):
+			var quoted []string
+			for ; i < len(lines) && strings.HasPrefix(lines[i], ">"); i++ {
+				quoted = append(quoted, strings.TrimPrefix(lines[i][1:], " "))
+			}
+			i--
+			block := "QUOTE"
+			if m := orgCalloutRe.FindStringSubmatch(quoted[0]); m != nil {
+				block, quoted = strings.ToUpper(m[1]), quoted[1:]
+			}
+			out = append(out, "#+BEGIN_"+block, orgBlocks(strings.Join(quoted, "\n")), "#+END_"+block)
+
+		case line == "<figure>":
+			var src, caption string
+			for i++; i < len(lines) && lines[i] != "</figure>"; i++ {
+				if m := orgImgRe.FindStringSubmatch(lines[i]); m != nil {
+					src = html.UnescapeString(m[1])
+				} else if m := orgCaptionRe.FindStringSubmatch(lines[i]); m != nil {
+					caption = html.UnescapeString(m[1])
+				}
+			}
+			if caption != "" {
+				out = append(out, "#+CAPTION: "+caption)
+			}
+			out = append(out, "[["+src+"]]")
+
+		default:
+			out = append(out, orgLine(line))
+		}
+	}
+	return strings.Join(out, "\n")
+}
+
+// orgLine converts a single line outside code blocks and quotes.
+func orgLine(line string) string {
+	if m := orgHeadingRe.FindStringSubmatch(line); m != nil {
+		return strings.Repeat("*", len(m[1])) + " " + orgInline(m[2])
+	}
+	switch {
+	case orgRuleRe.MatchString(line):
+		return "-----"
+	case line == "<details>":
+		return "#+BEGIN_DETAILS"
+	case line == "</details>":
+		return "#+END_DETAILS"
+	case orgTableRuleRe.MatchString(line):
+		row := strings.ReplaceAll(line[1:len(line)-1], ":", "-")
+		return "|" + strings.ReplaceAll(strings.ReplaceAll(row, " ", "-"), "|", "+") + "|"
+	}
+	if m := orgSummaryRe.FindStringSubmatch(line); m != nil {
+		return orgInline(html.UnescapeString(m[1]))
+	}
+	if m := orgImgRe.FindStringSubmatch(line); m != nil {
+		return "[[" + html.UnescapeString(m[1]) + "]]"
+	}
+
+	// A "* " list item would read as a heading.
+	indent := len(line) - len(strings.TrimLeft(line, " "))
+	if rest, ok := strings.CutPrefix(line[indent:], "* "); ok {
+		line = line[:indent] + "- " + rest
+	}
+	if m := orgFootnoteDefRe.FindStringSubmatch(line); m != nil {
+		line = "[fn:" + m[1] + "] " + line[len(m[0]):]
+	}
+	hardBreak := strings.HasSuffix(line, "  ")
+	line = orgInline(strings.TrimRight(line, " "))
+	if hardBreak {
+		line += " \\\\"
+	}
+	return line
+}
+
+// orgInline converts inline Markdown: code spans, escapes, footnote
+// references, images, links and emphasis. Escaped characters are kept
+// literally. Converted code and links are set
+// aside while emphasis is rewritten so URLs and code keep their characters.
+func orgInline(s string) string {
+	var stash []string
+	keep := func(org string) string {
+		stash = append(stash, org)
+		return "\x00" + strconv.Itoa(len(stash)-1) + "\x00"
+	}
+
+	s = orgCodeSpanRe.ReplaceAllStringFunc(s, func(m string) string {
+		code := codeSpanText(m)
+		if strings.Contains(code, "~") && !strings.Contains(code, "=") {
+			return keep("=" + code + "=")
+		}
+		return keep("~" + code + "~")
+	})
+	s = orgEscapeRe.ReplaceAllStringFunc(s, func(m string) string {
+		return keep(m[1:])
+	})
+	s = orgFootnoteRefRe.ReplaceAllStringFunc(s, func(m string) string {
+		return keep("[fn:" + m[2:len(m)-1] + "]")
+	})
+	// Org shows a link whose description is an image URL as that image.
+	s = orgLinkedImageRe.ReplaceAllStringFunc(s, func(m string) string {
+		sub := orgLinkedImageRe.FindStringSubmatch(m)
+		return keep("[[" + sub[2] + "][" + sub[1] + "]]")
+	})
+	s = orgImageRe.ReplaceAllStringFunc(s, func(m string) string {
+		return keep("[[" + orgImageRe.FindStringSubmatch(m)[1] + "]]")
+	})
+	s = orgLinkRe.ReplaceAllStringFunc(s, func(m string) string {
+		sub := orgLinkRe.FindStringSubmatch(m)
+		if sub[1] == "" || sub[1] == sub[2] {
+			return keep("[[" + sub[2] + "]]")
+		}
+		return keep("[[" + sub[2] + "][" + orgEmphasis(sub[1]) + "]]")
+	})
+	s = orgAutolinkRe.ReplaceAllStringFunc(s, func(m string) string {
+		return keep("[[" + m[1:len(m)-1] + "]]")
+	})
+	s = orgBareURLRe.ReplaceAllStringFunc(s, keep)
+
+	s = orgEmphasis(s)
+	// Stashed link descriptions may hold stashed escapes.
+	for orgStashRe.MatchString(s) {
+		s = orgStashRe.ReplaceAllStringFunc(s, func(m string) string {
+			i, _ := strconv.Atoi(m[1 : len(m)-1])
+			return stash[i]
+		})
+	}
+	return s
+}
+
+// codeSpanText returns the text of a code span matched by orgCodeSpanRe,
+// without the delimiters and the space padding a "“ `x` “" span needs.
+func codeSpanText(m string) string {
+	n := 1
+	if strings.HasPrefix(m, "``") {
+		n = 2
+	}
+	code := m[n : len(m)-n]
+	if len(code) > 2 && code[0] == ' ' && code[len(code)-1] == ' ' {
+		code = code[1 : len(code)-1]
+	}
+	return code
+}
+
+// orgEmphasis rewrites **bold**, *italic*, _italic_ and ~~strikethrough~~ as
+// Org's *bold*, /italic/ and +strikethrough+.
+func orgEmphasis(s string) string {
+	// Bold is marked with \x01 until italics, which use "*", are done.
+	s = orgBoldRe.ReplaceAllString(s, "\x01$1$2\x01")
+	s = orgStrikeRe.ReplaceAllString(s, "+$1+")
+	// Matches consume the character on either side, so adjacent spans such
+	// as "*a* *b*" need a second pass.
+	for range 2 {
+		s = orgItalicRe.ReplaceAllString(s, "$1$4/$2$5/$3$6")
+	}
+	return strings.ReplaceAll(s, "\x01", "*")
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMarkdownToOrgGolden(t *testing.T) {
+	md, err := os.ReadFile(filepath.Join("testdata", "code.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := os.ReadFile(filepath.Join("testdata", "code.org"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := MarkdownToOrg(string(md)); got != string(want) {
+		t.Errorf("MarkdownToOrg() =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Paragraph modes accepted by RenderOptions.Paragraphs.
+const (
+	ParagraphsBreaks = "breaks" // single newlines become hard line breaks
+	ParagraphsBlank  = "blank"  // single newlines become paragraph breaks
+)
+
+// blockLineRe matches lines that start Markdown block syntax (quotes, list
+// items, tables, headings), whose newlines already mean something.
+var blockLineRe = regexp.MustCompile(`^\s*(?:>|[-*+]\s|\d+[.)]\s|\||#{1,6}\s)`)
+
+// applyParagraphs makes the single newlines tweet authors use between
+// paragraphs survive Markdown rendering, which would otherwise join the
+// lines; renderers join with a space, which is also wrong for CJK text.
+// With ParagraphsBreaks a line ends in a two-space hard break, with
+// ParagraphsBlank a blank line is inserted. Newlines next to blank lines,
+// block syntax or fenced code are left alone.
+func applyParagraphs(text, mode string) string {
+	if mode != ParagraphsBreaks && mode != ParagraphsBlank {
+		return text
+	}
+	lines := strings.Split(text, "\n")
+	var sb strings.Builder
+	inFence := false
+	for i, line := range lines {
+		if strings.HasPrefix(strings.TrimLeft(line, " "), "```") {
+			inFence = !inFence
+		}
+		sb.WriteString(line)
+		if i == len(lines)-1 {
+			break
+		}
+		next := lines[i+1]
+		if inFence || !proseLine(line) || !proseLine(next) {
+			sb.WriteString("\n")
+			continue
+		}
+		if mode == ParagraphsBlank {
+			sb.WriteString("\n\n")
+		} else {
+			sb.WriteString(strings.Repeat(" ", 2-trailingSpaces(line)) + "\n")
+		}
+	}
+	return sb.String()
+}
+
+// proseLine reports whether line is non-blank text outside block syntax.
+func proseLine(line string) bool {
+	return strings.TrimSpace(line) != "" && !blockLineRe.MatchString(line) &&
+		!strings.HasPrefix(strings.TrimLeft(line, " "), "```")
+}
+
+// trailingSpaces counts the spaces ending line, at most two.
+func trailingSpaces(line string) int {
+	n := len(line) - len(strings.TrimRight(line, " "))
+	return min(n, 2)
+}
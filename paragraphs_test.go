@@ -0,0 +1,50 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestApplyParagraphs(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		mode string
+		want string
+	}{
+		{"off", "一\n二", "", "一\n二"},
+		{"breaks", "一\n二", ParagraphsBreaks, "一  \n二"},
+		{"blank", "一\n二", ParagraphsBlank, "一\n\n二"},
+		{"existing trailing space", "one \ntwo", ParagraphsBreaks, "one  \ntwo"},
+		{"already separated", "一\n\n二", ParagraphsBlank, "一\n\n二"},
+		{"list", "要点：\n- 一\n- 二", ParagraphsBlank, "要点：\n- 一\n- 二"},
+		{"fence", "```\na\nb\n```", ParagraphsBreaks, "```\na\nb\n```"},
+	}
+	for _, tt := range tests {
+		if got := applyParagraphs(tt.text, tt.mode); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRenderCJKParagraphs(t *testing.T) {
+	tweet := testThread("今天发布了新版本。\n欢迎大家试用并反馈问题。")[0]
+
+	got := docBody(RenderTweet(tweet, RenderOptions{Paragraphs: ParagraphsBlank}))
+	if !strings.Contains(got, "今天发布了新版本。\n\n欢迎大家试用并反馈问题。\n") {
+		t.Errorf("blank mode did not separate paragraphs:\n%q", got)
+	}
+	// The hard break's trailing spaces survive output cleanup.
+	got = docBody(RenderTweet(tweet, RenderOptions{Paragraphs: ParagraphsBreaks}))
+	if !strings.Contains(got, "今天发布了新版本。  \n欢迎大家试用并反馈问题。\n") {
+		t.Errorf("breaks mode lost the hard break:\n%q", got)
+	}
+	// No space is inserted between CJK lines.
+	if strings.Contains(got, "。 欢") {
+		t.Errorf("space inserted between CJK lines:\n%q", got)
+	}
+	got = docBody(RenderTweet(tweet, RenderOptions{}))
+	if !strings.Contains(got, "今天发布了新版本。\n欢迎大家试用并反馈问题。\n") {
+		t.Errorf("default changed the text:\n%q", got)
+	}
+}
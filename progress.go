@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// fetchProgress reports fetch progress on stderr when -progress is set; nil
+// disables it.
+var fetchProgress *progress
+
+// progress is a single status line updated in place, showing the position in
+// a batch of URLs and the number of thread tweets fetched so far.
+type progress struct {
+	mu      sync.Mutex
+	w       io.Writer
+	item    int // 1-based position in the batch
+	total   int // number of URLs in the batch
+	fetched int // tweets fetched for the current item
+}
+
+// newProgress returns a progress line on w, or nil when w is not a terminal
+// since in-place updates would only clutter logs and pipes.
+func newProgress(w io.Writer) *progress {
+	if !isTerminal(w) {
+		return nil
+	}
+	return &progress{w: w}
+}
+
+// isTerminal reports whether w is a character device such as a TTY.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// StartItem moves to the item-th of total URLs.
+func (p *progress) StartItem(item, total int) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.item, p.total, p.fetched = item, total, 0
+	p.draw()
+}
+
+// Fetched records that n tweets of the current item have been fetched.
+func (p *progress) Fetched(n int) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.fetched = n
+	p.draw()
+}
+
+// Clear erases the progress line so other messages start on a clean line.
+func (p *progress) Clear() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	fmt.Fprint(p.w, "\r\033[K")
+}
+
+func (p *progress) draw() {
+	line := ""
+	if p.total > 1 {
+		line = fmt.Sprintf("进度: %d/%d", p.item, p.total)
+	}
+	if p.fetched > 0 {
+		if line != "" {
+			line += "，"
+		}
+		line += fmt.Sprintf("已获取 %d 条推文", p.fetched)
+	}
+	if line == "" {
+		return
+	}
+	fmt.Fprint(p.w, "\r\033[K"+line)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestProgressSuppressedWithoutTTY(t *testing.T) {
+	var buf bytes.Buffer
+	if p := newProgress(&buf); p != nil {
+		t.Errorf("newProgress(buffer) = %v, want nil", p)
+	}
+	f, err := os.Create(filepath.Join(t.TempDir(), "stderr.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	p := newProgress(f)
+	if p != nil {
+		t.Errorf("newProgress(regular file) = %v, want nil", p)
+	}
+
+	// A disabled indicator accepts updates and writes nothing.
+	p.StartItem(1, 3)
+	p.Fetched(5)
+	p.Clear()
+	if info, err := f.Stat(); err != nil || info.Size() != 0 {
+		t.Errorf("disabled progress wrote to the file (%v)", err)
+	}
+}
+
+func TestProgressLine(t *testing.T) {
+	var buf bytes.Buffer
+	p := &progress{w: &buf}
+	p.StartItem(2, 3)
+	p.Fetched(4)
+	p.Clear()
+	want := "\r\033[K进度: 2/3" + "\r\033[K进度: 2/3，已获取 4 条推文" + "\r\033[K"
+	if got := buf.String(); got != want {
+		t.Errorf("progress output = %q, want %q", got, want)
+	}
+
+	// A single URL shows only the tweet count, and nothing until one arrives.
+	buf.Reset()
+	p.StartItem(1, 1)
+	p.Fetched(1)
+	if got := buf.String(); strings.Contains(got, "进度") || got != "\r\033[K已获取 1 条推文" {
+		t.Errorf("single-item progress = %q", got)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const defaultRPS = 2.0
+
+// apiLimiter throttles every FxTwitter API request made during a run.
+var apiLimiter = newRateLimiter(defaultRPS)
+
+// rateLimiter is a token bucket allowing rps requests per second with a
+// burst of one. A zero or negative rate disables throttling.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+
+	// now and sleep are swappable for deterministic timing.
+	now   func() time.Time
+	sleep func(context.Context, time.Duration) error
+}
+
+func newRateLimiter(rps float64) *rateLimiter {
+	return &rateLimiter{
+		rate:   rps,
+		tokens: 1,
+		now:    time.Now,
+		sleep:  sleepContext,
+	}
+}
+
+// sleepContext pauses for d, returning ctx's error early if it is cancelled.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Wait blocks until a request is allowed or ctx is cancelled, in which case
+// it returns ctx's error and gives back the request's turn.
+func (l *rateLimiter) Wait(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if l == nil || l.rate <= 0 {
+		return nil
+	}
+
+	l.mu.Lock()
+	now := l.now()
+	if !l.last.IsZero() {
+		l.tokens += now.Sub(l.last).Seconds() * l.rate
+		if l.tokens > 1 {
+			l.tokens = 1
+		}
+	}
+	l.last = now
+	// Reserve a token; a negative balance is the debt later callers wait out.
+	l.tokens--
+	var wait time.Duration
+	if l.tokens < 0 {
+		wait = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+
+	if wait == 0 {
+		return nil
+	}
+	if err := l.sleep(ctx, wait); err != nil {
+		l.mu.Lock()
+		l.tokens++
+		l.mu.Unlock()
+		return err
+	}
+	return nil
+}
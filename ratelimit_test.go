@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock whose sleeps advance time instantly.
+type fakeClock struct {
+	t      time.Time
+	sleeps []time.Duration
+}
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func (c *fakeClock) sleep(ctx context.Context, d time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c.sleeps = append(c.sleeps, d)
+	c.t = c.t.Add(d)
+	return nil
+}
+
+func newFakeLimiter(rps float64) (*rateLimiter, *fakeClock) {
+	clock := &fakeClock{t: time.Unix(1700000000, 0)}
+	l := newRateLimiter(rps)
+	l.now, l.sleep = clock.now, clock.sleep
+	return l, clock
+}
+
+func TestRateLimiterSpacing(t *testing.T) {
+	l, clock := newFakeLimiter(2)
+
+	var starts []time.Time
+	for range 4 {
+		l.Wait(context.Background())
+		starts = append(starts, clock.now())
+	}
+	// The first request goes out at once; the rest are 1/rps apart.
+	if starts[0] != time.Unix(1700000000, 0) {
+		t.Errorf("first request delayed to %v", starts[0])
+	}
+	for i := 1; i < len(starts); i++ {
+		if gap := starts[i].Sub(starts[i-1]); gap != 500*time.Millisecond {
+			t.Errorf("gap before request %d = %v, want 500ms", i+1, gap)
+		}
+	}
+}
+
+func TestRateLimiterIdleRefill(t *testing.T) {
+	l, clock := newFakeLimiter(1)
+
+	l.Wait(context.Background())
+	clock.t = clock.t.Add(5 * time.Second)
+	l.Wait(context.Background())
+	if len(clock.sleeps) != 0 {
+		t.Errorf("request after idle period slept %v", clock.sleeps)
+	}
+	// The bucket holds a single token, so idling does not allow a burst.
+	l.Wait(context.Background())
+	if len(clock.sleeps) != 1 || clock.sleeps[0] != time.Second {
+		t.Errorf("sleeps = %v, want [1s]", clock.sleeps)
+	}
+}
+
+func TestRateLimiterDisabled(t *testing.T) {
+	l, clock := newFakeLimiter(0)
+	for range 3 {
+		l.Wait(context.Background())
+	}
+	if len(clock.sleeps) != 0 {
+		t.Errorf("disabled limiter slept %v", clock.sleeps)
+	}
+}
+
+func TestRateLimiterCancel(t *testing.T) {
+	l, clock := newFakeLimiter(1)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	if err := l.Wait(ctx); err != nil {
+		t.Fatalf("first Wait: %v", err)
+	}
+	// The second request must wait; cancel while it does.
+	l.sleep = func(ctx context.Context, d time.Duration) error {
+		cancel()
+		return clock.sleep(ctx, d)
+	}
+	if err := l.Wait(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Wait cancelled while waiting = %v, want context.Canceled", err)
+	}
+	if err := l.Wait(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Wait after cancel = %v, want context.Canceled", err)
+	}
+	if len(clock.sleeps) != 0 {
+		t.Errorf("cancelled Wait slept %v", clock.sleeps)
+	}
+
+	// Cancelled waits do not use up the next request's turn.
+	l.sleep = clock.sleep
+	if err := l.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(clock.sleeps) != 1 || clock.sleeps[0] != time.Second {
+		t.Errorf("sleeps = %v, want [1s]", clock.sleeps)
+	}
+
+	// The real sleep returns as soon as the context is done.
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	start := time.Now()
+	if err := sleepContext(ctx, time.Hour); !errors.Is(err, context.Canceled) {
+		t.Errorf("sleepContext = %v, want context.Canceled", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("sleepContext returned after %v", elapsed)
+	}
+}
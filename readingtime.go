@@ -0,0 +1,53 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// defaultWPM is the reading speed assumed for reading_time.
+const defaultWPM = 200
+
+var (
+	readingImageRe = regexp.MustCompile(`!\[[^\]]*\]\([^)]*\)`)
+	readingLinkRe  = regexp.MustCompile(`\[([^\]]*)\]\([^)]*\)`)
+)
+
+// countWords estimates the word count of Markdown text. Latin text is split
+// on whitespace; CJK characters count as half a word each, since a Chinese
+// or Japanese word is about two characters long.
+func countWords(markdown string) int {
+	text := readingImageRe.ReplaceAllString(markdown, " ")
+	text = readingLinkRe.ReplaceAllString(text, "$1")
+
+	cjk := 0
+	text = strings.Map(func(r rune) rune {
+		if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+			cjk++
+			return ' '
+		}
+		return r
+	}, text)
+
+	latin := 0
+	for _, field := range strings.Fields(text) {
+		// Skip pure Markdown punctuation such as "#", "-", "---" or ">".
+		if strings.IndexFunc(field, func(r rune) bool {
+			return unicode.IsLetter(r) || unicode.IsNumber(r)
+		}) >= 0 {
+			latin++
+		}
+	}
+	return latin + cjk/2
+}
+
+// readingMinutes returns the estimated reading time in whole minutes,
+// rounded up.
+func readingMinutes(markdown string, wpm int) int {
+	if wpm <= 0 {
+		wpm = defaultWPM
+	}
+	words := countWords(markdown)
+	return (words + wpm - 1) / wpm
+}
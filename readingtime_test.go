@@ -0,0 +1,51 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCountWords(t *testing.T) {
+	tests := []struct {
+		md   string
+		want int
+	}{
+		{"one two three", 3},
+		{"## Heading\n\n- item one\n\n---\n\n> quoted text", 5},
+		{"see [the docs](https://example.com/a/b) now ![alt](https://example.com/i.png)", 4},
+		{"中文字数", 2},
+		{"Go 语言入门", 3},
+	}
+	for _, tt := range tests {
+		if got := countWords(tt.md); got != tt.want {
+			t.Errorf("countWords(%q) = %d, want %d", tt.md, got, tt.want)
+		}
+	}
+}
+
+func TestReadingMinutes(t *testing.T) {
+	words := func(n int) string { return strings.TrimSpace(strings.Repeat("word ", n)) }
+	tests := []struct {
+		md   string
+		wpm  int
+		want int
+	}{
+		{words(400), 200, 2},
+		{words(401), 200, 3},
+		{words(1), 200, 1},
+		{"", 200, 0},
+		{words(300), 0, 2}, // default 200 wpm
+		{words(300), 100, 3},
+		{strings.Repeat("字", 800), 200, 2},
+	}
+	for _, tt := range tests {
+		if got := readingMinutes(tt.md, tt.wpm); got != tt.want {
+			t.Errorf("readingMinutes(%d chars, %d) = %d, want %d", len(tt.md), tt.wpm, got, tt.want)
+		}
+	}
+
+	tweet, info := testArticle(Block{Type: "unstyled", Text: words(450)})
+	if got := RenderArticle(tweet, info, RenderOptions{ReadingWPM: 200}); !strings.Contains(got, "reading_time: 3\n") {
+		t.Errorf("article frontmatter missing reading_time: 3:\n%s", got[:strings.Index(got, "# Title")])
+	}
+}
@@ -1,28 +1,166 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"html"
+	"io"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
+	"unicode"
 )
 
+// RenderOptions controls optional rendering behavior.
+type RenderOptions struct {
+	// Renumber strips the author's manual "1/" style numbering from thread
+	// tweets and emits a "## N." header per tweet instead.
+	Renumber bool
+	// ThreadStyle selects how thread tweets are joined: ThreadStyleSeparated
+	// (default, "---" between tweets) or ThreadStyleContinuous.
+	ThreadStyle string
+	// ThreadOrder selects ThreadOrderOldest (default) or ThreadOrderNewest
+	// output order for thread tweets; frontmatter is the same either way.
+	ThreadOrder string
+	// ThreadPermalinks appends a link to each tweet on X in thread output.
+	ThreadPermalinks bool
+	// NoStats omits the engagement counts (likes, retweets, ...) from frontmatter.
+	NoStats bool
+	// Autolink wraps bare URLs in tweet text in <...> autolinks.
+	Autolink bool
+	// AppendRaw appends the source API JSON in a collapsed block at the end.
+	AppendRaw bool
+	// Normalize strips invisible characters and converts non-breaking spaces in text.
+	Normalize bool
+	// Aliases adds an "aliases" list with the status ID and fixupx.com link.
+	Aliases bool
+	// StripSelfMentions removes a leading "@author " from non-first thread tweets.
+	StripSelfMentions bool
+	// MarkSensitive wraps media of possibly sensitive tweets in a <details> block.
+	MarkSensitive bool
+	// NoMedia skips images and videos in the body, including article media;
+	// frontmatter still references the cover image.
+	NoMedia bool
+	// DedupeMedia omits thread media whose URL an earlier tweet already showed.
+	DedupeMedia bool
+	// ReadingWPM adds a reading_time (minutes) field to article frontmatter,
+	// estimated at this many words per minute; 0 disables it.
+	ReadingWPM int
+	// CleanParams lists query parameter patterns (e.g. "utm_*") stripped from
+	// links in tweet text and article bodies; nil leaves links untouched.
+	CleanParams []string
+	// Meta holds extra frontmatter fields, appended after the built-in ones
+	// or replacing a built-in field with the same key.
+	Meta []MetaField
+	// KeepOriginal keeps the original text in a blockquote below a translation.
+	KeepOriginal bool
+	// Callouts maps lowercased blockquote prefixes (e.g. "note") to callout
+	// types (e.g. "NOTE"); nil leaves blockquotes as they are.
+	Callouts map[string]string
+	// FlattenQuote renders quoted tweets as an inline parenthetical truncated
+	// to this many characters instead of a blockquote; 0 keeps the blockquote.
+	FlattenQuote int
+	// PreserveColor keeps colored article text in an HTML <span> with its
+	// color; by default only the text is kept.
+	PreserveColor bool
+	// Title overrides an article's title in its frontmatter and heading, and
+	// adds a title field to tweet and thread frontmatter; "" changes nothing.
+	Title string
+	// ShortURL adds a short_url field with the fixupx.com link to frontmatter.
+	ShortURL bool
+	// LinkEntities links @mentions and #hashtags in tweet text and article
+	// bodies to their X pages.
+	LinkEntities bool
+	// Frontmatter is FrontmatterBlock (also used when empty) or
+	// FrontmatterCompact.
+	Frontmatter string
+	// DescriptionLength caps the article description taken from its preview
+	// text, in characters; 0 keeps it whole.
+	DescriptionLength int
+	// Figure renders photos, the article cover and article images as HTML
+	// <figure> blocks captioned with their alt text.
+	Figure bool
+	// SortFrontmatter writes frontmatter keys in alphabetical order instead
+	// of the renderers' own order.
+	SortFrontmatter bool
+	// PollStyle is PollStyleBar (also used when empty) or PollStyleTable.
+	PollStyle string
+	// Footnotes turns an article's trailing list of source links into
+	// Markdown footnotes cited from the body.
+	Footnotes bool
+	// Paragraphs keeps single newlines in tweet text visible: ParagraphsBreaks
+	// or ParagraphsBlank; empty leaves the text as is.
+	Paragraphs string
+	// BodyOnly drops frontmatter and everything around the body: an article
+	// renders as its converted content, a tweet as its text and media, and a
+	// thread as its tweets.
+	BodyOnly bool
+	// Since and Until keep only the thread tweets posted at or after Since
+	// and before Until; a zero bound is open. Frontmatter then describes the
+	// remaining tweets.
+	Since, Until time.Time
+}
+
+// Thread styles accepted by RenderOptions.ThreadStyle.
+const (
+	ThreadStyleSeparated  = "separated"
+	ThreadStyleContinuous = "continuous"
+)
+
+// Thread orders accepted by RenderOptions.ThreadOrder.
+const (
+	ThreadOrderOldest = "oldest"
+	ThreadOrderNewest = "newest"
+)
+
+// yamlAmbiguousRe matches plain scalars YAML would read as non-strings.
+var yamlAmbiguousRe = regexp.MustCompile(`(?i)^(?:[-+]?[0-9][0-9_.,e+-]*|true|false|yes|no|on|off|null|~)$`)
+
 // yamlEscape escapes a string for use as a YAML value.
-// Wraps in quotes if the string contains special characters.
+// Wraps in quotes if the string contains special characters, starts with a
+// YAML indicator, or would otherwise parse as a number, bool or null.
 func yamlEscape(s string) string {
 	if s == "" {
 		return `""`
 	}
-	if strings.ContainsAny(s, ":#{}[]|>&*!,?\\\"'\n") {
+	if strings.ContainsAny(s, ":#{}[]|>&*!,?\\\"'\n") ||
+		strings.ContainsAny(s[:1], "@`-% ") || strings.HasSuffix(s, " ") ||
+		yamlAmbiguousRe.MatchString(s) {
 		escaped := strings.ReplaceAll(s, `\`, `\\`)
 		escaped = strings.ReplaceAll(escaped, `"`, `\"`)
+		escaped = strings.ReplaceAll(escaped, "\n", `\n`)
 		return `"` + escaped + `"`
 	}
 	return s
 }
 
+// Poll styles accepted by RenderOptions.PollStyle.
+const (
+	PollStyleBar   = "bar"
+	PollStyleTable = "table"
+)
+
+// Frontmatter styles accepted by RenderOptions.Frontmatter.
+const (
+	FrontmatterBlock   = "block"
+	FrontmatterCompact = "compact"
+)
+
 // writeFrontmatter writes YAML frontmatter from key-value pairs.
-// Only writes non-empty string values and non-zero int values.
-func writeFrontmatter(sb *strings.Builder, fields []frontmatterField) {
+// Only writes non-empty string values, int values, true bool values, and
+// non-empty lists ([]string, []int as block sequences) and string maps
+// (as nested mappings, sorted by key). The compact style writes the same
+// fields as a single flow mapping instead.
+func writeFrontmatter(sb io.StringWriter, fields []frontmatterField, style string) {
+	if style == FrontmatterCompact {
+		writeCompactFrontmatter(sb, fields)
+		return
+	}
 	sb.WriteString("---\n")
 	for _, f := range fields {
 		switch v := f.value.(type) {
@@ -34,43 +172,228 @@ func writeFrontmatter(sb *strings.Builder, fields []frontmatterField) {
 			sb.WriteString(fmt.Sprintf("%s: %d\n", f.key, v))
 		case int64:
 			sb.WriteString(fmt.Sprintf("%s: %d\n", f.key, v))
+		case Count:
+			sb.WriteString(fmt.Sprintf("%s: %d\n", f.key, v))
+		case bool:
+			if v {
+				sb.WriteString(fmt.Sprintf("%s: true\n", f.key))
+			}
+		case []string:
+			if len(v) > 0 {
+				sb.WriteString(f.key + ":\n")
+				for _, item := range v {
+					sb.WriteString("  - " + yamlEscape(item) + "\n")
+				}
+			}
+		case []int:
+			if len(v) > 0 {
+				sb.WriteString(f.key + ":\n")
+				for _, item := range v {
+					sb.WriteString(fmt.Sprintf("  - %d\n", item))
+				}
+			}
+		case map[string]string:
+			if len(v) > 0 {
+				sb.WriteString(f.key + ":\n")
+				keys := make([]string, 0, len(v))
+				for k := range v {
+					keys = append(keys, k)
+				}
+				sort.Strings(keys)
+				for _, k := range keys {
+					sb.WriteString(fmt.Sprintf("  %s: %s\n", yamlEscape(k), yamlEscape(v[k])))
+				}
+			}
 		}
 	}
 	sb.WriteString("---\n\n")
 }
 
+// writeCompactFrontmatter writes fields as one YAML flow mapping, e.g.
+// {type: tweet, author: "@x", tags: [a, b]}.
+func writeCompactFrontmatter(sb io.StringWriter, fields []frontmatterField) {
+	var entries []string
+	for _, f := range fields {
+		if v, ok := flowValue(f.value); ok {
+			entries = append(entries, f.key+": "+v)
+		}
+	}
+	sb.WriteString("---\n{" + strings.Join(entries, ", ") + "}\n---\n\n")
+}
+
+// flowValue formats a frontmatter value in YAML flow style, reporting false
+// for values writeFrontmatter omits.
+func flowValue(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return yamlEscape(v), v != ""
+	case int:
+		return strconv.Itoa(v), true
+	case int64:
+		return strconv.FormatInt(v, 10), true
+	case Count:
+		return strconv.Itoa(int(v)), true
+	case bool:
+		return "true", v
+	case []string:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = yamlEscape(item)
+		}
+		return "[" + strings.Join(items, ", ") + "]", len(v) > 0
+	case []int:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = strconv.Itoa(item)
+		}
+		return "[" + strings.Join(items, ", ") + "]", len(v) > 0
+	case map[string]string:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		items := make([]string, len(keys))
+		for i, k := range keys {
+			items[i] = yamlEscape(k) + ": " + yamlEscape(v[k])
+		}
+		return "{" + strings.Join(items, ", ") + "}", len(v) > 0
+	}
+	return "", false
+}
+
 type frontmatterField struct {
 	key   string
 	value interface{}
 }
 
+// MetaField is a user-supplied frontmatter field.
+type MetaField struct {
+	Key   string
+	Value string
+}
+
+// frontmatterFields applies -meta overrides to a renderer's fields and, with
+// SortFrontmatter, orders them by key.
+func frontmatterFields(fields []frontmatterField, opts RenderOptions) []frontmatterField {
+	fields = applyMeta(fields, opts.Meta)
+	if opts.SortFrontmatter {
+		sort.SliceStable(fields, func(i, j int) bool { return fields[i].key < fields[j].key })
+	}
+	return fields
+}
+
+// applyMeta overrides fields with matching keys in place and appends the rest,
+// so later meta fields win over earlier ones and over built-in fields.
+func applyMeta(fields []frontmatterField, meta []MetaField) []frontmatterField {
+	for _, m := range meta {
+		replaced := false
+		for i := range fields {
+			if fields[i].key == m.Key {
+				fields[i].value = m.Value
+				replaced = true
+			}
+		}
+		if !replaced {
+			fields = append(fields, frontmatterField{m.Key, m.Value})
+		}
+	}
+	return fields
+}
+
+// newDocWriter returns the lintWriter a document rendered with opts goes
+// through; -paragraphs breaks relies on hard line breaks surviving it.
+func newDocWriter(w io.StringWriter, opts RenderOptions) *lintWriter {
+	lw := newLintWriter(w)
+	lw.hardBreaks = opts.Paragraphs == ParagraphsBreaks
+	return lw
+}
+
 // RenderTweet renders a single tweet as Markdown with frontmatter.
-func RenderTweet(tweet *Tweet) string {
+func RenderTweet(tweet *Tweet, opts RenderOptions) string {
 	var sb strings.Builder
+	lw := newDocWriter(&sb, opts)
+	writeTweet(lw, tweet, opts)
+	lw.Flush()
+	return sb.String()
+}
 
-	writeTweetFrontmatter(&sb, tweet)
-	writeText(&sb, tweet.Text)
-	writeMedia(&sb, tweet.Media)
-	writePoll(&sb, tweet.Poll)
-	writeQuote(&sb, tweet.Quote)
+// WriteTweet is RenderTweet writing to w as the document is produced.
+func WriteTweet(w io.Writer, tweet *Tweet, opts RenderOptions) error {
+	bw := bufio.NewWriter(w)
+	lw := newDocWriter(bw, opts)
+	writeTweet(lw, tweet, opts)
+	lw.Flush()
+	return bw.Flush()
+}
 
-	return sb.String()
+func writeTweet(sb io.StringWriter, tweet *Tweet, opts RenderOptions) {
+	if !opts.BodyOnly {
+		writeTweetFrontmatter(sb, tweet, opts)
+		writeParent(sb, tweet.Parent)
+	}
+	raw := tweet
+	// A retweet has no content of its own; render the original under a header.
+	if rt := tweet.RetweetedStatus; rt != nil {
+		if !opts.BodyOnly {
+			sb.WriteString(fmt.Sprintf("> Retweeted from %s\n\n", authorHandle(rt)))
+		}
+		tweet = rt
+	}
+	if hasTranslation(tweet) {
+		writeText(sb, tweet.Translation.Text, tweet.Origin, opts)
+		if opts.KeepOriginal {
+			writeOriginalText(sb, tweet.Text)
+		}
+	} else {
+		writeText(sb, tweet.Text, tweet.Origin, opts)
+	}
+	if opts.BodyOnly {
+		writeMedia(sb, tweet, opts, nil)
+		return
+	}
+	writeCommunityNote(sb, tweet.CommunityNote)
+	writePlace(sb, tweet.Place)
+	writeMedia(sb, tweet, opts, nil)
+	writePoll(sb, tweet.Poll, opts)
+	writeQuote(sb, tweet.Quote, opts)
+	writeQuoteThread(sb, tweet.QuoteThread, opts)
+	if opts.AppendRaw {
+		writeRawJSON(sb, []*Tweet{raw})
+	}
 }
 
 // RenderThread renders a thread (multiple tweets) as Markdown with frontmatter.
-func RenderThread(tweets []*Tweet) string {
+func RenderThread(tweets []*Tweet, opts RenderOptions) string {
+	var sb strings.Builder
+	lw := newDocWriter(&sb, opts)
+	writeThread(lw, tweets, opts)
+	lw.Flush()
+	return sb.String()
+}
+
+// WriteThread is RenderThread writing to w as the document is produced.
+func WriteThread(w io.Writer, tweets []*Tweet, opts RenderOptions) error {
+	bw := bufio.NewWriter(w)
+	lw := newDocWriter(bw, opts)
+	writeThread(lw, tweets, opts)
+	lw.Flush()
+	return bw.Flush()
+}
+
+func writeThread(sb io.StringWriter, tweets []*Tweet, opts RenderOptions) {
+	tweets = tweetsBetween(tweets, opts.Since, opts.Until)
 	if len(tweets) == 0 {
-		return ""
+		return
 	}
 
-	var sb strings.Builder
-
 	// Use the first tweet for author/date, last tweet for stats and source URL
 	first := tweets[0]
 	last := tweets[len(tweets)-1]
 
 	fields := []frontmatterField{
 		{"type", "thread"},
+		{"title", opts.Title},
 		{"tweet_count", len(tweets)},
 	}
 	if first.Author != nil {
@@ -79,44 +402,219 @@ func RenderThread(tweets []*Tweet) string {
 			frontmatterField{"author_name", first.Author.Name},
 		)
 	}
-	fields = append(fields, frontmatterField{"date", formatDate(first.CreatedAt)})
+	fields = append(fields, frontmatterField{"date", tweetDate(first)})
 	if last.Author != nil {
-		fields = append(fields, frontmatterField{"source", fmt.Sprintf("https://x.com/%s/status/%s", last.Author.ScreenName, last.ID)})
+		fields = append(fields, frontmatterField{"source", tweetPermalink(last)})
+	}
+	if opts.ShortURL {
+		fields = append(fields, frontmatterField{"short_url", tweetShortURL(last)})
+	}
+	if opts.Aliases {
+		fields = append(fields, frontmatterField{"aliases", tweetAliases(last)})
+	}
+	if !opts.NoStats {
+		fields = append(fields,
+			frontmatterField{"likes", last.Likes},
+			frontmatterField{"retweets", last.Retweets},
+			frontmatterField{"replies", last.Replies},
+			frontmatterField{"views", last.Views},
+		)
+	}
+	for _, tweet := range tweets {
+		if hasCommunityNote(tweet) {
+			fields = append(fields, frontmatterField{"has_community_note", true})
+			break
+		}
+	}
+	if !opts.BodyOnly {
+		writeFrontmatter(sb, frontmatterFields(fields, opts), opts.Frontmatter)
 	}
-	fields = append(fields,
-		frontmatterField{"likes", last.Likes},
-		frontmatterField{"retweets", last.Retweets},
-		frontmatterField{"replies", last.Replies},
-		frontmatterField{"views", last.Views},
-	)
-	writeFrontmatter(&sb, fields)
 
-	for i, tweet := range tweets {
-		if i > 0 {
+	var seenMedia map[string]bool
+	if opts.DedupeMedia {
+		seenMedia = make(map[string]bool)
+	}
+	for k := range tweets {
+		// i is the tweet's position in the thread, k its position in the output.
+		i := k
+		if opts.ThreadOrder == ThreadOrderNewest {
+			i = len(tweets) - 1 - k
+		}
+		tweet := tweets[i]
+		text := tweet.Text
+		if opts.StripSelfMentions && i > 0 && tweet.Author != nil {
+			text = stripLeadingMention(text, tweet.Author.ScreenName)
+		}
+		continuous := opts.ThreadStyle == ThreadStyleContinuous
+		switch {
+		case opts.Renumber:
+			if k > 0 {
+				sb.WriteString("\n")
+			}
+			sb.WriteString(fmt.Sprintf("## %d.\n\n", i+1))
+			text = stripThreadMarker(text, i+1)
+		case continuous && k > 0:
+			sb.WriteString("\n")
+		case k > 0:
 			sb.WriteString("\n---\n\n")
 		}
-		writeText(&sb, tweet.Text)
-		writeMedia(&sb, tweet.Media)
-		writePoll(&sb, tweet.Poll)
-		writeQuote(&sb, tweet.Quote)
+		if continuous {
+			text = stripTrailingThreadMarker(text, i+1)
+		}
+		if article := tweet.Article; article != nil && article.Content != nil {
+			writeEmbeddedArticle(sb, article, opts)
+		} else {
+			writeText(sb, text, tweet.Origin, opts)
+		}
+		writeCommunityNote(sb, tweet.CommunityNote)
+		writeMedia(sb, tweet, opts, seenMedia)
+		writePoll(sb, tweet.Poll, opts)
+		writeQuote(sb, tweet.Quote, opts)
+		writeQuoteThread(sb, tweet.QuoteThread, opts)
+		if link := tweetPermalink(tweet); opts.ThreadPermalinks && link != "" {
+			sb.WriteString(fmt.Sprintf("\n[🔗](%s)\n", link))
+		}
+	}
+	if opts.AppendRaw && !opts.BodyOnly {
+		writeRawJSON(sb, tweets)
 	}
+}
 
-	return sb.String()
+// writeEmbeddedArticle renders an article attached to a thread tweet in place
+// of the tweet text, with its title as a section heading.
+func writeEmbeddedArticle(sb io.StringWriter, article *Article, opts RenderOptions) {
+	if article.Title != "" {
+		sb.WriteString("## " + article.Title + "\n\n")
+	}
+	writeArticleBody(sb, article, opts)
+}
+
+// articleBody converts an article's Draft.js content to Markdown with the
+// link and callout post-processing applied to tweet text.
+func articleBody(article *Article, opts RenderOptions) string {
+	md := DraftJSToMarkdown(article.Content, articleMedia(article, opts), articleDraftOptions(opts))
+	md = applyCallouts(cleanLinks(md, opts.CleanParams), opts.Callouts)
+	if opts.Footnotes {
+		md = applyFootnotes(md)
+	}
+	if opts.LinkEntities {
+		md = linkEntities(md, "")
+	}
+	return md
+}
+
+// writeArticleBody writes articleBody followed by a newline, unless it is
+// empty. Blocks are written as they are converted, except with -footnotes,
+// which needs the whole body to find its list of sources.
+func writeArticleBody(sb io.StringWriter, article *Article, opts RenderOptions) {
+	if opts.Footnotes {
+		if md := articleBody(article, opts); md != "" {
+			sb.WriteString(md + "\n")
+		}
+		return
+	}
+	post := func(md string) string {
+		md = applyCallouts(cleanLinks(md, opts.CleanParams), opts.Callouts)
+		if opts.LinkEntities {
+			md = linkEntities(md, "")
+		}
+		return md
+	}
+	if writeDraftJS(sb, article.Content, articleMedia(article, opts), articleDraftOptions(opts), post) {
+		sb.WriteString("\n")
+	}
+}
+
+// articleDescription returns the preview text as a one-line description, cut
+// to max characters when max is positive.
+func articleDescription(preview string, max int) string {
+	desc := singleLine(preview)
+	if max > 0 {
+		desc = truncateRunes(desc, max)
+	}
+	return desc
+}
+
+// articleDraftOptions returns the Draft.js conversion options selected by opts.
+func articleDraftOptions(opts RenderOptions) draftOptions {
+	return draftOptions{PreserveColor: opts.PreserveColor, Figure: opts.Figure}
+}
+
+// articleMedia returns the media entities to render in an article body; none
+// with -no-media, which drops the Draft.js media blocks.
+func articleMedia(article *Article, opts RenderOptions) []ArticleMedia {
+	if opts.NoMedia {
+		return nil
+	}
+	return article.MediaEntities
 }
 
 // RenderArticle renders an X Article as Markdown with frontmatter.
-func RenderArticle(tweet *Tweet, info URLInfo) string {
+func RenderArticle(tweet *Tweet, info URLInfo, opts RenderOptions) string {
 	var sb strings.Builder
+	lw := newDocWriter(&sb, opts)
+	writeArticle(lw, tweet, info, opts)
+	lw.Flush()
+	return sb.String()
+}
+
+// WriteArticle is RenderArticle writing to w as the document is produced.
+func WriteArticle(w io.Writer, tweet *Tweet, info URLInfo, opts RenderOptions) error {
+	bw := bufio.NewWriter(w)
+	lw := newDocWriter(bw, opts)
+	writeArticle(lw, tweet, info, opts)
+	lw.Flush()
+	return bw.Flush()
+}
 
+func writeArticle(sb io.StringWriter, tweet *Tweet, info URLInfo, opts RenderOptions) {
 	article := tweet.Article
 	if article == nil {
-		return RenderTweet(tweet)
+		writeTweet(sb, tweet, opts)
+		return
+	}
+
+	// Preview-only responses carry no Draft.js blocks; fall back to the preview text.
+	partial := articlePartial(article)
+	// The body is only converted up front when the reading time needs it;
+	// otherwise it is written out block by block after the header.
+	body, buffered := article.PreviewText, true
+	switch {
+	case partial:
+	case opts.ReadingWPM > 0 && !opts.BodyOnly:
+		body = articleBody(article, opts)
+	default:
+		buffered = false
+	}
+	writeBody := func() {
+		switch {
+		case !buffered:
+			writeArticleBody(sb, article, opts)
+		case body != "":
+			sb.WriteString(body + "\n")
+		}
+	}
+	if opts.BodyOnly {
+		if articleUnavailable(article) {
+			// Say so rather than emit an empty document.
+			sb.WriteString(unavailableArticleText(info) + "\n")
+			return
+		}
+		writeBody()
+		return
+	}
+
+	title := article.Title
+	if opts.Title != "" {
+		title = opts.Title
 	}
 
 	// Frontmatter
 	fields := []frontmatterField{
 		{"type", "article"},
-		{"title", article.Title},
+		{"title", title},
+		{"description", articleDescription(article.PreviewText, opts.DescriptionLength)},
+		{"partial", partial},
 	}
 	if tweet.Author != nil {
 		fields = append(fields,
@@ -124,53 +622,136 @@ func RenderArticle(tweet *Tweet, info URLInfo) string {
 			frontmatterField{"author_name", tweet.Author.Name},
 		)
 	}
-	dateStr := formatDate(tweet.CreatedAt)
-	if article.CreatedAt != "" {
-		dateStr = formatDate(article.CreatedAt)
+	dateStr := tweetDate(tweet)
+	if t, ok := parseDate(article.CreatedAt); ok {
+		dateStr = t.UTC().Format(dateLayout)
 	}
 	fields = append(fields, frontmatterField{"date", dateStr})
 	if article.ModifiedAt != "" {
 		fields = append(fields, frontmatterField{"modified", formatDate(article.ModifiedAt)})
 	}
 	fields = append(fields, frontmatterField{"source", info.OriginalURL})
+	if opts.ShortURL {
+		fields = append(fields, frontmatterField{"short_url", tweetShortURL(tweet)})
+	}
+	if opts.Aliases {
+		fields = append(fields, frontmatterField{"aliases", tweetAliases(tweet)})
+	}
 	if article.CoverMedia != nil && article.CoverMedia.MediaInfo != nil {
-		fields = append(fields, frontmatterField{"cover_image", article.CoverMedia.MediaInfo.OriginalImgURL})
+		cover := article.CoverMedia.MediaInfo
+		fields = append(fields,
+			frontmatterField{"cover_image", cover.OriginalImgURL},
+			frontmatterField{"cover_type", cover.TypeName},
+		)
+		if cover.OriginalImgWidth > 0 && cover.OriginalImgHeight > 0 {
+			fields = append(fields,
+				frontmatterField{"cover_width", cover.OriginalImgWidth},
+				frontmatterField{"cover_height", cover.OriginalImgHeight},
+			)
+		}
+	}
+	if opts.ReadingWPM > 0 {
+		fields = append(fields, frontmatterField{"reading_time", readingMinutes(body, opts.ReadingWPM)})
+	}
+	if !opts.NoStats {
+		fields = append(fields,
+			frontmatterField{"likes", tweet.Likes},
+			frontmatterField{"retweets", tweet.Retweets},
+			frontmatterField{"replies", tweet.Replies},
+			frontmatterField{"views", tweet.Views},
+			frontmatterField{"bookmarks", tweet.Bookmarks},
+		)
 	}
-	fields = append(fields,
-		frontmatterField{"likes", tweet.Likes},
-		frontmatterField{"retweets", tweet.Retweets},
-		frontmatterField{"replies", tweet.Replies},
-		frontmatterField{"views", tweet.Views},
-		frontmatterField{"bookmarks", tweet.Bookmarks},
-	)
-	writeFrontmatter(&sb, fields)
+	writeFrontmatter(sb, frontmatterFields(fields, opts), opts.Frontmatter)
 
 	// Title as H1
-	if article.Title != "" {
-		sb.WriteString("# " + article.Title + "\n\n")
+	if title != "" {
+		sb.WriteString("# " + title + "\n\n")
 	}
 
 	// Cover image
-	if article.CoverMedia != nil && article.CoverMedia.MediaInfo != nil &&
+	if !opts.NoMedia && article.CoverMedia != nil && article.CoverMedia.MediaInfo != nil &&
 		article.CoverMedia.MediaInfo.OriginalImgURL != "" {
-		sb.WriteString(fmt.Sprintf("![cover](%s)\n\n", article.CoverMedia.MediaInfo.OriginalImgURL))
+		cover := article.CoverMedia.MediaInfo.OriginalImgURL
+		if opts.Figure {
+			sb.WriteString(figureHTML(cover, "cover", "") + "\n\n")
+		} else {
+			sb.WriteString(fmt.Sprintf("![cover](%s)\n\n", cover))
+		}
 	}
 
-	// Article content from Draft.js blocks
-	if article.Content != nil {
-		md := DraftJSToMarkdown(article.Content, article.MediaEntities)
-		if md != "" {
-			sb.WriteString(md)
-			sb.WriteString("\n")
-		}
+	// Article content from Draft.js blocks, or the preview text
+	writeBody()
+	if opts.AppendRaw {
+		writeRawJSON(sb, []*Tweet{tweet})
 	}
+}
 
-	return sb.String()
+// articlePartial reports whether article came without its Draft.js content,
+// as in preview-only responses.
+func articlePartial(article *Article) bool {
+	return article.Content == nil || len(article.Content.Blocks) == 0
+}
+
+// articleUnavailable reports whether article has neither content nor preview
+// text, so nothing of its body can be rendered.
+func articleUnavailable(article *Article) bool {
+	return articlePartial(article) && strings.TrimSpace(article.PreviewText) == ""
+}
+
+// unavailableArticleText is written in place of an article body that is
+// unavailable, linking to the article when its URL is known.
+func unavailableArticleText(info URLInfo) string {
+	if info.OriginalURL == "" {
+		return "[article content unavailable]"
+	}
+	return "[article content unavailable](" + info.OriginalURL + ")"
+}
+
+// tweetPermalink returns the canonical x.com URL of a tweet (the post's own
+// URL for other services), or "" when the author is unknown (frontmatter
+// then omits the source).
+func tweetPermalink(tweet *Tweet) string {
+	if tweet.Origin != "" {
+		return tweet.URL
+	}
+	if tweet.Author == nil || tweet.Author.ScreenName == "" {
+		return ""
+	}
+	return fmt.Sprintf("https://x.com/%s/status/%s", tweet.Author.ScreenName, tweet.ID)
 }
 
-func writeTweetFrontmatter(sb *strings.Builder, tweet *Tweet) {
+// tweetAliases returns the status ID and fixupx.com short link of a tweet,
+// for Obsidian/Quartz link resolution.
+func tweetAliases(tweet *Tweet) []string {
+	if tweet.ID == "" {
+		return nil
+	}
+	aliases := []string{tweet.ID}
+	if short := tweetShortURL(tweet); short != "" {
+		aliases = append(aliases, short)
+	}
+	return aliases
+}
+
+// tweetShortURL returns the fixupx.com link of a tweet, which unfurls into a
+// rich preview, or "" when the author or ID is unknown or the post is not
+// from X.
+func tweetShortURL(tweet *Tweet) string {
+	if tweet.Origin != "" || tweet.ID == "" || tweet.Author == nil || tweet.Author.ScreenName == "" {
+		return ""
+	}
+	return fmt.Sprintf("https://fixupx.com/%s/status/%s", tweet.Author.ScreenName, tweet.ID)
+}
+
+func writeTweetFrontmatter(sb io.StringWriter, tweet *Tweet, opts RenderOptions) {
+	typ := "tweet"
+	if tweet.RetweetedStatus != nil {
+		typ = "retweet"
+	}
 	fields := []frontmatterField{
-		{"type", "tweet"},
+		{"type", typ},
+		{"title", opts.Title},
 	}
 	if tweet.Author != nil {
 		fields = append(fields,
@@ -178,56 +759,341 @@ func writeTweetFrontmatter(sb *strings.Builder, tweet *Tweet) {
 			frontmatterField{"author_name", tweet.Author.Name},
 		)
 	}
-	fields = append(fields, frontmatterField{"date", formatDate(tweet.CreatedAt)})
+	if rt := tweet.RetweetedStatus; rt != nil {
+		fields = append(fields, frontmatterField{"retweeted_from", authorHandle(rt)})
+	}
+	fields = append(fields, frontmatterField{"date", tweetDate(tweet)})
+	if edit := tweet.Edit; edit != nil && (edit.Count > 0 || edit.EditedAt != "") {
+		fields = append(fields,
+			frontmatterField{"edited", true},
+			frontmatterField{"edited_at", formatDate(edit.EditedAt)},
+		)
+	}
 	if tweet.Author != nil {
-		fields = append(fields, frontmatterField{"source", fmt.Sprintf("https://x.com/%s/status/%s", tweet.Author.ScreenName, tweet.ID)})
-	}
-	fields = append(fields,
-		frontmatterField{"likes", tweet.Likes},
-		frontmatterField{"retweets", tweet.Retweets},
-		frontmatterField{"replies", tweet.Replies},
-		frontmatterField{"views", tweet.Views},
-		frontmatterField{"bookmarks", tweet.Bookmarks},
-	)
-	if tweet.Lang != "" {
+		fields = append(fields, frontmatterField{"source", tweetPermalink(tweet)})
+	}
+	if opts.ShortURL {
+		fields = append(fields, frontmatterField{"short_url", tweetShortURL(tweet)})
+	}
+	if opts.Aliases {
+		fields = append(fields, frontmatterField{"aliases", tweetAliases(tweet)})
+	}
+	if !opts.NoStats {
+		fields = append(fields,
+			frontmatterField{"likes", tweet.Likes},
+			frontmatterField{"retweets", tweet.Retweets},
+			frontmatterField{"replies", tweet.Replies},
+			frontmatterField{"views", tweet.Views},
+			frontmatterField{"bookmarks", tweet.Bookmarks},
+		)
+	}
+	if hasTranslation(tweet) {
+		lang := tweet.Translation.SourceLang
+		if lang == "" {
+			lang = tweet.Lang
+		}
+		fields = append(fields,
+			frontmatterField{"lang", lang},
+			frontmatterField{"translated_to", tweet.Translation.TargetLang},
+		)
+	} else if tweet.Lang != "" {
 		fields = append(fields, frontmatterField{"lang", tweet.Lang})
 	}
 	if tweet.Source != "" {
 		fields = append(fields, frontmatterField{"via", tweet.Source})
 	}
-	writeFrontmatter(sb, fields)
+	if loc := placeName(tweet.Place); loc != "" {
+		fields = append(fields, frontmatterField{"location", loc})
+	}
+	original := tweet
+	if tweet.RetweetedStatus != nil {
+		original = tweet.RetweetedStatus
+	}
+	fields = append(fields, frontmatterField{"has_community_note", hasCommunityNote(original)})
+	writeFrontmatter(sb, frontmatterFields(fields, opts), opts.Frontmatter)
 }
 
-func writeText(sb *strings.Builder, text string) {
+// maxThreadNumber bounds the numbers in an "N/M" thread marker.
+const maxThreadNumber = 100
+
+// threadMarkerRe matches manual thread numbering at the start of a tweet,
+// e.g. "1/", "2/5", "🧵 1/" or a bare "🧵". The numbering must be followed by
+// whitespace or end the text, and isThreadNumbering checks its numbers.
+var threadMarkerRe = regexp.MustCompile(`^\s*(🧵\s*)?(?:(\d+)\s*/(\d+)?(?:\s+|$))?(🧵\s*)?`)
+
+// stripThreadMarker removes a leading thread numbering marker from the text
+// of the n-th (1-based) tweet of a thread. Text that merely starts like one,
+// such as "12/25 was a great day", is returned unchanged.
+func stripThreadMarker(text string, n int) string {
+	m := threadMarkerRe.FindStringSubmatchIndex(text)
+	if m[4] >= 0 && isThreadNumbering(submatch(text, m, 2), submatch(text, m, 3), n) {
+		return text[m[1]:]
+	}
+	if m[2] >= 0 {
+		// Only the leading 🧵 is a marker.
+		return text[m[3]:]
+	}
+	return text
+}
+
+// isThreadNumbering reports whether "num/total" reads as the numbering of
+// the n-th tweet of a thread rather than a date or a fraction. A bare "num/"
+// only needs a plausible number; with a total, num must also be n and the
+// total at least num, up to maxThreadNumber. A total of "n" counts as bare.
+func isThreadNumbering(num, total string, n int) bool {
+	a, err := strconv.Atoi(num)
+	if err != nil || a < 1 || a > maxThreadNumber {
+		return false
+	}
+	if total == "" || total == "n" {
+		return true
+	}
+	b, err := strconv.Atoi(total)
+	return err == nil && a == n && b >= a && b <= maxThreadNumber
+}
+
+// stripLeadingMention removes a leading "@screenName" mention from text.
+// Other handles, including ones that merely start with screenName, are kept.
+func stripLeadingMention(text, screenName string) string {
+	mention := "@" + screenName
+	if len(text) < len(mention) || !strings.EqualFold(text[:len(mention)], mention) {
+		return text
+	}
+	rest := text[len(mention):]
+	if rest != "" && !unicode.IsSpace(rune(rest[0])) {
+		return text
+	}
+	return strings.TrimLeft(rest, " \t\n")
+}
+
+// trailingThreadMarkerRe matches numbering at the end of a tweet,
+// e.g. "1/", "2/5", "(3/n)" or "4/ 🧵", set off by whitespace.
+var trailingThreadMarkerRe = regexp.MustCompile(`(?:^|\s+)[(\[]?(\d+)\s*/(\d+|n)?[)\]]?\s*(?:🧵\s*)?$`)
+
+// stripTrailingThreadMarker removes a trailing thread numbering marker from
+// the text of the n-th (1-based) tweet of a thread, checked as in
+// stripThreadMarker.
+func stripTrailingThreadMarker(text string, n int) string {
+	m := trailingThreadMarkerRe.FindStringSubmatchIndex(text)
+	if m == nil || !isThreadNumbering(text[m[2]:m[3]], submatch(text, m, 2), n) {
+		return text
+	}
+	return text[:m[0]]
+}
+
+// submatch returns the i-th submatch of text given FindStringSubmatchIndex
+// output, or "" when that group did not participate.
+func submatch(text string, m []int, i int) string {
+	if m[2*i] < 0 {
+		return ""
+	}
+	return text[m[2*i]:m[2*i+1]]
+}
+
+func writeText(sb io.StringWriter, text, origin string, opts RenderOptions) {
 	if text == "" {
 		return
 	}
+	if opts.Normalize {
+		text = normalizeText(text)
+	}
+	text = cleanLinks(text, opts.CleanParams)
+	text = applyCallouts(text, opts.Callouts)
+	if opts.LinkEntities {
+		text = linkEntities(text, origin)
+	}
+	if opts.Autolink {
+		text = autolinkURLs(text)
+	}
+	text = applyParagraphs(text, opts.Paragraphs)
 	sb.WriteString(text + "\n")
 }
 
-func writeMedia(sb *strings.Builder, media *Media) {
-	if media == nil {
+// hasCommunityNote reports whether the tweet carries a non-empty Community Note.
+func hasCommunityNote(tweet *Tweet) bool {
+	return tweet.CommunityNote != nil && strings.TrimSpace(tweet.CommunityNote.Text) != ""
+}
+
+// writeCommunityNote renders a Community Note as a NOTE callout.
+func writeCommunityNote(sb io.StringWriter, note *CommunityNote) {
+	if note == nil || strings.TrimSpace(note.Text) == "" {
 		return
 	}
+	sb.WriteString("\n> [!NOTE] Community Note\n")
+	for _, line := range strings.Split(strings.TrimSpace(note.Text), "\n") {
+		sb.WriteString(strings.TrimRight("> "+line, " ") + "\n")
+	}
+}
 
-	for _, photo := range media.Photos {
-		alt := photo.AltText
-		if alt == "" {
-			alt = "image"
+// placeName returns a display name for a place, or "" if there is none.
+func placeName(place *Place) string {
+	if place == nil {
+		return ""
+	}
+	name := place.FullName
+	if name == "" {
+		name = place.Name
+	}
+	if place.Country != "" && name != "" && !strings.Contains(name, place.Country) {
+		name += ", " + place.Country
+	}
+	return name
+}
+
+// writePlace writes the tweet's location as a map search link.
+func writePlace(sb io.StringWriter, place *Place) {
+	name := placeName(place)
+	if name == "" {
+		return
+	}
+	mapURL := "https://www.openstreetmap.org/search?query=" + url.QueryEscape(name)
+	sb.WriteString(fmt.Sprintf("\n📍 [%s](%s)\n", name, mapURL))
+}
+
+// hasTranslation reports whether the tweet carries a usable translation.
+func hasTranslation(tweet *Tweet) bool {
+	return tweet.Translation != nil && tweet.Translation.Text != ""
+}
+
+// writeOriginalText writes the untranslated tweet text as a blockquote.
+func writeOriginalText(sb io.StringWriter, text string) {
+	if text == "" {
+		return
+	}
+	sb.WriteString("\n")
+	for _, line := range strings.Split(text, "\n") {
+		sb.WriteString("> " + line + "\n")
+	}
+}
+
+// invisibleReplacer removes zero-width characters and turns non-breaking
+// spaces into regular spaces. ZWJ/ZWNJ (emoji sequences, Persian and Indic
+// scripts) and bidi marks (RTL text) are meaningful and left alone.
+var invisibleReplacer = strings.NewReplacer(
+	"\u200B", "", // zero width space
+	"\u2060", "", // word joiner
+	"\uFEFF", "", // zero width no-break space / BOM
+	"\u00AD", "", // soft hyphen
+	"\u00A0", " ", // no-break space
+	"\u202F", " ", // narrow no-break space
+	"\u2007", " ", // figure space
+)
+
+// normalizeText strips invisible characters that break Markdown tooling and diffs.
+func normalizeText(text string) string {
+	return invisibleReplacer.Replace(text)
+}
+
+// autolinkRe matches existing Markdown links/images and autolinks, which are
+// kept as is, or a bare URL (last group).
+var autolinkRe = regexp.MustCompile(`!?\[[^\]]*\]\([^)]*\)|<https?://[^>\s]+>|(https?://[^\s<>]+)`)
+
+// autolinkURLs wraps bare http(s) URLs in <...> so GFM renders them as links.
+// Trailing punctuation is left outside the link.
+func autolinkURLs(text string) string {
+	return autolinkRe.ReplaceAllStringFunc(text, func(m string) string {
+		if !strings.HasPrefix(m, "http") {
+			return m
+		}
+		url := strings.TrimRight(m, ".,;:!?)\"'")
+		return "<" + url + ">" + m[len(url):]
+	})
+}
+
+// seen, when non-nil, holds media URLs already rendered; repeats are replaced
+// by a short note and new URLs are added to it.
+func writeMedia(sb io.StringWriter, tweet *Tweet, opts RenderOptions, seen map[string]bool) {
+	media := tweet.Media
+	if media == nil || opts.NoMedia {
+		return
+	}
+
+	// Hide sensitive media behind a collapsed block so previews don't show it.
+	if opts.MarkSensitive && tweet.PossiblySensitive {
+		var inner strings.Builder
+		writeMediaItems(&inner, media, opts.Figure, seen)
+		sb.WriteString("\n<details>\n<summary>⚠️ 敏感内容</summary>\n")
+		sb.WriteString(inner.String())
+		sb.WriteString("\n</details>\n")
+		return
+	}
+	writeMediaItems(sb, media, opts.Figure, seen)
+}
+
+// writeMediaItems renders media in the order the tweet shows it (Media.All),
+// falling back to photos then videos when All is absent.
+func writeMediaItems(sb io.StringWriter, media *Media, figure bool, seen map[string]bool) {
+	repeated := func(url string) bool {
+		if seen == nil || url == "" {
+			return false
 		}
-		sb.WriteString(fmt.Sprintf("\n![%s](%s)\n", alt, photo.URL))
+		if seen[url] {
+			sb.WriteString("\n*（重复媒体已省略）*\n")
+			return true
+		}
+		seen[url] = true
+		return false
 	}
 
+	if len(media.All) > 0 {
+		for _, item := range media.All {
+			if repeated(item.URL) {
+				continue
+			}
+			if item.Type == "photo" {
+				writePhoto(sb, item.URL, item.AltText, figure)
+			} else {
+				writeVideo(sb, item.URL, item.ThumbnailURL)
+			}
+		}
+		return
+	}
+
+	for _, photo := range media.Photos {
+		if !repeated(photo.URL) {
+			writePhoto(sb, photo.URL, photo.AltText, figure)
+		}
+	}
 	for _, video := range media.Videos {
-		if video.URL != "" {
-			sb.WriteString(fmt.Sprintf("\n[▶ Video](%s)\n", video.URL))
-		} else if video.ThumbnailURL != "" {
-			sb.WriteString(fmt.Sprintf("\n![video thumbnail](%s)\n", video.ThumbnailURL))
+		if !repeated(video.URL) {
+			writeVideo(sb, video.URL, video.ThumbnailURL)
 		}
 	}
 }
 
-func writePoll(sb *strings.Builder, poll *Poll) {
+func writePhoto(sb io.StringWriter, url, alt string, figure bool) {
+	if figure {
+		sb.WriteString("\n" + figureHTML(url, alt, singleLine(alt)) + "\n")
+		return
+	}
+	if alt == "" {
+		alt = "image"
+	}
+	sb.WriteString(fmt.Sprintf("\n![%s](%s)\n", alt, url))
+}
+
+// figureHTML renders an image as an HTML figure, with a figcaption unless
+// caption is empty.
+func figureHTML(url, alt, caption string) string {
+	var sb strings.Builder
+	sb.WriteString("<figure>\n")
+	sb.WriteString(fmt.Sprintf(`<img src="%s" alt="%s">`, html.EscapeString(url), html.EscapeString(alt)) + "\n")
+	if caption != "" {
+		sb.WriteString("<figcaption>" + html.EscapeString(caption) + "</figcaption>\n")
+	}
+	sb.WriteString("</figure>")
+	return sb.String()
+}
+
+func writeVideo(sb io.StringWriter, url, thumbnailURL string) {
+	if url != "" {
+		sb.WriteString(fmt.Sprintf("\n[▶ Video](%s)\n", url))
+	} else if thumbnailURL != "" {
+		sb.WriteString(fmt.Sprintf("\n![video thumbnail](%s)\n", thumbnailURL))
+	}
+}
+
+func writePoll(sb io.StringWriter, poll *Poll, opts RenderOptions) {
 	if poll == nil {
 		return
 	}
@@ -238,6 +1104,11 @@ func writePoll(sb *strings.Builder, poll *Poll) {
 	}
 	sb.WriteString("\n\n")
 
+	if opts.PollStyle == PollStyleTable {
+		writePollTable(sb, poll)
+		sb.WriteString(fmt.Sprintf("\n共 %d 票\n", poll.TotalVotes))
+		return
+	}
 	for _, choice := range poll.Choices {
 		bar := renderPollBar(choice.Percentage)
 		sb.WriteString(fmt.Sprintf("- %s %s (%.1f%%)\n", choice.Label, bar, choice.Percentage))
@@ -245,6 +1116,32 @@ func writePoll(sb *strings.Builder, poll *Poll) {
 	sb.WriteString(fmt.Sprintf("\n共 %d 票\n", poll.TotalVotes))
 }
 
+// writePollTable renders poll choices as a GFM table, bolding the leading
+// choice (every choice tied for the lead).
+func writePollTable(sb io.StringWriter, poll *Poll) {
+	lead := 0
+	for _, choice := range poll.Choices {
+		if choice.Count > lead {
+			lead = choice.Count
+		}
+	}
+	sb.WriteString("| Option | Votes | Percentage |\n")
+	sb.WriteString("| --- | ---: | ---: |\n")
+	for _, choice := range poll.Choices {
+		cells := []string{
+			strings.ReplaceAll(choice.Label, "|", `\|`),
+			strconv.Itoa(choice.Count),
+			fmt.Sprintf("%.1f%%", choice.Percentage),
+		}
+		if lead > 0 && choice.Count == lead {
+			for i, c := range cells {
+				cells[i] = "**" + c + "**"
+			}
+		}
+		sb.WriteString("| " + strings.Join(cells, " | ") + " |\n")
+	}
+}
+
 func renderPollBar(percentage float64) string {
 	filled := int(percentage / 5)
 	if filled > 20 {
@@ -253,10 +1150,27 @@ func renderPollBar(percentage float64) string {
 	return strings.Repeat("█", filled) + strings.Repeat("░", 20-filled)
 }
 
-func writeQuote(sb *strings.Builder, quote *Tweet) {
+func writeQuote(sb io.StringWriter, quote *Tweet, opts RenderOptions) {
 	if quote == nil {
 		return
 	}
+	if isTombstone(quote) {
+		if opts.FlattenQuote > 0 {
+			sb.WriteString("\n([quoted tweet unavailable])\n")
+		} else {
+			sb.WriteString("\n> [quoted tweet unavailable]\n")
+		}
+		return
+	}
+	if quote.Article != nil {
+		writeQuotedArticle(sb, quote, opts)
+		return
+	}
+	if opts.FlattenQuote > 0 {
+		text := truncateRunes(singleLine(quote.Text), opts.FlattenQuote)
+		sb.WriteString(fmt.Sprintf("\n(quoting %s: \"%s\")\n", authorHandle(quote), text))
+		return
+	}
 
 	sb.WriteString("\n")
 	lines := strings.Split(quote.Text, "\n")
@@ -264,15 +1178,169 @@ func writeQuote(sb *strings.Builder, quote *Tweet) {
 		sb.WriteString("> " + line + "\n")
 	}
 
-	if quote.Author != nil {
-		sb.WriteString(fmt.Sprintf("> — @%s\n", quote.Author.ScreenName))
+	sb.WriteString("> — " + authorHandle(quote) + "\n")
+}
+
+// writeQuotedArticle renders a quoted X Article as a condensed reference: its
+// linked title and a short preview in place of the quote's text, which is
+// usually just the article link.
+func writeQuotedArticle(sb io.StringWriter, quote *Tweet, opts RenderOptions) {
+	article := quote.Article
+	title := article.Title
+	if title == "" {
+		title = "Article"
+	}
+	link := title
+	if url := quotedArticleURL(quote); url != "" {
+		link = fmt.Sprintf("[%s](%s)", title, url)
 	}
+	if opts.FlattenQuote > 0 {
+		sb.WriteString(fmt.Sprintf("\n(quoting %s: %s)\n", authorHandle(quote), link))
+		return
+	}
+
+	sb.WriteString("\n> **" + link + "**\n")
+	if preview := articleDescription(article.PreviewText, opts.DescriptionLength); preview != "" {
+		sb.WriteString(">\n> " + preview + "\n")
+	}
+	sb.WriteString("> — " + authorHandle(quote) + "\n")
 }
 
+// quotedArticleURL returns the x.com URL of a quoted article, falling back to
+// the quoting tweet's permalink when the article ID is missing.
+func quotedArticleURL(quote *Tweet) string {
+	if quote.Article.ID != "" && quote.Author != nil && quote.Author.ScreenName != "" {
+		return normalizeOriginalURL(quote.Author.ScreenName, "article", quote.Article.ID)
+	}
+	return tweetPermalink(quote)
+}
+
+// isTombstone reports whether a tweet is the empty stand-in FxTwitter returns
+// for a deleted or otherwise unavailable tweet: no text and no author.
+func isTombstone(tweet *Tweet) bool {
+	return strings.TrimSpace(tweet.Text) == "" && (tweet.Author == nil || tweet.Author.ScreenName == "")
+}
+
+// truncateRunes shortens s to at most n characters, marking a cut with an
+// ellipsis.
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return strings.TrimSpace(string(runes[:n-1])) + "…"
+}
+
+// writeParent writes the tweet being replied to as a context blockquote
+// ahead of the reply.
+func writeParent(sb io.StringWriter, parent *Tweet) {
+	if parent == nil {
+		return
+	}
+	for _, line := range strings.Split(parent.Text, "\n") {
+		sb.WriteString("> " + line + "\n")
+	}
+	sb.WriteString("> — " + authorHandle(parent))
+	if link := tweetPermalink(parent); link != "" {
+		sb.WriteString(fmt.Sprintf(" [🔗](%s)", link))
+	}
+	sb.WriteString("\n\n")
+}
+
+// authorHandle returns "@screen_name" for a tweet's author, or "unknown".
+func authorHandle(tweet *Tweet) string {
+	if tweet.Author == nil || tweet.Author.ScreenName == "" {
+		return "unknown"
+	}
+	return "@" + tweet.Author.ScreenName
+}
+
+// writeQuoteThread renders an expanded quote thread as a collapsible section
+// nested inside the quote blockquote, or after the quote's parenthetical when
+// it is flattened.
+func writeQuoteThread(sb io.StringWriter, thread []*Tweet, opts RenderOptions) {
+	if len(thread) == 0 {
+		return
+	}
+
+	var inner strings.Builder
+	for i, tweet := range thread {
+		if i > 0 {
+			inner.WriteString("\n---\n\n")
+		}
+		writeText(&inner, tweet.Text, tweet.Origin, opts)
+		writeMedia(&inner, tweet, opts, nil)
+	}
+
+	summary := fmt.Sprintf("<summary>引用推文之前的线程（%d 条）</summary>", len(thread))
+	if opts.FlattenQuote > 0 {
+		sb.WriteString("\n<details>\n" + summary + "\n\n")
+		sb.WriteString(strings.TrimRight(inner.String(), "\n") + "\n\n</details>\n")
+		return
+	}
+
+	sb.WriteString(">\n")
+	sb.WriteString("> <details>\n")
+	sb.WriteString("> " + summary + "\n>\n")
+	for _, line := range strings.Split(strings.TrimRight(inner.String(), "\n"), "\n") {
+		if line == "" {
+			sb.WriteString(">\n")
+		} else {
+			sb.WriteString("> " + line + "\n")
+		}
+	}
+	sb.WriteString(">\n> </details>\n")
+}
+
+// writeRawJSON appends the pretty-printed API responses of tweets in a
+// collapsed block. A thread's responses are emitted as a JSON array.
+func writeRawJSON(sb io.StringWriter, tweets []*Tweet) {
+	var raws []json.RawMessage
+	for _, tweet := range tweets {
+		if len(tweet.Raw) > 0 {
+			raws = append(raws, tweet.Raw)
+		}
+	}
+	if len(raws) == 0 {
+		return
+	}
+
+	var data []byte
+	var err error
+	if len(raws) == 1 {
+		data, err = json.Marshal(raws[0])
+	} else {
+		data, err = json.Marshal(raws)
+	}
+	if err != nil {
+		return
+	}
+	var pretty bytes.Buffer
+	if err := json.Indent(&pretty, data, "", "  "); err != nil {
+		return
+	}
+
+	sb.WriteString("\n<details>\n<summary>原始 JSON</summary>\n\n```json\n")
+	sb.WriteString(pretty.String())
+	sb.WriteString("\n```\n\n</details>\n")
+}
+
+// dateLayout is the UTC form dates are rendered in.
+const dateLayout = "2006-01-02T15:04:05Z"
+
 // formatDate formats a date string to a more readable format.
 func formatDate(dateStr string) string {
+	t, ok := parseDate(dateStr)
+	if !ok {
+		return dateStr
+	}
+	return t.UTC().Format(dateLayout)
+}
+
+// parseDate parses the date formats the API uses.
+func parseDate(dateStr string) (time.Time, bool) {
 	if dateStr == "" {
-		return ""
+		return time.Time{}, false
 	}
 
 	// Try parsing Twitter's date format: "Wed Jan 15 12:30:00 +0000 2024"
@@ -284,9 +1352,31 @@ func formatDate(dateStr string) string {
 			// Try ISO 8601
 			t, err = time.Parse(time.RFC3339, dateStr)
 			if err != nil {
-				return dateStr
+				return time.Time{}, false
 			}
 		}
 	}
-	return t.UTC().Format("2006-01-02T15:04:05Z")
+	return t, true
+}
+
+// tweetTime returns when tweet was posted, from created_at or, when that
+// cannot be parsed, from created_timestamp.
+func tweetTime(tweet *Tweet) (time.Time, bool) {
+	if t, ok := parseDate(tweet.CreatedAt); ok {
+		return t, true
+	}
+	if tweet.CreatedTimestamp > 0 {
+		return time.Unix(tweet.CreatedTimestamp, 0), true
+	}
+	return time.Time{}, false
+}
+
+// tweetDate formats the tweet's date like formatDate, falling back to
+// created_timestamp and then to the raw created_at string.
+func tweetDate(tweet *Tweet) string {
+	t, ok := tweetTime(tweet)
+	if !ok {
+		return tweet.CreatedAt
+	}
+	return t.UTC().Format(dateLayout)
 }
@@ -0,0 +1,1246 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// testThread builds a self-thread by @alice with one tweet per text.
+func testThread(texts ...string) []*Tweet {
+	author := &Author{Name: "Alice", ScreenName: "alice"}
+	tweets := make([]*Tweet, len(texts))
+	for i, text := range texts {
+		id := string(rune('1' + i))
+		tweets[i] = &Tweet{
+			ID:        id,
+			URL:       "https://x.com/alice/status/" + id,
+			Text:      text,
+			CreatedAt: "Wed Jan 15 12:30:00 +0000 2024",
+			Author:    author,
+		}
+	}
+	return tweets
+}
+
+// docBody returns doc without its leading frontmatter block.
+func docBody(doc string) string {
+	if !strings.HasPrefix(doc, "---\n") {
+		return doc
+	}
+	_, body, _ := strings.Cut(doc[len("---\n"):], "\n---\n")
+	return body
+}
+
+func TestStripThreadMarker(t *testing.T) {
+	tests := []struct {
+		text string
+		n    int
+		want string
+	}{
+		{"1/ Hello", 1, "Hello"},
+		{"2/ Next", 2, "Next"},
+		{"2/5 Next", 2, "Next"},
+		{"🧵 1/ Start", 1, "Start"},
+		{"1/ 🧵 Start", 1, "Start"},
+		{"🧵 Start", 1, "Start"},
+		{"4/", 4, ""},
+		{"1/\nLine", 1, "Line"},
+		// Dates and fractions are content, not numbering.
+		{"12/25 was a great day", 1, "12/25 was a great day"},
+		{"3/4 of people agree", 1, "3/4 of people agree"},
+		{"🧵 3/4 of people agree", 1, "3/4 of people agree"},
+		{"5/3 reversed", 5, "5/3 reversed"},
+		{"1/500 too many", 1, "1/500 too many"},
+		{"1/2cups", 1, "1/2cups"},
+		{"10/10 would read again", 1, "10/10 would read again"},
+		{"No marker here", 1, "No marker here"},
+	}
+	for _, tt := range tests {
+		if got := stripThreadMarker(tt.text, tt.n); got != tt.want {
+			t.Errorf("stripThreadMarker(%q, %d) = %q, want %q", tt.text, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestStripTrailingThreadMarker(t *testing.T) {
+	tests := []struct {
+		text string
+		n    int
+		want string
+	}{
+		{"Hello 1/", 1, "Hello"},
+		{"Next 2/5", 2, "Next"},
+		{"More (3/n)", 3, "More"},
+		{"End 4/ 🧵", 4, "End"},
+		{"See you 12/25", 1, "See you 12/25"},
+		{"Mix 3/4", 1, "Mix 3/4"},
+		{"https://example.com/a/12/", 1, "https://example.com/a/12/"},
+	}
+	for _, tt := range tests {
+		if got := stripTrailingThreadMarker(tt.text, tt.n); got != tt.want {
+			t.Errorf("stripTrailingThreadMarker(%q, %d) = %q, want %q", tt.text, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestRenderThreadRenumber(t *testing.T) {
+	tweets := testThread("1/ First point", "2/ Second point")
+
+	got := RenderThread(tweets, RenderOptions{Renumber: true})
+	for _, want := range []string{"## 1.\n\nFirst point\n", "## 2.\n\nSecond point\n"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("renumbered output missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "1/") || strings.Contains(got, "2/ ") {
+		t.Errorf("renumbered output kept manual numbering:\n%s", got)
+	}
+
+	got = RenderThread(tweets, RenderOptions{})
+	for _, want := range []string{"1/ First point", "2/ Second point"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output without -renumber missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "## 1.") {
+		t.Errorf("output without -renumber has section headers:\n%s", got)
+	}
+}
+
+func TestRenderThreadStyles(t *testing.T) {
+	tweets := testThread("1/ One", "2/ Two", "3/ Three")
+
+	separated := docBody(RenderThread(tweets, RenderOptions{}))
+	if n := strings.Count(separated, "\n---\n\n"); n != 2 {
+		t.Errorf("separated style has %d rules between tweets, want 2:\n%s", n, separated)
+	}
+
+	continuous := docBody(RenderThread(tweets, RenderOptions{ThreadStyle: ThreadStyleContinuous}))
+	if strings.Contains(continuous, "---") {
+		t.Errorf("continuous style has rules between tweets:\n%s", continuous)
+	}
+	if !strings.Contains(continuous, "1/ One\n\n2/ Two\n\n3/ Three\n") {
+		t.Errorf("continuous style does not join tweets as paragraphs:\n%s", continuous)
+	}
+}
+
+func TestRenderThreadPermalinks(t *testing.T) {
+	tweets := testThread("One", "Two", "Three")
+	body := docBody(RenderThread(tweets, RenderOptions{ThreadPermalinks: true}))
+
+	sections := strings.Split(body, "\n---\n")
+	if len(sections) != len(tweets) {
+		t.Fatalf("got %d sections, want %d:\n%s", len(sections), len(tweets), body)
+	}
+	for i, section := range sections {
+		want := "[🔗](https://x.com/alice/status/" + tweets[i].ID + ")"
+		if !strings.Contains(section, want) {
+			t.Errorf("section %d missing %s:\n%s", i+1, want, section)
+		}
+		if strings.Count(section, "[🔗]") != 1 {
+			t.Errorf("section %d has %d permalinks, want 1:\n%s", i+1, strings.Count(section, "[🔗]"), section)
+		}
+	}
+
+	if body := RenderThread(tweets, RenderOptions{}); strings.Contains(body, "[🔗]") {
+		t.Errorf("permalinks without -thread-permalinks:\n%s", body)
+	}
+}
+
+func TestRenderNoStats(t *testing.T) {
+	tweet := testThread("Hello")[0]
+	tweet.Likes, tweet.Retweets, tweet.Replies, tweet.Views, tweet.Bookmarks = 1, 2, 3, 4, 5
+	article := *tweet
+	article.Article = &Article{Title: "Title", PreviewText: "Preview"}
+	info := URLInfo{OriginalURL: tweet.URL}
+
+	stats := []string{"likes:", "retweets:", "replies:", "views:", "bookmarks:"}
+	render := map[string]func(RenderOptions) string{
+		"tweet":   func(opts RenderOptions) string { return RenderTweet(tweet, opts) },
+		"thread":  func(opts RenderOptions) string { return RenderThread([]*Tweet{tweet, tweet}, opts) },
+		"article": func(opts RenderOptions) string { return RenderArticle(&article, info, opts) },
+	}
+	for name, fn := range render {
+		if got := fn(RenderOptions{}); !strings.Contains(got, "likes: 1\n") {
+			t.Errorf("%s: frontmatter missing likes:\n%s", name, got)
+		}
+		got := fn(RenderOptions{NoStats: true})
+		for _, key := range stats {
+			if strings.Contains(got, key) {
+				t.Errorf("%s: -no-stats output has %s:\n%s", name, key, got)
+			}
+		}
+		if !strings.Contains(got, "author: \"@alice\"\n") {
+			t.Errorf("%s: -no-stats dropped other frontmatter:\n%s", name, got)
+		}
+	}
+}
+
+func TestAutolinkURLs(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"See https://example.com/a now", "See <https://example.com/a> now"},
+		{"Ends with https://example.com.", "Ends with <https://example.com>."},
+		{"[docs](https://example.com/docs)", "[docs](https://example.com/docs)"},
+		{"![img](https://example.com/i.png)", "![img](https://example.com/i.png)"},
+		{"Already <https://example.com>", "Already <https://example.com>"},
+		{
+			"Bare https://a.example and [linked](https://b.example)",
+			"Bare <https://a.example> and [linked](https://b.example)",
+		},
+	}
+	for _, tt := range tests {
+		if got := autolinkURLs(tt.text); got != tt.want {
+			t.Errorf("autolinkURLs(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+
+	tweet := testThread("Bare https://a.example and [linked](https://b.example)")[0]
+	if got := RenderTweet(tweet, RenderOptions{Autolink: true}); !strings.Contains(got, "Bare <https://a.example> and [linked](https://b.example)\n") {
+		t.Errorf("-autolink output:\n%s", got)
+	}
+}
+
+// testArticle returns a tweet by @alice carrying an article with the given blocks.
+func testArticle(blocks ...Block) (*Tweet, URLInfo) {
+	tweet := testThread("")[0]
+	tweet.Article = &Article{Title: "Title", PreviewText: "Preview text"}
+	if len(blocks) > 0 {
+		tweet.Article.Content = &ArticleContent{Blocks: blocks}
+	}
+	return tweet, URLInfo{Type: URLTypeArticle, OriginalURL: tweet.URL}
+}
+
+func TestRenderArticleCoverDimensions(t *testing.T) {
+	tweet, info := testArticle(Block{Type: "unstyled", Text: "Body"})
+	tweet.Article.CoverMedia = &ArticleMedia{MediaInfo: &MediaInfo{
+		TypeName:          "ApiImage",
+		OriginalImgURL:    "https://pbs.twimg.com/media/cover.jpg",
+		OriginalImgWidth:  1200,
+		OriginalImgHeight: 630,
+	}}
+
+	got := RenderArticle(tweet, info, RenderOptions{})
+	for _, want := range []string{
+		"cover_image: \"https://pbs.twimg.com/media/cover.jpg\"\n",
+		"cover_type: ApiImage\n",
+		"cover_width: 1200\n",
+		"cover_height: 630\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("frontmatter missing %q:\n%s", want, got)
+		}
+	}
+
+	tweet.Article.CoverMedia.MediaInfo.OriginalImgWidth = 0
+	if got := RenderArticle(tweet, info, RenderOptions{}); strings.Contains(got, "cover_width") || strings.Contains(got, "cover_height") {
+		t.Errorf("dimensions written without a width:\n%s", got)
+	}
+}
+
+func TestRenderArticleEmptyBlocks(t *testing.T) {
+	para := func(text string) Block { return Block{Type: "unstyled", Text: text} }
+	tweet, info := testArticle(para("A"), para(""), para(""), para("B"))
+	got := docBody(RenderArticle(tweet, info, RenderOptions{BodyOnly: true}))
+	if !strings.Contains(got, "A\n\nB\n") || strings.Contains(got, "A\n\n\n") {
+		t.Errorf("want exactly one blank line between A and B:\n%q", got)
+	}
+}
+
+func TestRenderPartialArticle(t *testing.T) {
+	tweet, info := testArticle()
+	got := RenderArticle(tweet, info, RenderOptions{})
+	for _, want := range []string{"partial: true\n", "# Title\n", "\nPreview text\n"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("partial article missing %q:\n%s", want, got)
+		}
+	}
+
+	tweet, info = testArticle(Block{Type: "unstyled", Text: "Full body"})
+	got = RenderArticle(tweet, info, RenderOptions{})
+	if strings.Contains(got, "partial:") || !strings.Contains(got, "\nFull body\n") {
+		t.Errorf("full article:\n%s", got)
+	}
+}
+
+func TestRenderThreadEmbeddedArticle(t *testing.T) {
+	tweets := testThread("Intro", "https://x.com/i/article/99", "Outro")
+	tweets[1].Article = &Article{
+		Title: "Deep Dive",
+		Content: &ArticleContent{Blocks: []Block{
+			{Type: "header-two", Text: "Part one"},
+			{Type: "unstyled", Text: "Article body", InlineStyleRanges: []InlineStyleRange{{Offset: 8, Length: 4, Style: "Bold"}}},
+		}},
+	}
+
+	got := docBody(RenderThread(tweets, RenderOptions{}))
+	sections := strings.Split(got, "\n---\n")
+	if len(sections) != 3 {
+		t.Fatalf("got %d sections, want 3:\n%s", len(sections), got)
+	}
+	for _, want := range []string{"## Deep Dive\n", "## Part one\n", "Article **body**\n"} {
+		if !strings.Contains(sections[1], want) {
+			t.Errorf("second tweet missing %q:\n%s", want, sections[1])
+		}
+	}
+	if strings.Contains(got, "https://x.com/i/article/99") {
+		t.Errorf("article tweet rendered its link text instead of the article:\n%s", got)
+	}
+	if !strings.Contains(sections[0], "Intro") || !strings.Contains(sections[2], "Outro") {
+		t.Errorf("surrounding tweets lost:\n%s", got)
+	}
+}
+
+func TestRenderSensitiveMedia(t *testing.T) {
+	tweet := testThread("Spoiler")[0]
+	tweet.PossiblySensitive = true
+	tweet.Media = &Media{Photos: []Photo{{URL: "https://pbs.twimg.com/media/a.jpg"}}}
+
+	got := RenderTweet(tweet, RenderOptions{MarkSensitive: true})
+	want := "<details>\n<summary>⚠️ 敏感内容</summary>\n\n![image](https://pbs.twimg.com/media/a.jpg)\n\n</details>\n"
+	if !strings.Contains(got, want) {
+		t.Errorf("sensitive photo not collapsed, want %q in:\n%s", want, got)
+	}
+
+	if got := RenderTweet(tweet, RenderOptions{}); strings.Contains(got, "<details>") {
+		t.Errorf("photo collapsed without -mark-sensitive:\n%s", got)
+	}
+	tweet.PossiblySensitive = false
+	if got := RenderTweet(tweet, RenderOptions{MarkSensitive: true}); strings.Contains(got, "<details>") {
+		t.Errorf("photo not marked sensitive was collapsed:\n%s", got)
+	}
+}
+
+// rawAppendix returns the contents of the ```json appendix in doc.
+func rawAppendix(t *testing.T, doc string) string {
+	t.Helper()
+	_, rest, ok := strings.Cut(doc, "<summary>原始 JSON</summary>\n\n```json\n")
+	if !ok {
+		t.Fatalf("no raw JSON appendix:\n%s", doc)
+	}
+	raw, _, ok := strings.Cut(rest, "\n```\n")
+	if !ok {
+		t.Fatalf("unterminated raw JSON appendix:\n%s", doc)
+	}
+	return raw
+}
+
+func TestRenderAppendRaw(t *testing.T) {
+	tweet, err := LoadTweetFile(filepath.Join("testdata", "tweet.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var resp APIResponse
+	if err := json.Unmarshal([]byte(rawAppendix(t, RenderTweet(tweet, RenderOptions{AppendRaw: true}))), &resp); err != nil {
+		t.Fatalf("appendix is not valid JSON: %v", err)
+	}
+	if resp.Tweet == nil || resp.Tweet.ID != tweet.ID {
+		t.Errorf("appendix does not hold the source response: %+v", resp)
+	}
+
+	var thread []APIResponse
+	if err := json.Unmarshal([]byte(rawAppendix(t, RenderThread([]*Tweet{tweet, tweet}, RenderOptions{AppendRaw: true}))), &thread); err != nil {
+		t.Fatalf("thread appendix is not a valid JSON array: %v", err)
+	}
+	if len(thread) != 2 {
+		t.Errorf("thread appendix has %d responses, want 2", len(thread))
+	}
+
+	if got := RenderTweet(tweet, RenderOptions{}); strings.Contains(got, "```json") {
+		t.Errorf("appendix written without -append-raw:\n%s", got)
+	}
+}
+
+func TestNormalizeText(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"ZWSP and NBSP", "zero\u200Bwidth\u00A0space", "zerowidth space"},
+		{"BOM and soft hyphen", "\uFEFFhy\u00ADphen", "hyphen"},
+		{"ZWJ emoji sequence kept", "family \U0001F468\u200D\U0001F469\u200D\U0001F467", "family \U0001F468\u200D\U0001F469\u200D\U0001F467"},
+		{"RTL marks kept", "\u200Fمرحبا\u200E", "\u200Fمرحبا\u200E"},
+	}
+	for _, tt := range tests {
+		if got := normalizeText(tt.text); got != tt.want {
+			t.Errorf("%s: normalizeText(%q) = %q, want %q", tt.name, tt.text, got, tt.want)
+		}
+	}
+
+	tweet := testThread("a\u200Bb\u00A0c")[0]
+	if got := RenderTweet(tweet, RenderOptions{Normalize: true}); !strings.Contains(got, "\nab c\n") {
+		t.Errorf("-normalize output:\n%s", got)
+	}
+	if got := RenderTweet(tweet, RenderOptions{}); !strings.Contains(got, "a\u200Bb\u00A0c") {
+		t.Errorf("text normalized without -normalize:\n%s", got)
+	}
+}
+
+func TestWriteFrontmatterLists(t *testing.T) {
+	var sb strings.Builder
+	writeFrontmatter(&sb, []frontmatterField{
+		{"aliases", []string{"1746000000000000001", "https://fixupx.com/alice/status/1746000000000000001"}},
+		{"widths", []int{640, 1280}},
+		{"tags", []string{}},
+	}, FrontmatterBlock)
+	want := "---\naliases:\n  - \"1746000000000000001\"\n  - \"https://fixupx.com/alice/status/1746000000000000001\"\nwidths:\n  - 640\n  - 1280\n---\n\n"
+	if got := sb.String(); got != want {
+		t.Errorf("block lists =\n%s\nwant\n%s", got, want)
+	}
+
+	sb.Reset()
+	writeFrontmatter(&sb, []frontmatterField{{"aliases", []string{"1", "a b"}}}, FrontmatterCompact)
+	if got, want := sb.String(), "---\n{aliases: [\"1\", a b]}\n---\n\n"; got != want {
+		t.Errorf("compact list = %q, want %q", got, want)
+	}
+}
+
+func TestRenderAliases(t *testing.T) {
+	tweet := testThread("Hello")[0]
+	got := RenderTweet(tweet, RenderOptions{Aliases: true})
+	if want := "aliases:\n  - \"1\"\n  - \"https://fixupx.com/alice/status/1\"\n"; !strings.Contains(got, want) {
+		t.Errorf("frontmatter missing %q:\n%s", want, got)
+	}
+	if got := RenderTweet(tweet, RenderOptions{}); strings.Contains(got, "aliases:") {
+		t.Errorf("aliases written without -aliases:\n%s", got)
+	}
+}
+
+func TestWriteFrontmatterEscapesItems(t *testing.T) {
+	var sb strings.Builder
+	writeFrontmatter(&sb, []frontmatterField{
+		{"tags", []string{"plain", "key: value", "#hash", "- dash", "say \"hi\""}},
+		{"extra", map[string]string{"b": "x: y", "a": "plain"}},
+		{"empty", map[string]string{}},
+		{"ids", []int(nil)},
+	}, FrontmatterBlock)
+	want := "---\n" +
+		"tags:\n  - plain\n  - \"key: value\"\n  - \"#hash\"\n  - \"- dash\"\n  - \"say \\\"hi\\\"\"\n" +
+		"extra:\n  a: plain\n  b: \"x: y\"\n" +
+		"---\n\n"
+	if got := sb.String(); got != want {
+		t.Errorf("writeFrontmatter() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestRenderThreadStripSelfMentions(t *testing.T) {
+	tweets := testThread("1/ Start", "@alice 2/ Middle", "@Alice more", "@bob 4/ Not me", "@alicex 5/ Other")
+
+	body := docBody(RenderThread(tweets, RenderOptions{StripSelfMentions: true, Renumber: true}))
+	for _, want := range []string{
+		"## 1.\n\nStart\n",
+		"## 2.\n\nMiddle\n",
+		"## 3.\n\nmore\n",
+		"## 4.\n\n@bob 4/ Not me\n",
+		"## 5.\n\n@alicex 5/ Other\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("output missing %q:\n%s", want, body)
+		}
+	}
+
+	// The first tweet keeps a leading mention; the option is off by default.
+	tweets = testThread("@alice note to self", "@alice next")
+	if body := docBody(RenderThread(tweets, RenderOptions{StripSelfMentions: true})); !strings.Contains(body, "@alice note to self") || strings.Contains(body, "@alice next") {
+		t.Errorf("-strip-self-mentions output:\n%s", body)
+	}
+	if body := docBody(RenderThread(tweets, RenderOptions{})); !strings.Contains(body, "@alice next") {
+		t.Errorf("mention stripped without -strip-self-mentions:\n%s", body)
+	}
+}
+
+func TestRenderMediaOrder(t *testing.T) {
+	tweet := testThread("Mixed")[0]
+	tweet.Media = &Media{
+		All: []MediaItem{
+			{Type: "video", URL: "https://video.twimg.com/v1.mp4", ThumbnailURL: "https://pbs.twimg.com/v1.jpg"},
+			{Type: "photo", URL: "https://pbs.twimg.com/media/p1.jpg"},
+			{Type: "gif", URL: "https://video.twimg.com/g1.mp4", ThumbnailURL: "https://pbs.twimg.com/g1.jpg"},
+			{Type: "photo", URL: "https://pbs.twimg.com/media/p2.jpg"},
+		},
+		Photos: []Photo{{URL: "https://pbs.twimg.com/media/p1.jpg"}, {URL: "https://pbs.twimg.com/media/p2.jpg"}},
+		Videos: []Video{{URL: "https://video.twimg.com/v1.mp4"}, {URL: "https://video.twimg.com/g1.mp4"}},
+	}
+
+	// inOrder reports whether each of urls appears in doc after the previous one.
+	inOrder := func(doc string, urls ...string) bool {
+		last := -1
+		for _, u := range urls {
+			i := strings.Index(doc, u)
+			if i <= last {
+				return false
+			}
+			last = i
+		}
+		return true
+	}
+
+	if got := RenderTweet(tweet, RenderOptions{}); !inOrder(got, "v1.mp4", "p1.jpg", "g1.mp4", "p2.jpg") {
+		t.Errorf("media not in Media.All order:\n%s", got)
+	}
+
+	// Without Media.All, photos come before videos.
+	tweet.Media.All = nil
+	if got := RenderTweet(tweet, RenderOptions{}); !inOrder(got, "p1.jpg", "p2.jpg", "v1.mp4", "g1.mp4") {
+		t.Errorf("fallback media order:\n%s", got)
+	}
+}
+
+func TestRenderPlace(t *testing.T) {
+	tweet := testThread("At the venue")[0]
+	tweet.Place = &Place{Name: "Moscone Center", FullName: "Moscone Center, San Francisco", Country: "United States"}
+
+	got := RenderTweet(tweet, RenderOptions{})
+	for _, want := range []string{
+		"location: \"Moscone Center, San Francisco, United States\"\n",
+		"📍 [Moscone Center, San Francisco, United States](https://www.openstreetmap.org/search?query=Moscone+Center%2C+San+Francisco%2C+United+States)\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output missing %q:\n%s", want, got)
+		}
+	}
+
+	tweet.Place = &Place{Name: "Tokyo", Country: "Japan"}
+	if got := placeName(tweet.Place); got != "Tokyo, Japan" {
+		t.Errorf("placeName() = %q, want Tokyo, Japan", got)
+	}
+
+	tweet.Place = nil
+	if got := RenderTweet(tweet, RenderOptions{}); strings.Contains(got, "location:") || strings.Contains(got, "📍") {
+		t.Errorf("place rendered for a tweet without one:\n%s", got)
+	}
+}
+
+func TestRenderNoMedia(t *testing.T) {
+	photo := &Media{Photos: []Photo{{URL: "https://pbs.twimg.com/media/p.jpg"}}}
+	tweet := testThread("Photo tweet")[0]
+	tweet.Media = photo
+	thread := testThread("One", "Two")
+	thread[1].Media = photo
+
+	article, info := testArticle(
+		Block{Type: "unstyled", Text: "Before"},
+		Block{Type: "atomic", Text: " ", EntityRanges: []EntityRange{{Key: 0, Offset: 0, Length: 1}}},
+		Block{Type: "unstyled", Text: "After"},
+	)
+	article.Article.Content.EntityMap = []EntityMapItem{{Key: 0, Value: EntityValue{
+		Type: "MEDIA", Data: EntityData{MediaItems: []EntityMediaRef{{MediaID: "m1"}}},
+	}}}
+	article.Article.MediaEntities = []ArticleMedia{{MediaID: "m1", MediaInfo: &MediaInfo{OriginalImgURL: "https://pbs.twimg.com/media/body.jpg"}}}
+	article.Article.CoverMedia = &ArticleMedia{MediaInfo: &MediaInfo{OriginalImgURL: "https://pbs.twimg.com/media/cover.jpg"}}
+
+	docs := map[string]func(RenderOptions) string{
+		"tweet":   func(opts RenderOptions) string { return RenderTweet(tweet, opts) },
+		"thread":  func(opts RenderOptions) string { return RenderThread(thread, opts) },
+		"article": func(opts RenderOptions) string { return RenderArticle(article, info, opts) },
+	}
+	for name, render := range docs {
+		if got := render(RenderOptions{}); !strings.Contains(got, "![") {
+			t.Errorf("%s: no images without -no-media:\n%s", name, got)
+		}
+		got := render(RenderOptions{NoMedia: true})
+		if strings.Contains(got, "![") || strings.Contains(got, "<img") {
+			t.Errorf("%s: image with -no-media:\n%s", name, got)
+		}
+	}
+
+	got := RenderArticle(article, info, RenderOptions{NoMedia: true})
+	if !strings.Contains(got, "cover_image: \"https://pbs.twimg.com/media/cover.jpg\"\n") || !strings.Contains(got, "Before\n\nAfter\n") {
+		t.Errorf("-no-media article lost text or cover frontmatter:\n%s", got)
+	}
+}
+
+func TestWriteMatchesRender(t *testing.T) {
+	load := func(name string) *Tweet {
+		tweet, err := LoadTweetFile(filepath.Join("testdata", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tweet
+	}
+	tweet, article := load("tweet.json"), load("article.json")
+	thread := testThread("First @bob", "Second https://example.com/?utm_source=x&id=1", "Third")
+	para := func(text string) Block { return Block{Type: "unstyled", Text: text} }
+	sections, sectionsInfo := testArticle(
+		para("  Lead #x2md"),
+		para(""),
+		para("Line one  "),
+		Block{Type: "blockquote", Text: "Note: quoted @bob"},
+		Block{Type: "blockquote", Text: "More"},
+		Block{Type: "code-block", Text: "a := 1"},
+		Block{Type: "code-block", Text: "b := 2"},
+		para("See https://example.com/?utm_medium=y [1]"),
+		Block{Type: "ordered-list-item", Text: "Source https://example.com/src"},
+		para(""),
+	)
+
+	callouts, err := parseCalloutTypes(defaultCalloutTypes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	extras := RenderOptions{
+		LinkEntities: true,
+		CleanParams:  parseParamPatterns(defaultTrackingParams),
+		Callouts:     callouts,
+		Paragraphs:   ParagraphsBreaks,
+	}
+	buffered := extras
+	buffered.Footnotes, buffered.ReadingWPM = true, 200
+	optionSets := map[string]RenderOptions{
+		"default":   {},
+		"body only": {BodyOnly: true},
+		"extras":    extras,
+		"buffered":  buffered,
+	}
+
+	for name, opts := range optionSets {
+		check := func(what, want string, write func(io.Writer) error) {
+			t.Helper()
+			var buf bytes.Buffer
+			if err := write(&buf); err != nil {
+				t.Fatalf("%s/%s: %v", name, what, err)
+			}
+			if got := buf.String(); got != want {
+				t.Errorf("%s/%s: streamed output differs\nstreamed:\n%s\nrendered:\n%s", name, what, got, want)
+			}
+		}
+		check("tweet", RenderTweet(tweet, opts), func(w io.Writer) error { return WriteTweet(w, tweet, opts) })
+		check("thread", RenderThread(thread, opts), func(w io.Writer) error { return WriteThread(w, thread, opts) })
+		for what, a := range map[string]struct {
+			tweet *Tweet
+			info  URLInfo
+		}{
+			"article":  {article, tweetURLInfo(article)},
+			"sections": {sections, sectionsInfo},
+		} {
+			check(what, RenderArticle(a.tweet, a.info, opts), func(w io.Writer) error { return WriteArticle(w, a.tweet, a.info, opts) })
+		}
+	}
+}
+
+// recordWriter records each string written to it.
+type recordWriter struct {
+	writes []string
+}
+
+func (r *recordWriter) WriteString(s string) (int, error) {
+	r.writes = append(r.writes, s)
+	return len(s), nil
+}
+
+func TestWriteArticleBodyStreamsBlocks(t *testing.T) {
+	tweet, _ := testArticle(
+		Block{Type: "header-two", Text: "One"},
+		Block{Type: "unstyled", Text: "Two"},
+		Block{Type: "unstyled", Text: "Three"},
+	)
+	var rw recordWriter
+	writeArticleBody(&rw, tweet.Article, RenderOptions{})
+	if got, want := strings.Join(rw.writes, ""), "## One\n\nTwo\n\nThree\n"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	// Each block reaches the writer on its own rather than as one string.
+	if len(rw.writes) < 3 {
+		t.Errorf("body written in %d pieces: %q", len(rw.writes), rw.writes)
+	}
+}
+
+func TestRenderThreadDedupeMedia(t *testing.T) {
+	banner := "https://pbs.twimg.com/media/banner.jpg"
+	thread := testThread("One", "Two")
+	thread[0].Media = &Media{Photos: []Photo{{URL: banner, AltText: "banner"}}}
+	thread[1].Media = &Media{Photos: []Photo{
+		{URL: banner, AltText: "banner"},
+		{URL: "https://pbs.twimg.com/media/new.jpg", AltText: "new"},
+	}}
+
+	got := RenderThread(thread, RenderOptions{DedupeMedia: true})
+	if n := strings.Count(got, "![banner]("+banner+")"); n != 1 {
+		t.Errorf("banner rendered %d times, want 1:\n%s", n, got)
+	}
+	if !strings.Contains(got, "*（重复媒体已省略）*") || !strings.Contains(got, "![new](https://pbs.twimg.com/media/new.jpg)") {
+		t.Errorf("repeat note or new image missing:\n%s", got)
+	}
+
+	// Without the option every tweet keeps its own copy.
+	if got := RenderThread(thread, RenderOptions{}); strings.Count(got, "![banner]") != 2 {
+		t.Errorf("banner not rendered twice without DedupeMedia:\n%s", got)
+	}
+}
+
+func TestRenderTweetEdited(t *testing.T) {
+	tweet := testThread("Fixed a typo")[0]
+	tweet.Edit = &EditInfo{Count: 2, EditedAt: "Wed Jan 15 13:00:00 +0000 2024"}
+	got := RenderTweet(tweet, RenderOptions{})
+	for _, want := range []string{"edited: true\n", `edited_at: "2024-01-15T13:00:00Z"`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("frontmatter missing %q:\n%s", want, got)
+		}
+	}
+
+	// Only a count: edited without a timestamp.
+	tweet.Edit = &EditInfo{Count: 1}
+	got = RenderTweet(tweet, RenderOptions{})
+	if !strings.Contains(got, "edited: true\n") || strings.Contains(got, "edited_at") {
+		t.Errorf("count-only edit rendered wrongly:\n%s", got)
+	}
+
+	tweet.Edit = nil
+	if got := RenderTweet(tweet, RenderOptions{}); strings.Contains(got, "edited") {
+		t.Errorf("unedited tweet has edit fields:\n%s", got)
+	}
+}
+
+func TestRenderMetaFields(t *testing.T) {
+	var meta metaFlag
+	for _, pair := range []string{"project=research", "type=note", "status=draft: v1", "project=archive"} {
+		if err := meta.Set(pair); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got := RenderTweet(testThread("Hello")[0], RenderOptions{Meta: meta})
+
+	if !strings.HasPrefix(got, "---\ntype: note\n") {
+		t.Errorf("type not overridden in place:\n%s", got)
+	}
+	if !strings.Contains(got, "bookmarks: 0\nproject: archive\nstatus: \"draft: v1\"\n---\n") {
+		t.Errorf("custom fields not appended, overridden and escaped:\n%s", got)
+	}
+	if strings.Contains(got, "research") || strings.Contains(got, "type: tweet") {
+		t.Errorf("overridden values kept:\n%s", got)
+	}
+
+	if err := meta.Set("no value"); err == nil {
+		t.Error("Set accepted a pair without '='")
+	}
+}
+
+func TestRenderNilAuthor(t *testing.T) {
+	anon := func(id, text string) *Tweet {
+		return &Tweet{
+			ID:    id,
+			Text:  text,
+			Media: &Media{Photos: []Photo{{URL: "https://pbs.twimg.com/media/" + id + ".jpg"}}},
+		}
+	}
+	tweet := anon("1", "Hello @bob")
+	tweet.Quote = anon("2", "Quoted")
+	tweet.QuoteThread = []*Tweet{anon("3", "Quoted thread")}
+	tweet.Parent = anon("4", "Parent")
+	retweet := &Tweet{ID: "5", RetweetedStatus: anon("6", "Original")}
+	article, _ := testArticle(Block{Type: "unstyled", Text: "Body"})
+	article.Author = nil
+	thread := []*Tweet{anon("7", "First"), anon("8", "Second")}
+
+	opts := RenderOptions{
+		Aliases:           true,
+		ShortURL:          true,
+		ThreadPermalinks:  true,
+		StripSelfMentions: true,
+		LinkEntities:      true,
+	}
+	docs := map[string]string{
+		"tweet":   RenderTweet(tweet, opts),
+		"retweet": RenderTweet(retweet, opts),
+		"article": RenderArticle(article, tweetURLInfo(article), opts),
+		"thread":  RenderThread(thread, opts),
+	}
+	if strings.Contains(docs["tweet"], "source:") || strings.Contains(docs["thread"], "source:") {
+		t.Errorf("source rendered without an author:\n%s\n%s", docs["tweet"], docs["thread"])
+	}
+	for name, doc := range docs {
+		// Links built from the author's handle are left out.
+		if strings.Contains(doc, "x.com//") || strings.Contains(doc, "x.com/i/status") || strings.Contains(doc, `"@"`) {
+			t.Errorf("%s: link or handle rendered without an author:\n%s", name, doc)
+		}
+	}
+	if !strings.Contains(docs["tweet"], "> Quoted\n> — unknown\n") || !strings.Contains(docs["tweet"], "> Parent\n> — unknown\n") {
+		t.Errorf("quote and parent not attributed to unknown:\n%s", docs["tweet"])
+	}
+	if !strings.Contains(docs["retweet"], "> Retweeted from unknown\n\nOriginal\n") {
+		t.Errorf("retweet not attributed to unknown:\n%s", docs["retweet"])
+	}
+
+	paths, err := filepath.Glob(filepath.Join("templates", "*.tmpl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range paths {
+		tmpl, err := LoadTemplate(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c := &Content{Type: ContentTweet, Tweet: tweet, Info: tweetURLInfo(tweet)}
+		if _, err := RenderTemplate(tmpl, c, RenderOptions{}); err != nil {
+			t.Errorf("%s: %v", path, err)
+		}
+	}
+}
+
+func TestRenderThreadOrder(t *testing.T) {
+	thread := testThread("First", "Second", "Third")
+	thread[2].CreatedAt = "Wed Jan 15 14:00:00 +0000 2024"
+
+	oldest := RenderThread(thread, RenderOptions{})
+	newest := RenderThread(thread, RenderOptions{ThreadOrder: ThreadOrderNewest})
+
+	if want := "\nFirst\n\n---\n\nSecond\n\n---\n\nThird\n"; docBody(oldest) != want {
+		t.Errorf("oldest-first body = %q, want %q", docBody(oldest), want)
+	}
+	if want := "\nThird\n\n---\n\nSecond\n\n---\n\nFirst\n"; docBody(newest) != want {
+		t.Errorf("newest-first body = %q, want %q", docBody(newest), want)
+	}
+	// Both orders describe the same thread: started by the first tweet,
+	// linked to the last.
+	for _, doc := range []string{oldest, newest} {
+		if !strings.Contains(doc, `date: "2024-01-15T12:30:00Z"`) || !strings.Contains(doc, `source: "https://x.com/alice/status/3"`) {
+			t.Errorf("frontmatter does not reference the boundary tweets:\n%s", doc)
+		}
+	}
+}
+
+func TestRenderRetweet(t *testing.T) {
+	got := renderFixture(t, "retweet.json", RenderOptions{})
+	for _, want := range []string{
+		"type: retweet\n",
+		`author: "@carol"`,
+		`retweeted_from: "@alice"`,
+		"> Retweeted from @alice\n\nShipping x2md today.\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "RT @alice") {
+		t.Errorf("retweet's own text rendered:\n%s", got)
+	}
+
+	// A quote tweet has content of its own and stays a tweet.
+	tweet := testThread("My take")[0]
+	tweet.Quote = testThread("Original")[0]
+	if got := RenderTweet(tweet, RenderOptions{}); !strings.Contains(got, "type: tweet\n") || strings.Contains(got, "Retweeted from") {
+		t.Errorf("quote tweet labelled as a retweet:\n%s", got)
+	}
+}
+
+func TestRenderFlattenQuote(t *testing.T) {
+	tweet := testThread("My take")[0]
+	tweet.Quote = &Tweet{ID: "9", Text: "Parsers are\nfun to write", Author: &Author{Name: "Bob", ScreenName: "bob"}}
+
+	tests := []struct {
+		length int
+		want   string
+	}{
+		{100, `(quoting @bob: "Parsers are fun to write")`},
+		{24, `(quoting @bob: "Parsers are fun to write")`},
+		{12, `(quoting @bob: "Parsers are…")`},
+		{5, `(quoting @bob: "Pars…")`},
+	}
+	for _, tt := range tests {
+		got := docBody(RenderTweet(tweet, RenderOptions{FlattenQuote: tt.length}))
+		if want := "\nMy take\n\n" + tt.want + "\n"; got != want {
+			t.Errorf("length %d: body = %q, want %q", tt.length, got, want)
+		}
+	}
+	if got := RenderTweet(tweet, RenderOptions{}); !strings.Contains(got, "> Parsers are\n> fun to write\n> — @bob\n") {
+		t.Errorf("default quote is not a blockquote:\n%s", got)
+	}
+}
+
+func TestRenderCommunityNote(t *testing.T) {
+	got := renderFixture(t, "community_note.json", RenderOptions{})
+	want := "\nThe moon landing was filmed in a studio.\n\n" +
+		"> [!NOTE] Community Note\n" +
+		"> The Apollo landings are well documented.\n" +
+		"> Independent observers tracked the missions.\n"
+	if !strings.HasSuffix(got, want) {
+		t.Errorf("note not rendered below the text:\n%s", got)
+	}
+	if !strings.Contains(got, "has_community_note: true\n") {
+		t.Errorf("frontmatter missing has_community_note:\n%s", got)
+	}
+
+	// A blank note is treated as no note.
+	tweet := testThread("Plain")[0]
+	tweet.CommunityNote = &CommunityNote{Text: "  "}
+	if got := RenderTweet(tweet, RenderOptions{}); strings.Contains(got, "Community Note") || strings.Contains(got, "has_community_note") {
+		t.Errorf("blank note rendered:\n%s", got)
+	}
+}
+
+func TestRenderTitleOverride(t *testing.T) {
+	article, info := testArticle(Block{Type: "unstyled", Text: "Body"})
+	got := RenderArticle(article, info, RenderOptions{Title: "My: Archive"})
+	if !strings.Contains(got, "title: \"My: Archive\"\n") || !strings.Contains(got, "\n# My: Archive\n") {
+		t.Errorf("article title not overridden:\n%s", got)
+	}
+	if strings.Contains(got, "Title\n") {
+		t.Errorf("original article title kept:\n%s", got)
+	}
+	if got := RenderArticle(article, info, RenderOptions{}); !strings.Contains(got, "title: Title\n") || !strings.Contains(got, "\n# Title\n") {
+		t.Errorf("article title without override:\n%s", got)
+	}
+
+	tweet := testThread("Hello")[0]
+	got = RenderTweet(tweet, RenderOptions{Title: "Greeting"})
+	if !strings.HasPrefix(got, "---\ntype: tweet\ntitle: Greeting\n") || strings.Contains(got, "# Greeting") {
+		t.Errorf("tweet title field:\n%s", got)
+	}
+	if got := RenderTweet(tweet, RenderOptions{}); strings.Contains(got, "title:") {
+		t.Errorf("tweet has a title field without -title:\n%s", got)
+	}
+}
+
+func TestRenderShortURL(t *testing.T) {
+	opts := RenderOptions{ShortURL: true}
+	thread := testThread("One", "Two")
+	article, info := testArticle(Block{Type: "unstyled", Text: "Body"})
+	docs := map[string]struct {
+		doc  string
+		want string
+	}{
+		"tweet":   {RenderTweet(thread[0], opts), "https://fixupx.com/alice/status/1"},
+		"thread":  {RenderThread(thread, opts), "https://fixupx.com/alice/status/2"},
+		"article": {RenderArticle(article, info, opts), "https://fixupx.com/alice/status/1"},
+	}
+	for name, d := range docs {
+		if want := `short_url: "` + d.want + `"` + "\n"; !strings.Contains(d.doc, want) {
+			t.Errorf("%s: missing %q:\n%s", name, want, d.doc)
+		}
+	}
+	if got := RenderTweet(thread[0], RenderOptions{}); strings.Contains(got, "short_url") {
+		t.Errorf("short_url without -short-url:\n%s", got)
+	}
+
+	// Posts from other services have no fixupx link.
+	post := testThread("Skeet")[0]
+	post.Origin, post.URL = SourceBluesky, "https://bsky.app/profile/alice/post/1"
+	if got := RenderTweet(post, opts); strings.Contains(got, "short_url") {
+		t.Errorf("short_url for a Bluesky post:\n%s", got)
+	}
+}
+
+func TestRenderCompactFrontmatter(t *testing.T) {
+	tweet := testThread("Hello")[0]
+	tweet.Likes = 3
+	opts := RenderOptions{NoStats: true, Aliases: true, Title: "a: b"}
+
+	block := RenderTweet(tweet, opts)
+	wantBlock := "---\ntype: tweet\ntitle: \"a: b\"\nauthor: \"@alice\"\nauthor_name: Alice\n" +
+		"date: \"2024-01-15T12:30:00Z\"\nsource: \"https://x.com/alice/status/1\"\naliases:\n  - \"1\"\n  - \"https://fixupx.com/alice/status/1\"\n---\n\nHello\n"
+	if block != wantBlock {
+		t.Errorf("block frontmatter =\n%s\nwant\n%s", block, wantBlock)
+	}
+
+	opts.Frontmatter = FrontmatterCompact
+	compact := RenderTweet(tweet, opts)
+	wantCompact := "---\n{type: tweet, title: \"a: b\", author: \"@alice\", author_name: Alice, " +
+		"date: \"2024-01-15T12:30:00Z\", source: \"https://x.com/alice/status/1\", aliases: [\"1\", \"https://fixupx.com/alice/status/1\"]}\n---\n\nHello\n"
+	if compact != wantCompact {
+		t.Errorf("compact frontmatter =\n%s\nwant\n%s", compact, wantCompact)
+	}
+	if docBody(block) != docBody(compact) {
+		t.Errorf("bodies differ between frontmatter styles")
+	}
+}
+
+func TestRenderDeletedQuote(t *testing.T) {
+	var tweet Tweet
+	// FxTwitter's stand-in for a deleted quoted tweet: an object with no
+	// text and no author.
+	data := `{"id": "1", "text": "Look at this", "author": {"name": "Alice", "screen_name": "alice"}, "quote": {"id": "", "text": "", "author": {}}}`
+	if err := json.Unmarshal([]byte(data), &tweet); err != nil {
+		t.Fatal(err)
+	}
+	got := RenderTweet(&tweet, RenderOptions{})
+	if !strings.HasSuffix(got, "\nLook at this\n\n> [quoted tweet unavailable]\n") {
+		t.Errorf("deleted quote not marked unavailable:\n%s", got)
+	}
+	if strings.Contains(got, "> —") {
+		t.Errorf("empty attribution rendered:\n%s", got)
+	}
+
+	tweet.Quote = &Tweet{}
+	if got := RenderTweet(&tweet, RenderOptions{FlattenQuote: 50}); !strings.HasSuffix(got, "\nLook at this\n\n([quoted tweet unavailable])\n") {
+		t.Errorf("flattened deleted quote:\n%s", got)
+	}
+}
+
+func TestArticleDescriptionTruncation(t *testing.T) {
+	tests := []struct {
+		name    string
+		preview string
+		max     int
+		want    string
+	}{
+		{"short", "Preview text", 160, "Preview text"},
+		{"exact", "abcde", 5, "abcde"},
+		{"cut", "abcdefgh", 5, "abcd…"},
+		{"trailing space before cut", "abc defgh", 5, "abc…"},
+		{"CJK counts characters", "一二三四五六七八", 4, "一二三…"},
+		{"emoji counts characters", "😀😀😀😀", 3, "😀😀…"},
+		{"joins lines", "First line\n\n  second   line", 0, "First line second line"},
+		{"unlimited", strings.Repeat("x", 300), 0, strings.Repeat("x", 300)},
+	}
+	for _, tt := range tests {
+		if got := articleDescription(tt.preview, tt.max); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	tweet, info := testArticle()
+	tweet.Article.PreviewText = strings.Repeat("长", 200)
+	got := RenderArticle(tweet, info, RenderOptions{DescriptionLength: 10})
+	if want := "description: " + strings.Repeat("长", 9) + "…\n"; !strings.Contains(got, want) {
+		t.Errorf("frontmatter missing %q:\n%s", want, got)
+	}
+}
+
+func TestRenderFigure(t *testing.T) {
+	tweet := testThread("Photo tweet")[0]
+	tweet.Media = &Media{Photos: []Photo{{URL: "https://pbs.twimg.com/media/a.jpg", AltText: "A \"quoted\"\ncat"}}}
+	got := RenderTweet(tweet, RenderOptions{Figure: true})
+	want := "<figure>\n" +
+		`<img src="https://pbs.twimg.com/media/a.jpg" alt="A &#34;quoted&#34;` + "\ncat\">\n" +
+		"<figcaption>A &#34;quoted&#34; cat</figcaption>\n" +
+		"</figure>\n"
+	if !strings.Contains(got, want) {
+		t.Errorf("photo figure missing %q:\n%s", want, got)
+	}
+	if strings.Contains(got, "![") {
+		t.Errorf("Markdown image alongside figure:\n%s", got)
+	}
+
+	// Without alt text the figure has no caption.
+	tweet.Media.Photos[0].AltText = ""
+	if got := RenderTweet(tweet, RenderOptions{Figure: true}); strings.Contains(got, "<figcaption>") {
+		t.Errorf("empty figcaption:\n%s", got)
+	}
+
+	article, info := testArticle(Block{Type: "atomic", Text: " ", EntityRanges: []EntityRange{{Key: 0, Offset: 0, Length: 1}}})
+	article.Article.Content.EntityMap = []EntityMapItem{{Key: 0, Value: EntityValue{
+		Type: "MEDIA", Data: EntityData{Caption: "Body image", MediaItems: []EntityMediaRef{{MediaID: "m1"}}},
+	}}}
+	article.Article.MediaEntities = []ArticleMedia{{MediaID: "m1", MediaInfo: &MediaInfo{OriginalImgURL: "https://pbs.twimg.com/media/body.jpg"}}}
+	article.Article.CoverMedia = &ArticleMedia{MediaInfo: &MediaInfo{OriginalImgURL: "https://pbs.twimg.com/media/cover.jpg"}}
+	got = RenderArticle(article, info, RenderOptions{Figure: true})
+	for _, want := range []string{
+		"<figure>\n<img src=\"https://pbs.twimg.com/media/cover.jpg\" alt=\"cover\">\n</figure>\n\n",
+		// The entity caption becomes the figcaption rather than the alt text.
+		"<figure>\n<img src=\"https://pbs.twimg.com/media/body.jpg\" alt=\"\">\n<figcaption>Body image</figcaption>\n</figure>",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("article missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "![") {
+		t.Errorf("Markdown image alongside figure:\n%s", got)
+	}
+}
+
+// frontmatterKeys returns the top-level keys of a document's block
+// frontmatter, in order.
+func frontmatterKeys(doc string) []string {
+	var keys []string
+	for _, line := range strings.Split(doc, "\n")[1:] {
+		if line == "---" {
+			break
+		}
+		if key, _, ok := strings.Cut(line, ":"); ok && !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "-") {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func TestRenderSortFrontmatter(t *testing.T) {
+	var meta metaFlag
+	if err := meta.Set("project=research"); err != nil {
+		t.Fatal(err)
+	}
+	tweet := testThread("Hello #golang")[0]
+	tweet.Author.Name = "Alice: the first"
+
+	got := RenderTweet(tweet, RenderOptions{SortFrontmatter: true, Meta: meta})
+	keys := frontmatterKeys(got)
+	if len(keys) < 5 || !sort.StringsAreSorted(keys) {
+		t.Errorf("keys not sorted: %v\n%s", keys, got)
+	}
+	// Values keep their quoting and list form.
+	for _, want := range []string{
+		"author: \"@alice\"\n",
+		"author_name: \"Alice: the first\"\n",
+		"date: \"2024-01-15T12:30:00Z\"\n",
+		"project: research\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("sorted frontmatter missing %q:\n%s", want, got)
+		}
+	}
+
+	// The default keeps the renderer's order, type first.
+	keys = frontmatterKeys(RenderTweet(tweet, RenderOptions{Meta: meta}))
+	if keys[0] != "type" || keys[len(keys)-1] != "project" {
+		t.Errorf("default key order = %v", keys)
+	}
+	if sort.StringsAreSorted(keys) {
+		t.Errorf("default keys sorted: %v", keys)
+	}
+}
+
+func TestRenderPollTable(t *testing.T) {
+	tweet := testThread("Which editor?")[0]
+	tweet.Poll = &Poll{
+		Choices: []PollChoice{
+			{Label: "Vim", Count: 30, Percentage: 30},
+			{Label: "Emacs | Evil", Count: 60, Percentage: 60},
+			{Label: "Other", Count: 10, Percentage: 10},
+		},
+		TotalVotes: 100,
+		Ended:      true,
+	}
+
+	got := RenderTweet(tweet, RenderOptions{PollStyle: PollStyleTable})
+	want := "**投票** (已结束)\n\n" +
+		"| Option | Votes | Percentage |\n" +
+		"| --- | ---: | ---: |\n" +
+		"| Vim | 30 | 30.0% |\n" +
+		"| **Emacs \\| Evil** | **60** | **60.0%** |\n" +
+		"| Other | 10 | 10.0% |\n" +
+		"\n共 100 票\n"
+	if !strings.Contains(got, want) {
+		t.Errorf("poll table missing:\n%s\ngot:\n%s", want, got)
+	}
+	if strings.Contains(got, "█") {
+		t.Errorf("bar chart in table style:\n%s", got)
+	}
+
+	// Ties bold every leader; a poll without votes bolds none.
+	var sb strings.Builder
+	writePollTable(&sb, &Poll{Choices: []PollChoice{{Label: "A", Count: 2}, {Label: "B", Count: 2}, {Label: "C", Count: 1}}})
+	if n := strings.Count(sb.String(), "\n| **"); n != 2 {
+		t.Errorf("tie bolded %d rows, want 2:\n%s", n, sb.String())
+	}
+	sb.Reset()
+	writePollTable(&sb, &Poll{Choices: []PollChoice{{Label: "A"}, {Label: "B"}}})
+	if strings.Contains(sb.String(), "**") {
+		t.Errorf("empty poll bolded a row:\n%s", sb.String())
+	}
+
+	// The bar chart stays the default.
+	for _, style := range []string{"", PollStyleBar} {
+		if got := RenderTweet(tweet, RenderOptions{PollStyle: style}); !strings.Contains(got, "- Vim ██████░") || strings.Contains(got, "| Option |") {
+			t.Errorf("style %q not a bar chart:\n%s", style, got)
+		}
+	}
+}
+
+func TestRenderCreatedTimestampFallback(t *testing.T) {
+	// 1705321800 is 2024-01-15T12:30:00Z.
+	tweets := testThread("First", "Second")
+	for _, tweet := range tweets {
+		tweet.CreatedAt = "sometime last week"
+		tweet.CreatedTimestamp = 1705321800
+	}
+	const want = "date: \"2024-01-15T12:30:00Z\"\n"
+
+	if got := RenderTweet(tweets[0], RenderOptions{}); !strings.Contains(got, want) {
+		t.Errorf("tweet date missing %q:\n%s", want, got)
+	}
+	if got := RenderThread(tweets, RenderOptions{}); !strings.Contains(got, want) {
+		t.Errorf("thread date missing %q:\n%s", want, got)
+	}
+	article, info := testArticle()
+	article.CreatedAt, article.CreatedTimestamp = "", 1705321800
+	if got := RenderArticle(article, info, RenderOptions{}); !strings.Contains(got, want) {
+		t.Errorf("article date missing %q:\n%s", want, got)
+	}
+	if got := filenameDate(tweets[0]); got != "2024-01-15" {
+		t.Errorf("filenameDate() = %q, want 2024-01-15", got)
+	}
+
+	// A parseable created_at wins over the timestamp.
+	tweets[0].CreatedAt = "Tue Jan 16 08:00:00 +0000 2024"
+	if got := tweetDate(tweets[0]); got != "2024-01-16T08:00:00Z" {
+		t.Errorf("tweetDate() = %q, want created_at", got)
+	}
+	// With neither, the raw string is kept.
+	tweets[0].CreatedAt, tweets[0].CreatedTimestamp = "sometime last week", 0
+	if got := tweetDate(tweets[0]); got != "sometime last week" {
+		t.Errorf("tweetDate() = %q, want the raw created_at", got)
+	}
+}
+
+func TestRenderBodyOnly(t *testing.T) {
+	article, info := testArticle(Block{Type: "unstyled", Text: "First paragraph."}, Block{Type: "unstyled", Text: "Second."})
+	article.Article.CoverMedia = &ArticleMedia{MediaInfo: &MediaInfo{OriginalImgURL: "https://pbs.twimg.com/media/cover.jpg"}}
+	opts := RenderOptions{BodyOnly: true}
+
+	if got, want := RenderArticle(article, info, opts), "First paragraph.\n\nSecond.\n"; got != want {
+		t.Errorf("article body = %q, want %q", got, want)
+	}
+
+	tweet := testThread("Just the text")[0]
+	tweet.Media = &Media{Photos: []Photo{{URL: "https://pbs.twimg.com/media/a.jpg"}}}
+	got := RenderTweet(tweet, opts)
+	if strings.Contains(got, "---") || strings.Contains(got, "# ") || !strings.HasPrefix(got, "Just the text\n") ||
+		!strings.Contains(got, "![image](https://pbs.twimg.com/media/a.jpg)") {
+		t.Errorf("tweet body = %q", got)
+	}
+
+	// A preview-only article falls back to its preview text.
+	partial, info := testArticle()
+	if got := RenderArticle(partial, info, opts); got != "Preview text\n" {
+		t.Errorf("partial article body = %q, want the preview text", got)
+	}
+	// With no preview either, a placeholder stands in for the empty body.
+	partial.Article.PreviewText = " "
+	if got, want := RenderArticle(partial, info, opts), "[article content unavailable](https://x.com/alice/status/1)\n"; got != want {
+		t.Errorf("unavailable article body = %q, want %q", got, want)
+	}
+}
+
+func TestRenderQuotedArticle(t *testing.T) {
+	tweet := testThread("Worth a read")[0]
+	tweet.Quote = &Tweet{
+		ID:     "77",
+		Text:   "https://t.co/abc123",
+		URL:    "https://x.com/bob/status/77",
+		Author: &Author{Name: "Bob", ScreenName: "bob"},
+		Article: &Article{
+			ID:          "555",
+			Title:       "Scaling Postgres",
+			PreviewText: "Lessons from a year of\nrunning a large cluster.",
+		},
+	}
+
+	got := RenderTweet(tweet, RenderOptions{DescriptionLength: 30})
+	want := "> **[Scaling Postgres](https://x.com/bob/article/555)**\n" +
+		">\n" +
+		"> Lessons from a year of runnin…\n" +
+		"> — @bob\n"
+	if !strings.Contains(got, want) {
+		t.Errorf("quoted article missing:\n%s\ngot:\n%s", want, got)
+	}
+	if strings.Contains(got, "t.co/abc123") {
+		t.Errorf("raw quote text rendered:\n%s", got)
+	}
+
+	// Without an article ID the link falls back to the quoted tweet.
+	tweet.Quote.Article.ID = ""
+	if got := RenderTweet(tweet, RenderOptions{}); !strings.Contains(got, "[Scaling Postgres](https://x.com/bob/status/77)") {
+		t.Errorf("article link did not fall back to the tweet:\n%s", got)
+	}
+	if got := RenderTweet(tweet, RenderOptions{FlattenQuote: 50}); !strings.Contains(got, "(quoting @bob: [Scaling Postgres](https://x.com/bob/status/77))") {
+		t.Errorf("flattened quoted article wrong:\n%s", got)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// templateFuncs are the helpers available to -template files.
+var templateFuncs = template.FuncMap{
+	// formatDate normalizes a tweet date to RFC 3339 UTC.
+	"formatDate": formatDate,
+	// yaml escapes a string for use as a frontmatter value.
+	"yaml": yamlEscape,
+	// photos returns a tweet's photos (nil-safe).
+	"photos": func(t *Tweet) []Photo {
+		if t == nil || t.Media == nil {
+			return nil
+		}
+		return t.Media.Photos
+	},
+	// videos returns a tweet's videos (nil-safe).
+	"videos": func(t *Tweet) []Video {
+		if t == nil || t.Media == nil {
+			return nil
+		}
+		return t.Media.Videos
+	},
+	// articleBody converts an article's Draft.js content to Markdown; see
+	// RenderTemplate.
+	"articleBody": templateArticleBody(RenderOptions{}),
+	// quote prefixes every line with "> ".
+	"quote": func(s string) string {
+		return "> " + strings.ReplaceAll(s, "\n", "\n> ")
+	},
+}
+
+// LoadTemplate parses a text/template file with the x2md helper functions.
+func LoadTemplate(path string) (*template.Template, error) {
+	return template.New(filepath.Base(path)).Funcs(templateFuncs).ParseFiles(path)
+}
+
+// RenderTemplate renders content through tmpl instead of the built-in
+// renderers. articleBody converts articles the way RenderArticle does with
+// opts, and a thread is narrowed to opts.Since and opts.Until first; like
+// RenderThread, a thread with no tweets in that window renders as nothing.
+func RenderTemplate(tmpl *template.Template, c *Content, opts RenderOptions) (string, error) {
+	if c.Type == ContentThread {
+		tweets := tweetsBetween(c.Tweets, opts.Since, opts.Until)
+		if len(tweets) == 0 {
+			return "", nil
+		}
+		filtered := *c
+		filtered.Tweet, filtered.Tweets = tweets[0], tweets
+		c = &filtered
+	}
+
+	tmpl, err := tmpl.Clone()
+	if err != nil {
+		return "", err
+	}
+	tmpl.Funcs(template.FuncMap{"articleBody": templateArticleBody(opts)})
+
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, c); err != nil {
+		return "", err
+	}
+	return cleanWhitespace(sb.String()), nil
+}
+
+// templateArticleBody returns the articleBody template helper for opts.
+func templateArticleBody(opts RenderOptions) func(*Article) string {
+	return func(a *Article) string {
+		if a == nil || a.Content == nil {
+			return ""
+		}
+		return articleBody(a, opts)
+	}
+}
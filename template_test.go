@@ -0,0 +1,131 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+)
+
+func TestRenderTemplateInline(t *testing.T) {
+	tmpl := template.Must(template.New("inline").Funcs(templateFuncs).Parse(
+		"---\nauthor: {{ yaml (printf \"@%s\" .Tweet.Author.ScreenName) }}\ndate: {{ formatDate .Tweet.CreatedAt }}\n---\n\n" +
+			"{{ quote .Tweet.Text }}\n{{ range photos .Tweet }}\n![{{ .AltText }}]({{ .URL }})\n{{ end }}",
+	))
+	tweet := testThread("Line one\nLine two")[0]
+	tweet.Media = &Media{Photos: []Photo{{URL: "https://pbs.twimg.com/media/a.jpg", AltText: "alt"}}}
+
+	got, err := RenderTemplate(tmpl, newTweetContent(tweet, tweetURLInfo(tweet)), RenderOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "---\nauthor: \"@alice\"\ndate: 2024-01-15T12:30:00Z\n---\n\n> Line one\n> Line two\n\n![alt](https://pbs.twimg.com/media/a.jpg)\n"
+	if got != want {
+		t.Errorf("RenderTemplate() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestShippedTemplates(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("templates", "*.tmpl"))
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("no shipped templates: %v", err)
+	}
+	tweets := testThread("First", "Second")
+	c := &Content{Type: ContentThread, Tweet: tweets[0], Tweets: tweets, Info: tweetURLInfo(tweets[0])}
+	for _, path := range paths {
+		tmpl, err := LoadTemplate(path)
+		if err != nil {
+			t.Errorf("%s: %v", path, err)
+			continue
+		}
+		got, err := RenderTemplate(tmpl, c, RenderOptions{})
+		if err != nil {
+			t.Errorf("%s: %v", path, err)
+			continue
+		}
+		if !strings.Contains(got, "First") || !strings.Contains(got, "Second") {
+			t.Errorf("%s: thread text missing:\n%s", path, got)
+		}
+	}
+}
+
+func TestRenderTemplateArticleOptions(t *testing.T) {
+	tmpl, err := LoadTemplate(filepath.Join("templates", "hugo.tmpl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tweet, info := testArticle(
+		Block{
+			Type: "unstyled",
+			Text: "Key point in red",
+			InlineStyleRanges: []InlineStyleRange{
+				{Offset: 0, Length: 9, Style: "HIGHLIGHT"},
+				{Offset: 13, Length: 3, Style: "color-red"},
+			},
+		},
+		Block{Type: "atomic", Text: " ", EntityRanges: []EntityRange{{Key: 0, Offset: 0, Length: 1}}},
+	)
+	article := tweet.Article
+	article.Content.EntityMap = []EntityMapItem{{Key: 0, Value: EntityValue{
+		Type: "MEDIA",
+		Data: EntityData{Caption: "Chart", MediaItems: []EntityMediaRef{{MediaID: "1"}}},
+	}}}
+	article.MediaEntities = []ArticleMedia{{MediaID: "1", MediaInfo: &MediaInfo{OriginalImgURL: "https://pbs.twimg.com/media/1.jpg"}}}
+	c := &Content{Type: ContentArticle, Tweet: tweet, Tweets: []*Tweet{tweet}, Info: info}
+
+	render := func(opts RenderOptions) string {
+		t.Helper()
+		got, err := RenderTemplate(tmpl, c, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+	got := render(RenderOptions{})
+	for _, want := range []string{"==Key point== in red\n", "![Chart](https://pbs.twimg.com/media/1.jpg)\n*Chart*\n"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("default output missing %q:\n%s", want, got)
+		}
+	}
+
+	got = render(RenderOptions{PreserveColor: true, Figure: true})
+	for _, want := range []string{`==Key point== in <span style="color:red">red</span>`, "<figcaption>Chart</figcaption>"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("-preserve-color -figure output missing %q:\n%s", want, got)
+		}
+	}
+
+	if got := render(RenderOptions{NoMedia: true}); strings.Contains(got, "pbs.twimg.com") {
+		t.Errorf("-no-media output keeps the image:\n%s", got)
+	}
+	// Options given for one render do not stick to the template.
+	if got := render(RenderOptions{}); strings.Contains(got, "<span") {
+		t.Errorf("options leaked into a later render:\n%s", got)
+	}
+}
+
+func TestRenderTemplateDateWindow(t *testing.T) {
+	tmpl := template.Must(template.New("thread").Funcs(templateFuncs).Parse(
+		"first: {{ .Tweet.Text }}\n{{ range .Tweets }}- {{ .Text }}\n{{ end }}",
+	))
+	tweets := datedThread("Day 10", "Day 11", "Day 12")
+	c := &Content{Type: ContentThread, Tweet: tweets[0], Tweets: tweets, Info: tweetURLInfo(tweets[0])}
+
+	opts := RenderOptions{Since: time.Date(2024, 1, 11, 0, 0, 0, 0, time.UTC)}
+	got, err := RenderTemplate(tmpl, c, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "first: Day 11\n- Day 11\n- Day 12\n"; got != want {
+		t.Errorf("RenderTemplate() = %q, want %q", got, want)
+	}
+	if len(c.Tweets) != 3 || c.Tweet != tweets[0] {
+		t.Error("RenderTemplate modified the content")
+	}
+
+	opts.Since = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	if got, err := RenderTemplate(tmpl, c, opts); err != nil || got != "" {
+		t.Errorf("empty window = %q, %v; want nothing", got, err)
+	}
+}
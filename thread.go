@@ -1,40 +1,106 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"strings"
+	"time"
+	"unicode"
 )
 
-const maxThreadDepth = 50
+const (
+	// maxThreadDepth is the default -max-depth.
+	maxThreadDepth      = 50
+	maxQuoteThreadDepth = 10
+)
+
+// Reasons a thread traversal stops.
+const (
+	stopRoot         = "reached root"
+	stopDepthLimit   = "reached depth limit"
+	stopParentFailed = "parent fetch failed"
+	stopCrossAuthor  = "reply crosses authors"
+	stopCycle        = "reply cycle"
+)
+
+// threadStop explains why a thread traversal stopped; err is the fetch error
+// behind stopParentFailed.
+type threadStop struct {
+	reason string
+	err    error
+}
+
+func (s threadStop) String() string {
+	if s.err != nil {
+		return s.reason + ": " + s.err.Error()
+	}
+	return s.reason
+}
 
 // FetchThread fetches an entire thread by traversing replying_to_status upward.
-// It returns tweets in chronological order (oldest first).
-func FetchThread(screenName, id string) ([]*Tweet, error) {
+// It returns tweets in chronological order (oldest first). When ctx is
+// cancelled, the partial chain fetched so far is returned along with the error.
+// At most maxDepth tweets are fetched through f; 0 means maxThreadDepth. A
+// traversal that stops before the thread's first tweet is reported on stderr.
+func FetchThread(ctx context.Context, f Fetcher, screenName, id string, maxDepth int) ([]*Tweet, error) {
+	if maxDepth <= 0 {
+		maxDepth = maxThreadDepth
+	}
+	chain, stop, err := fetchThread(ctx, f, screenName, id, maxDepth, fetchProgress.Fetched)
+	if err == nil && stop.reason != stopRoot {
+		warnf("线程获取提前停止（%s），已获取 %d 条，导出的线程可能不完整", stop, len(chain))
+	}
+	return chain, err
+}
+
+// fetchThread is FetchThread with an explicit depth limit, also reporting why
+// traversal stopped. If ctx is cancelled mid-thread, the tweets fetched so far
+// are returned with ctx's error. onFetch, if non-nil, is called with the chain
+// length after each tweet.
+func fetchThread(ctx context.Context, f Fetcher, screenName, id string, maxDepth int, onFetch func(int)) ([]*Tweet, threadStop, error) {
 	var chain []*Tweet
+	seen := make(map[string]bool)
 
 	currentScreenName := screenName
 	currentID := id
+	stop := threadStop{reason: stopDepthLimit}
 
-	for i := 0; i < maxThreadDepth; i++ {
-		tweet, err := FetchTweet(currentScreenName, currentID)
+	for i := 0; i < maxDepth; i++ {
+		// Guard against reply cycles in malformed data.
+		if seen[currentID] {
+			stop = threadStop{reason: stopCycle}
+			break
+		}
+		seen[currentID] = true
+
+		tweet, err := f.FetchTweet(ctx, currentScreenName, currentID)
 		if err != nil {
+			if ctx.Err() != nil {
+				reverse(chain)
+				return chain, stop, ctx.Err()
+			}
 			if len(chain) == 0 {
-				return nil, fmt.Errorf("failed to fetch tweet %s: %w", currentID, err)
+				return nil, stop, fmt.Errorf("failed to fetch tweet %s: %w", currentID, err)
 			}
 			// If we fail to fetch a parent tweet, stop traversal and return what we have.
+			stop = threadStop{reason: stopParentFailed, err: err}
 			break
 		}
 
 		chain = append(chain, tweet)
+		if onFetch != nil {
+			onFetch(len(chain))
+		}
 
 		// Check if this tweet is a reply to another tweet by the same author (thread).
 		if tweet.ReplyingToStatus == "" {
+			stop = threadStop{reason: stopRoot}
 			break
 		}
 
 		// Only follow the chain if replying to the same author (self-thread).
-		if tweet.ReplyingTo != "" && tweet.Author != nil &&
-			!strings.EqualFold(tweet.ReplyingTo, tweet.Author.ScreenName) {
+		if tweet.ReplyingTo != "" && tweet.Author != nil && !repliesToSelf(tweet) {
+			stop = threadStop{reason: stopCrossAuthor}
 			break
 		}
 
@@ -48,7 +114,64 @@ func FetchThread(screenName, id string) ([]*Tweet, error) {
 	// Reverse to chronological order (oldest first).
 	reverse(chain)
 
-	return chain, nil
+	return chain, stop, nil
+}
+
+// replyHandles splits a replying_to value, which may name several accounts
+// ("@alice @bob" or "alice, bob"), into bare screen names.
+func replyHandles(replyingTo string) []string {
+	var handles []string
+	for _, f := range strings.FieldsFunc(replyingTo, func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	}) {
+		if h := strings.TrimPrefix(f, "@"); h != "" {
+			handles = append(handles, h)
+		}
+	}
+	return handles
+}
+
+// repliesToSelf reports whether the tweet's author is among the accounts it
+// replies to, as when continuing a thread while also mentioning others.
+func repliesToSelf(tweet *Tweet) bool {
+	for _, h := range replyHandles(tweet.ReplyingTo) {
+		if strings.EqualFold(h, tweet.Author.ScreenName) {
+			return true
+		}
+	}
+	return false
+}
+
+// ExpandQuotes fetches the thread leading up to each tweet's quoted tweet and
+// stores it in QuoteThread. Quotes pointing back into tweets are skipped, and
+// each quoted tweet is expanded at most once.
+func ExpandQuotes(ctx context.Context, f Fetcher, tweets []*Tweet) {
+	seen := make(map[string]bool)
+	for _, tweet := range tweets {
+		seen[tweet.ID] = true
+	}
+
+	for _, tweet := range tweets {
+		quote := tweet.Quote
+		if quote == nil || quote.ID == "" || quote.Author == nil || seen[quote.ID] {
+			continue
+		}
+		seen[quote.ID] = true
+
+		chain, _, err := fetchThread(ctx, f, quote.Author.ScreenName, quote.ID, maxQuoteThreadDepth, nil)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			warnf("获取引用线程失败 %s: %v", quote.ID, err)
+			continue
+		}
+		// The quote itself, last in the chain, is already rendered by the
+		// quote block; keep only the tweets leading up to it.
+		if len(chain) > 1 {
+			tweet.QuoteThread = chain[:len(chain)-1]
+		}
+	}
 }
 
 // reverse reverses a slice of tweets in place.
@@ -58,3 +181,40 @@ func reverse(tweets []*Tweet) {
 	}
 }
 
+// tweetsBetween returns the tweets posted at or after since and before
+// until, skipping tweets whose date is unknown. A zero bound is open; with
+// both zero, tweets is returned as is.
+func tweetsBetween(tweets []*Tweet, since, until time.Time) []*Tweet {
+	if since.IsZero() && until.IsZero() {
+		return tweets
+	}
+	var kept []*Tweet
+	for _, tweet := range tweets {
+		t, ok := tweetTime(tweet)
+		if !ok || (!since.IsZero() && t.Before(since)) || (!until.IsZero() && !t.Before(until)) {
+			continue
+		}
+		kept = append(kept, tweet)
+	}
+	return kept
+}
+
+// parseDateBound parses a -since or -until value, either a date
+// (2006-01-02, UTC) or an RFC 3339 time. A date given as an upper bound
+// covers the whole day. An empty value is the zero time.
+func parseDateBound(s string, upper bool) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		if upper {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q (want YYYY-MM-DD or RFC 3339)", s)
+	}
+	return t, nil
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeFetcher serves tweets by ID from memory, recording each ID fetched.
+type fakeFetcher struct {
+	tweets  map[string]*Tweet
+	fetched []string
+}
+
+func newFakeFetcher(tweets ...*Tweet) *fakeFetcher {
+	f := &fakeFetcher{tweets: make(map[string]*Tweet)}
+	for _, t := range tweets {
+		f.tweets[t.ID] = t
+	}
+	return f
+}
+
+func (f *fakeFetcher) FetchTweet(ctx context.Context, screenName, id string) (*Tweet, error) {
+	f.fetched = append(f.fetched, id)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	tweet, ok := f.tweets[id]
+	if !ok {
+		return nil, fmt.Errorf("tweet %s not found", id)
+	}
+	return tweet, nil
+}
+
+// reply returns a tweet by screenName replying to parent's author.
+func reply(id, screenName, text string, parent *Tweet) *Tweet {
+	t := &Tweet{ID: id, Text: text, Author: &Author{Name: screenName, ScreenName: screenName}}
+	if parent != nil {
+		t.ReplyingToStatus = parent.ID
+		t.ReplyingTo = parent.Author.ScreenName
+	}
+	return t
+}
+
+func TestExpandQuotesTwoTweetThread(t *testing.T) {
+	q1 := reply("q1", "bob", "Quoted thread start", nil)
+	q2 := reply("q2", "bob", "Quoted thread end", q1)
+	f := newFakeFetcher(q1, q2)
+
+	tweet := reply("t1", "alice", "Look at this", nil)
+	tweet.Quote = &Tweet{ID: "q2", Text: q2.Text, Author: q2.Author}
+	ExpandQuotes(context.Background(), f, []*Tweet{tweet})
+
+	if len(tweet.QuoteThread) != 1 || tweet.QuoteThread[0] != q1 {
+		t.Fatalf("QuoteThread = %v, want [q1]", tweet.QuoteThread)
+	}
+
+	got := RenderTweet(tweet, RenderOptions{})
+	for _, want := range []string{
+		"> <summary>引用推文之前的线程（1 条）</summary>",
+		"> Quoted thread start\n",
+		"> </details>",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output missing %q:\n%s", want, got)
+		}
+	}
+	if n := strings.Count(got, "Quoted thread end"); n != 1 {
+		t.Errorf("quoted tweet rendered %d times:\n%s", n, got)
+	}
+
+	// A flattened quote is followed by an unquoted section.
+	got = RenderTweet(tweet, RenderOptions{FlattenQuote: 80})
+	want := "(quoting @bob: \"Quoted thread end\")\n\n<details>\n<summary>引用推文之前的线程（1 条）</summary>\n\nQuoted thread start\n\n</details>\n"
+	if !strings.Contains(got, want) || strings.Contains(got, "> ") {
+		t.Errorf("flattened quote thread:\n%s\nwant it to contain\n%s", got, want)
+	}
+}
+
+func TestExpandQuotesSkipsCycles(t *testing.T) {
+	first := reply("t1", "alice", "First", nil)
+	second := reply("t2", "alice", "Second", first)
+	// The second tweet quotes the first, which is already in the thread.
+	second.Quote = &Tweet{ID: "t1", Author: first.Author}
+	f := newFakeFetcher(first, second)
+
+	ExpandQuotes(context.Background(), f, []*Tweet{first, second})
+	if len(f.fetched) != 0 {
+		t.Errorf("fetched %v for a quote inside the thread", f.fetched)
+	}
+	if second.QuoteThread != nil {
+		t.Errorf("QuoteThread = %v, want none", second.QuoteThread)
+	}
+}
+
+// cancellingFetcher cancels the fetch context when asked for tweet cancelAt.
+type cancellingFetcher struct {
+	*fakeFetcher
+	cancelAt string
+	cancel   context.CancelFunc
+}
+
+func (f *cancellingFetcher) FetchTweet(ctx context.Context, screenName, id string) (*Tweet, error) {
+	if id == f.cancelAt {
+		f.cancel()
+	}
+	return f.fakeFetcher.FetchTweet(ctx, screenName, id)
+}
+
+func TestFetchThreadCancelledPartialOutput(t *testing.T) {
+	t1 := reply("t1", "alice", "Part one", nil)
+	t2 := reply("t2", "alice", "Part two", t1)
+	t3 := reply("t3", "alice", "Part three", t2)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	f := &cancellingFetcher{fakeFetcher: newFakeFetcher(t1, t2, t3), cancelAt: "t1", cancel: cancel}
+
+	tweets, err := FetchThread(ctx, f, "alice", "t3", 0)
+	if err != context.Canceled {
+		t.Fatalf("FetchThread error = %v, want context.Canceled", err)
+	}
+	if len(tweets) != 2 || tweets[0] != t2 || tweets[1] != t3 {
+		t.Fatalf("partial thread = %v, want [t2 t3]", tweets)
+	}
+
+	// What was fetched still renders, oldest first.
+	got := RenderThread(tweets, RenderOptions{})
+	if !strings.Contains(got, "tweet_count: 2\n") || !strings.Contains(got, "Part two\n\n---\n\nPart three\n") {
+		t.Errorf("partial thread rendered wrongly:\n%s", got)
+	}
+	if strings.Contains(got, "Part one") {
+		t.Errorf("tweet fetched after cancellation rendered:\n%s", got)
+	}
+}
+
+// selfThread builds a self-thread of n tweets by screenName with IDs
+// "t1".."tn", each replying to the one before.
+func selfThread(screenName string, n int) []*Tweet {
+	tweets := make([]*Tweet, n)
+	var parent *Tweet
+	for i := range tweets {
+		parent = reply(fmt.Sprintf("t%d", i+1), screenName, fmt.Sprintf("Part %d", i+1), parent)
+		tweets[i] = parent
+	}
+	return tweets
+}
+
+func TestFetchThreadMaxDepth(t *testing.T) {
+	chain := selfThread("alice", 10)
+	f := newFakeFetcher(chain...)
+
+	tweets, stop, err := fetchThread(context.Background(), f, "alice", "t10", 3, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.fetched) != 3 || len(tweets) != 3 {
+		t.Fatalf("fetched %v, returned %d tweets; want 3", f.fetched, len(tweets))
+	}
+	if tweets[0].ID != "t8" || tweets[2].ID != "t10" {
+		t.Errorf("thread = %s..%s, want t8..t10", tweets[0].ID, tweets[2].ID)
+	}
+	if stop.reason != stopDepthLimit {
+		t.Errorf("stop reason = %q, want %q", stop.reason, stopDepthLimit)
+	}
+
+	// A limit beyond the chain reaches its root.
+	f = newFakeFetcher(chain...)
+	if tweets, stop, _ := fetchThread(context.Background(), f, "alice", "t10", 50, nil); len(tweets) != 10 || stop.reason != stopRoot {
+		t.Errorf("got %d tweets stopping at %q, want 10 at the root", len(tweets), stop.reason)
+	}
+	// Zero means the default depth.
+	f = newFakeFetcher(selfThread("alice", maxThreadDepth+5)...)
+	tweets, err = FetchThread(context.Background(), f, "alice", fmt.Sprintf("t%d", maxThreadDepth+5), 0)
+	if err != nil || len(tweets) != maxThreadDepth {
+		t.Errorf("default depth fetched %d tweets (%v), want %d", len(tweets), err, maxThreadDepth)
+	}
+}
+
+func TestMaxDepthFlagValidation(t *testing.T) {
+	for _, depth := range []string{"0", "-3"} {
+		_, stderr, code := runX2MD(t, "-thread", "-max-depth", depth, "https://x.com/alice/status/1")
+		if code == 0 || !strings.Contains(stderr, "-max-depth 必须大于 0") {
+			t.Errorf("-max-depth %s: exit %d, stderr %q", depth, code, stderr)
+		}
+	}
+}
+
+func TestFetchThreadStopReasons(t *testing.T) {
+	root := reply("t1", "alice", "Root", nil)
+	mid := reply("t2", "alice", "Middle", root)
+	leaf := reply("t3", "alice", "Leaf", mid)
+
+	bobRoot := reply("b1", "bob", "Bob's tweet", nil)
+	crossing := reply("t4", "alice", "Replying to bob", bobRoot)
+
+	// t5 and t6 reply to each other.
+	loopA := reply("t5", "alice", "Loop A", nil)
+	loopB := reply("t6", "alice", "Loop B", loopA)
+	loopA.ReplyingToStatus, loopA.ReplyingTo = "t6", "alice"
+
+	tests := []struct {
+		name     string
+		tweets   []*Tweet
+		id       string
+		depth    int
+		wantIDs  []string
+		wantStop string
+	}{
+		{"root", []*Tweet{root, mid, leaf}, "t3", 10, []string{"t1", "t2", "t3"}, stopRoot},
+		{"depth limit", []*Tweet{root, mid, leaf}, "t3", 2, []string{"t2", "t3"}, stopDepthLimit},
+		{"parent failed", []*Tweet{mid, leaf}, "t3", 10, []string{"t2", "t3"}, stopParentFailed},
+		{"cross author", []*Tweet{bobRoot, crossing}, "t4", 10, []string{"t4"}, stopCrossAuthor},
+		{"cycle", []*Tweet{loopA, loopB}, "t6", 10, []string{"t5", "t6"}, stopCycle},
+	}
+	for _, tt := range tests {
+		tweets, stop, err := fetchThread(context.Background(), newFakeFetcher(tt.tweets...), "alice", tt.id, tt.depth, nil)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		var ids []string
+		for _, tweet := range tweets {
+			ids = append(ids, tweet.ID)
+		}
+		if strings.Join(ids, ",") != strings.Join(tt.wantIDs, ",") {
+			t.Errorf("%s: thread = %v, want %v", tt.name, ids, tt.wantIDs)
+		}
+		if stop.reason != tt.wantStop {
+			t.Errorf("%s: stop reason = %q, want %q", tt.name, stop.reason, tt.wantStop)
+		}
+		if (stop.err != nil) != (tt.wantStop == stopParentFailed) {
+			t.Errorf("%s: stop error = %v", tt.name, stop.err)
+		}
+	}
+}
+
+func TestRepliesToSelfMultipleHandles(t *testing.T) {
+	tests := []struct {
+		replyingTo string
+		want       bool
+	}{
+		{"@alice @bob", true},
+		{"alice, bob", true},
+		{"@Bob", true},
+		{"@alice @carol", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		tweet := &Tweet{ReplyingTo: tt.replyingTo, Author: &Author{ScreenName: "bob"}}
+		if got := repliesToSelf(tweet); got != tt.want {
+			t.Errorf("repliesToSelf(%q) by bob = %v, want %v", tt.replyingTo, got, tt.want)
+		}
+	}
+
+	// A self-reply that also mentions others keeps following the thread.
+	root := reply("t1", "bob", "Start", nil)
+	next := reply("t2", "bob", "Continued", root)
+	next.ReplyingTo = "@alice @bob"
+	tweets, stop, err := fetchThread(context.Background(), newFakeFetcher(root, next), "bob", "t2", 10, nil)
+	if err != nil || len(tweets) != 2 || stop.reason != stopRoot {
+		t.Errorf("got %d tweets stopping at %q (%v), want 2 at the root", len(tweets), stop.reason, err)
+	}
+}
+
+// datedThread is testThread with the tweets posted on consecutive days from
+// 2024-01-10, dated only by created_timestamp.
+func datedThread(texts ...string) []*Tweet {
+	tweets := testThread(texts...)
+	start := time.Date(2024, 1, 10, 9, 0, 0, 0, time.UTC)
+	for i, tweet := range tweets {
+		tweet.CreatedAt = ""
+		tweet.CreatedTimestamp = start.AddDate(0, 0, i).Unix()
+	}
+	return tweets
+}
+
+func TestThreadDateWindow(t *testing.T) {
+	tweets := datedThread("Day 10", "Day 11", "Day 12", "Day 13")
+	since, err := parseDateBound("2024-01-11", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	until, err := parseDateBound("2024-01-12", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kept := tweetsBetween(tweets, since, until)
+	if len(kept) != 2 || kept[0] != tweets[1] || kept[1] != tweets[2] {
+		t.Fatalf("tweetsBetween() kept %d tweets, want days 11 and 12", len(kept))
+	}
+
+	got := RenderThread(tweets, RenderOptions{Since: since, Until: until})
+	for _, want := range []string{
+		"tweet_count: 2\n",
+		"date: \"2024-01-11T09:00:00Z\"\n",
+		"source: \"https://x.com/alice/status/3\"\n",
+		"Day 11\n\n---\n\nDay 12\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("filtered thread missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "Day 10") || strings.Contains(got, "Day 13") {
+		t.Errorf("tweet outside the window rendered:\n%s", got)
+	}
+
+	// Open-ended bounds and an empty window.
+	if n := len(tweetsBetween(tweets, since, time.Time{})); n != 3 {
+		t.Errorf("-since alone kept %d tweets, want 3", n)
+	}
+	if got := RenderThread(tweets, RenderOptions{Since: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}); got != "" {
+		t.Errorf("empty window rendered %q", got)
+	}
+
+	for _, bad := range []string{"2024-13-01", "yesterday", "2024/01/11"} {
+		if _, err := parseDateBound(bad, false); err == nil {
+			t.Errorf("parseDateBound(%q) accepted an invalid date", bad)
+		}
+	}
+	if got, err := parseDateBound("2024-01-11T08:00:00+08:00", true); err != nil || !got.Equal(time.Date(2024, 1, 11, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("RFC 3339 bound = %v, %v", got, err)
+	}
+}
+
+func TestDateWindowFlagValidation(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-since", "2024-01-11"}, "-since 与 -until 需配合 -thread 使用"},
+		{[]string{"-thread", "-until", "soon"}, "-until 无效"},
+		{[]string{"-thread", "-since", "2024-02-01", "-until", "2024-01-01"}, "-since 必须早于 -until"},
+	}
+	for _, tt := range tests {
+		args := append(tt.args, "https://x.com/alice/status/1")
+		if _, stderr, code := runX2MD(t, args...); code == 0 || !strings.Contains(stderr, tt.want) {
+			t.Errorf("%v: exit %d, stderr %q, want %q", tt.args, code, stderr, tt.want)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// txtListItemRe matches a "* " or "+ " bullet, which becomes "- ".
+	txtListItemRe = regexp.MustCompile(`^(\s*)[*+] `)
+	// txtTagRe matches an HTML tag.
+	txtTagRe = regexp.MustCompile(`</?[a-zA-Z][^>]*>`)
+)
+
+// MarkdownToText converts a rendered Markdown document to plain text for
+// -format txt: frontmatter is dropped, bullets become "- ", images become
+// "[image]", links become "text (url)" and emphasis, code, quote and heading
+// markers go. It shares org.go's patterns for the Markdown it recognizes.
+func MarkdownToText(md string) string {
+	_, body := orgKeywords(md)
+	return cleanWhitespace(txtBlocks(body))
+}
+
+// txtBlocks converts md line by line, keeping code block contents as they
+// are and unwrapping blockquotes.
+func txtBlocks(md string) string {
+	lines := strings.Split(md, "\n")
+	var out []string
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case strings.HasPrefix(line, "```"):
+			for i++; i < len(lines) && !strings.HasPrefix(lines[i], "```"); i++ {
+				out = append(out, lines[i])
+			}
+
+		case strings.HasPrefix(line, ">"):
+			var quoted []string
+			for ; i < len(lines) && strings.HasPrefix(lines[i], ">"); i++ {
+				quoted = append(quoted, strings.TrimPrefix(lines[i][1:], " "))
+			}
+			i--
+			if orgCalloutRe.MatchString(quoted[0]) {
+				quoted = quoted[1:]
+			}
+			out = append(out, txtBlocks(strings.Join(quoted, "\n")))
+
+		case orgTableRuleRe.MatchString(line):
+			// The header row reads fine directly above the body rows.
+
+		default:
+			out = append(out, txtLine(line))
+		}
+	}
+	return strings.Join(out, "\n")
+}
+
+// txtLine converts a single line outside code blocks and quotes.
+func txtLine(line string) string {
+	if m := orgHeadingRe.FindStringSubmatch(line); m != nil {
+		return txtInline(m[2])
+	}
+	switch {
+	case orgRuleRe.MatchString(line):
+		return ""
+	case orgImgRe.MatchString(line):
+		return "[image]"
+	case strings.HasPrefix(line, "|") && strings.HasSuffix(line, "|") && len(line) > 1:
+		cells := strings.Split(line[1:len(line)-1], "|")
+		for i, cell := range cells {
+			cells[i] = txtInline(strings.TrimSpace(cell))
+		}
+		return strings.Join(cells, "\t")
+	}
+	line = txtListItemRe.ReplaceAllString(line, "$1- ")
+	if m := orgFootnoteDefRe.FindStringSubmatch(line); m != nil {
+		line = "[" + m[1] + "] " + line[len(m[0]):]
+	}
+	return txtInline(strings.TrimRight(line, " "))
+}
+
+// txtInline strips inline Markdown and HTML. Code, escapes and URLs are set
+// aside while emphasis markers are removed so they keep their characters.
+func txtInline(s string) string {
+	var stash []string
+	keep := func(text string) string {
+		stash = append(stash, text)
+		return "\x00" + strconv.Itoa(len(stash)-1) + "\x00"
+	}
+
+	s = orgCodeSpanRe.ReplaceAllStringFunc(s, func(m string) string {
+		return keep(codeSpanText(m))
+	})
+	s = orgEscapeRe.ReplaceAllStringFunc(s, func(m string) string {
+		return keep(m[1:])
+	})
+	s = orgFootnoteRefRe.ReplaceAllStringFunc(s, func(m string) string {
+		return keep("[" + m[2:len(m)-1] + "]")
+	})
+	s = orgAutolinkRe.ReplaceAllStringFunc(s, func(m string) string {
+		return keep(m[1 : len(m)-1])
+	})
+	s = orgLinkedImageRe.ReplaceAllString(s, "[image]")
+	s = orgImageRe.ReplaceAllString(s, "[image]")
+	s = txtTagRe.ReplaceAllStringFunc(s, func(m string) string {
+		if strings.HasPrefix(m, "<img ") {
+			return "[image]"
+		}
+		return ""
+	})
+	s = orgLinkRe.ReplaceAllStringFunc(s, func(m string) string {
+		sub := orgLinkRe.FindStringSubmatch(m)
+		if sub[1] == "" || sub[1] == sub[2] {
+			return keep(sub[2])
+		}
+		return sub[1] + " (" + keep(sub[2]) + ")"
+	})
+	s = orgBareURLRe.ReplaceAllStringFunc(s, keep)
+
+	s = orgBoldRe.ReplaceAllString(s, "$1$2")
+	s = orgStrikeRe.ReplaceAllString(s, "$1")
+	for range 2 {
+		s = orgItalicRe.ReplaceAllString(s, "$1$4$2$5$3$6")
+	}
+	s = html.UnescapeString(s)
+	return orgStashRe.ReplaceAllStringFunc(s, func(m string) string {
+		i, _ := strconv.Atoi(m[1 : len(m)-1])
+		return stash[i]
+	})
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// markdownMarkerRe matches Markdown syntax that plain text output must not
+// contain.
+var markdownMarkerRe = regexp.MustCompile("(?m)\\*\\*|__|~~|`|!\\[|\\]\\(|^#{1,6} |^> ?|^---$|^\\* |<[a-z/][^>]*>|^\\|.*\\|$|\\[\\^")
+
+func TestMarkdownToTextNoMarkers(t *testing.T) {
+	tweet := testThread("Hello **world**, see [docs](https://example.com/a_b) and `x`.\n\n* one\n* two")[0]
+	tweet.Media = &Media{Photos: []Photo{{URL: "https://pbs.twimg.com/media/a.jpg", AltText: "A cat"}}}
+	tweet.Poll = &Poll{Choices: []PollChoice{{Label: "Yes", Count: 2, Percentage: 100}}, TotalVotes: 2}
+	tweet.Quote = &Tweet{ID: "2", Text: "Quoted _words_", Author: &Author{Name: "Bob", ScreenName: "bob"}}
+
+	article, info := testArticle(
+		Block{Type: "header-two", Text: "Section"},
+		Block{Type: "unstyled", Text: "Bold and italic", InlineStyleRanges: []InlineStyleRange{
+			{Offset: 0, Length: 4, Style: "Bold"}, {Offset: 9, Length: 6, Style: "Italic"},
+		}},
+		Block{Type: "blockquote", Text: "A quote"},
+		Block{Type: "unstyled", Text: "Cited [1]."},
+		Block{Type: "ordered-list-item", Text: "https://example.com/source"},
+	)
+
+	docs := map[string]string{
+		"tweet":           RenderTweet(tweet, RenderOptions{}),
+		"figure tweet":    RenderTweet(tweet, RenderOptions{Figure: true, PollStyle: PollStyleTable}),
+		"article":         RenderArticle(article, info, RenderOptions{}),
+		"footnotes":       RenderArticle(article, info, RenderOptions{Footnotes: true}),
+		"tweet fixture":   renderFixture(t, "tweet.json", RenderOptions{}),
+		"article fixture": renderFixture(t, "article.json", RenderOptions{}),
+	}
+	for name, md := range docs {
+		got := MarkdownToText(md)
+		if m := markdownMarkerRe.FindAllString(got, -1); m != nil {
+			t.Errorf("%s: Markdown markers %q left in:\n%s", name, m, got)
+		}
+	}
+
+	got := MarkdownToText(docs["tweet"])
+	for _, want := range []string{
+		"Hello world, see docs (https://example.com/a_b) and x.\n",
+		"- one\n- two\n",
+		"[image]",
+		"Quoted words",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("tweet text missing %q:\n%s", want, got)
+		}
+	}
+
+	// Code keeps its characters.
+	if got := MarkdownToText("Use `**kwargs` here.\n\n```\n# not a heading\n**kept**\n```\n"); got != "Use **kwargs here.\n\n# not a heading\n**kept**\n" {
+		t.Errorf("code = %q", got)
+	}
+}
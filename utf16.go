@@ -0,0 +1,18 @@
+package main
+
+import "unicode/utf16"
+
+// utf16OffsetToRuneIndex converts an offset counted in UTF-16 code units
+// (as X and Draft.js count them) into an index into runes. Characters outside
+// the BMP, such as most emoji, take two code units but one rune. The result
+// is clamped to [0, len(runes)].
+func utf16OffsetToRuneIndex(runes []rune, offset int) int {
+	units := 0
+	for i, r := range runes {
+		if units >= offset {
+			return i
+		}
+		units += utf16.RuneLen(r)
+	}
+	return len(runes)
+}
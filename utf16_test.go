@@ -0,0 +1,29 @@
+package main
+
+import "testing"
+
+func TestUTF16OffsetToRuneIndex(t *testing.T) {
+	tests := []struct {
+		name   string
+		text   string
+		offset int
+		want   int
+	}{
+		{"ascii start", "hello", 0, 0},
+		{"ascii middle", "hello", 3, 3},
+		{"ascii end", "hello", 5, 5},
+		{"4-byte emoji before", "😀ab", 2, 1},
+		{"after two emoji", "😀😀ab", 4, 2},
+		{"inside surrogate pair", "😀ab", 1, 1},
+		{"combining acute", "e\u0301x", 2, 2},
+		{"BMP CJK", "中文ab", 2, 2},
+		{"past end clamps", "ab", 10, 2},
+		{"negative clamps", "ab", -1, 0},
+		{"empty text", "", 3, 0},
+	}
+	for _, tt := range tests {
+		if got := utf16OffsetToRuneIndex([]rune(tt.text), tt.offset); got != tt.want {
+			t.Errorf("%s: utf16OffsetToRuneIndex(%q, %d) = %d, want %d", tt.name, tt.text, tt.offset, got, tt.want)
+		}
+	}
+}
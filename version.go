@@ -0,0 +1,17 @@
+package main
+
+import "fmt"
+
+// Build metadata, injected at build time:
+//
+//	go build -ldflags "-X main.version=1.2.0 -X main.commit=$(git rev-parse --short HEAD) -X main.buildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+var (
+	version   = "0.0.0-dev"
+	commit    = "unknown"
+	buildDate = "unknown"
+)
+
+// versionString returns the version line printed by -version.
+func versionString() string {
+	return fmt.Sprintf("x2md %s (commit %s, built %s)", version, commit, buildDate)
+}